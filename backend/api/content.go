@@ -0,0 +1,54 @@
+package api
+
+import (
+	"fmt"
+
+	"brainbook-api/internal/database"
+	"brainbook-api/internal/markdown"
+	"brainbook-api/internal/validator"
+)
+
+// Limits shared by posts and comments, on creation and on edit.
+const (
+	maxContentRunes = 500
+	maxFileBytes    = 10_000_000
+	// maxLongFormRunes is the length limit of Markdown posts.
+	maxLongFormRunes = 20_000
+)
+
+// validateContent checks the text and optional image of a post or comment.
+// contentField is the field name the error is reported under.
+func validateContent(v *validator.Validator, contentField, content string, file []byte) {
+	validateContentOfLength(v, contentField, content, file, maxContentRunes)
+}
+
+// validatePostContent checks a post written in format, which defaults to plain text.
+// Markdown posts are long-form and may be up to maxLongFormRunes long.
+func validatePostContent(v *validator.Validator, contentField, content, format string, file []byte) {
+	switch format {
+	case database.FormatPlain:
+		validateContentOfLength(v, contentField, content, file, maxContentRunes)
+	case database.FormatMarkdown:
+		validateContentOfLength(v, contentField, content, file, maxLongFormRunes)
+	default:
+		v.AddFieldError("content_format", "Content format must be plain or markdown")
+	}
+}
+
+func validateContentOfLength(v *validator.Validator, contentField, content string, file []byte, maxRunes int) {
+	v.CheckField(validator.NotBlank(content), contentField, "Content must not be empty")
+	v.CheckField(validator.MaxRunes(content, maxRunes), contentField, fmt.Sprintf("Content must not exceed %d characters", maxRunes))
+	if len(file) > 0 {
+		v.CheckField(len(file) <= maxFileBytes, "file", "File size must be 10MB or less")
+		v.CheckField(isAllowedImage(file), "file", "File must be JPEG, PNG, or GIF")
+	}
+}
+
+// renderContent returns the sanitized HTML of content written in format, or nil for plain text.
+func renderContent(format, content string) *string {
+	if format != database.FormatMarkdown {
+		return nil
+	}
+	rendered := markdown.Render(content)
+	return &rendered
+}
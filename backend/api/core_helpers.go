@@ -1,31 +1,38 @@
 package api
 
 import (
+	"encoding/base64"
 	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
+
+	"brainbook-api/internal/database"
+	"brainbook-api/internal/validator"
 )
 
-// func (app *Application) backgroundTask(r *http.Request, fn func() error) {
-// 	app.WG.Add(1)
+// backgroundTask runs fn after the response has been written. It is tracked in app.WG, so shutdown
+// waits for it; a panic or error is reported against the request that started it.
+func (app *Application) backgroundTask(r *http.Request, fn func() error) {
+	app.WG.Add(1)
 
-// 	go func() {
-// 		defer app.WG.Done()
+	go func() {
+		defer app.WG.Done()
 
-// 		defer func() {
-// 			err := recover()
-// 			if err != nil {
-// 				app.reportServerError(r, fmt.Errorf("%s", err))
-// 			}
-// 		}()
+		defer func() {
+			err := recover()
+			if err != nil {
+				app.reportServerError(r, fmt.Errorf("%s", err))
+			}
+		}()
 
-// 		err := fn()
-// 		if err != nil {
-// 			app.reportServerError(r, err)
-// 		}
-// 	}()
-// }
+		err := fn()
+		if err != nil {
+			app.reportServerError(r, err)
+		}
+	}()
+}
 
 func parseStringID(stringID string) (int, error) {
 
@@ -54,6 +61,47 @@ func parseQueryInt(r *http.Request, key string, fallback int) int {
 	return fallback
 }
 
+// encodeCursor turns a (created_at, id) position into an opaque pagination cursor.
+func encodeCursor(createdAt time.Time, id int) string {
+	raw := fmt.Sprintf("%d:%d", createdAt.UTC().Unix(), id)
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// parseQueryCursor reads an opaque cursor from the query string.
+// It returns nil when the parameter is absent.
+func parseQueryCursor(r *http.Request, key string) (*database.Cursor, error) {
+	value := strings.TrimSpace(r.URL.Query().Get(key))
+	if value == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s parameter", key)
+	}
+
+	unixPart, idPart, found := strings.Cut(string(raw), ":")
+	if !found {
+		return nil, fmt.Errorf("invalid %s parameter", key)
+	}
+	seconds, err := strconv.ParseInt(unixPart, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s parameter", key)
+	}
+	id, err := strconv.Atoi(idPart)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s parameter", key)
+	}
+
+	return &database.Cursor{CreatedAt: time.Unix(seconds, 0).UTC(), ID: id}, nil
+}
+
+func parseQueryBool(r *http.Request, key string) bool {
+	value := strings.TrimSpace(r.URL.Query().Get(key))
+	parsed, err := strconv.ParseBool(value)
+	return err == nil && parsed
+}
+
 func isAllowedImage(data []byte) bool {
 	contentType := http.DetectContentType(data)
 	switch contentType {
@@ -63,3 +111,18 @@ func isAllowedImage(data []byte) bool {
 		return false
 	}
 }
+
+// checkFutureTime validates an RFC 3339 timestamp that must be in the future and returns it in
+// the database format. Errors are reported on v under field, using label as the subject.
+func checkFutureTime(v *validator.Validator, field, label, value string) string {
+	when, err := time.Parse(time.RFC3339, strings.TrimSpace(value))
+	if err != nil {
+		v.AddFieldError(field, label+" must be an RFC 3339 timestamp")
+		return ""
+	}
+	if !when.After(time.Now()) {
+		v.AddFieldError(field, label+" must be in the future")
+		return ""
+	}
+	return when.UTC().Format("2006-01-02 15:04:05")
+}
@@ -0,0 +1,285 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"brainbook-api/internal/media"
+	t "brainbook-api/internal/time"
+)
+
+const (
+	purgeInterval = time.Hour
+	// mediaGCInterval is how often files nothing references are collected.
+	mediaGCInterval = time.Hour
+	// mediaGCBatch is how many orphaned files are read per query by the collector.
+	mediaGCBatch = 100
+	// maxArticleSchedulerSleep bounds the wait for the next scheduled article, so that rows
+	// scheduled outside this process are still picked up.
+	maxArticleSchedulerSleep = time.Hour
+	// mediaScanInterval is how often the malware scanner looks for pending files without being
+	// woken, which retries files whose scan failed and picks up uploads of other processes.
+	mediaScanInterval = time.Minute
+)
+
+// startJobs launches the periodic background jobs. They stop when ctx is cancelled.
+func (app *Application) startJobs(ctx context.Context) {
+	app.runPeriodically(ctx, "purge deleted content", purgeInterval, app.purgeDeletedContent)
+	app.runPeriodically(ctx, "collect orphaned media", mediaGCInterval, app.collectOrphanedMedia)
+	app.runArticleScheduler(ctx)
+	app.runMediaScanner(ctx)
+}
+
+// runPeriodically runs fn every interval until ctx is cancelled. The job is tracked in app.WG,
+// so shutdown waits for a running pass to finish; a panic or error only ends the current pass.
+func (app *Application) runPeriodically(ctx context.Context, name string, interval time.Duration, fn func(ctx context.Context) error) {
+	app.WG.Add(1)
+
+	go func() {
+		defer app.WG.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			app.runJobPass(ctx, name, fn)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (app *Application) runJobPass(ctx context.Context, name string, fn func(ctx context.Context) error) {
+	defer func() {
+		if err := recover(); err != nil {
+			app.Logger.Error(fmt.Sprintf("%s", err), slog.String("job", name))
+		}
+	}()
+
+	if err := fn(ctx); err != nil {
+		app.Logger.Error(err.Error(), slog.String("job", name))
+	}
+}
+
+// purgeDeletedContent permanently removes posts and comments deleted longer than the retention period ago.
+func (app *Application) purgeDeletedContent(ctx context.Context) error {
+	if app.Config.Content.RetentionPeriod <= 0 {
+		return nil
+	}
+
+	cutoff := time.Now().UTC().Add(-app.Config.Content.RetentionPeriod).Format("2006-01-02 15:04:05")
+	purged, err := app.DB.PurgeDeletedContent(cutoff)
+	if err != nil {
+		return err
+	}
+
+	if purged > 0 {
+		app.Logger.Info("purged deleted content", slog.Int64("rows", purged))
+	}
+	return nil
+}
+
+// collectOrphanedMedia deletes the files that nothing has referenced for the grace period:
+// uploads never attached, replaced avatars and covers, and the files of purged content. It then
+// sweeps the store for files without a media row. A pass stops early when ctx is cancelled and
+// resumes on the next run.
+func (app *Application) collectOrphanedMedia(ctx context.Context) error {
+	if app.Config.Media.OrphanGracePeriod <= 0 {
+		return nil
+	}
+
+	cutoff := time.Now().UTC().Add(-app.Config.Media.OrphanGracePeriod).Format("2006-01-02 15:04:05")
+	var collected, storeFiles int
+	var freed int64
+
+	for ctx.Err() == nil {
+		files, err := app.DB.OrphanedMedia(cutoff, mediaGCBatch)
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			break
+		}
+
+		progress := false
+		for _, file := range files {
+			if ctx.Err() != nil {
+				break
+			}
+
+			keys, deleted, err := app.DB.DeleteOrphanedMedia(file.ID)
+			if err != nil {
+				return err
+			}
+			if !deleted {
+				continue
+			}
+			progress = true
+			collected++
+			freed += file.Size
+
+			for _, key := range keys {
+				err := app.Media.Delete(key)
+				if errors.Is(err, media.ErrRecentlyStored) {
+					// An upload of the same bytes is about to reference the file again, or
+					// the sweep deletes it once it is old enough.
+					continue
+				}
+				if err != nil {
+					app.Logger.Error(err.Error(), slog.String("job", "collect orphaned media"), slog.Int("media_id", file.ID))
+					continue
+				}
+				storeFiles++
+			}
+		}
+		if !progress {
+			break
+		}
+	}
+
+	if collected > 0 {
+		app.Logger.Info("collected orphaned media", slog.Int("media", collected), slog.Int("store_files", storeFiles), slog.Int64("bytes", freed))
+	}
+
+	swept, err := app.sweepMediaStore(ctx)
+	if err != nil {
+		return err
+	}
+	if swept > 0 {
+		app.Logger.Info("swept unreferenced media files", slog.Int("store_files", swept))
+	}
+	return nil
+}
+
+// sweepMediaStore deletes the store files that no media row or variant references: those of
+// uploads that failed before recording their row, and those the collector found recently
+// stored. The store keeps recently stored files, which an upload may be about to reference.
+func (app *Application) sweepMediaStore(ctx context.Context) (int, error) {
+	var swept int
+	err := app.Media.Walk(func(key string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		referenced, err := app.DB.MediaKeyReferenced(key)
+		if err != nil || referenced {
+			return err
+		}
+
+		err = app.Media.Delete(key)
+		if errors.Is(err, media.ErrRecentlyStored) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		swept++
+		return nil
+	})
+	if ctx.Err() != nil {
+		return swept, nil
+	}
+	return swept, err
+}
+
+// runArticleScheduler publishes scheduled articles when they fall due until ctx is cancelled.
+// Pending rows are read back from the database on every pass, so schedules survive restarts
+// and articles that fell due while the server was down are published on startup.
+func (app *Application) runArticleScheduler(ctx context.Context) {
+	app.articleScheduled = make(chan struct{}, 1)
+	app.WG.Add(1)
+
+	go func() {
+		defer app.WG.Done()
+
+		for {
+			app.runJobPass(ctx, "publish scheduled articles", app.publishDueArticles)
+
+			timer := time.NewTimer(app.nextArticleWait())
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-app.articleScheduled:
+			case <-timer.C:
+			}
+			timer.Stop()
+		}
+	}()
+}
+
+// wakeArticleScheduler makes the scheduler look again for the next article to publish,
+// after one was scheduled or rescheduled.
+func (app *Application) wakeArticleScheduler() {
+	select {
+	case app.articleScheduled <- struct{}{}:
+	default:
+	}
+}
+
+// runMediaScanner scans uploaded files as they are stored until ctx is cancelled.
+func (app *Application) runMediaScanner(ctx context.Context) {
+	app.mediaStored = make(chan struct{}, 1)
+	app.WG.Add(1)
+
+	go func() {
+		defer app.WG.Done()
+
+		for {
+			app.runJobPass(ctx, "scan uploaded media", app.scanPendingMedia)
+
+			timer := time.NewTimer(mediaScanInterval)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-app.mediaStored:
+			case <-timer.C:
+			}
+			timer.Stop()
+		}
+	}()
+}
+
+// wakeMediaScanner makes the malware scanner look for pending files, after one was uploaded.
+func (app *Application) wakeMediaScanner() {
+	select {
+	case app.mediaStored <- struct{}{}:
+	default:
+	}
+}
+
+// publishDueArticles publishes the scheduled articles whose publish time has passed.
+func (app *Application) publishDueArticles(ctx context.Context) error {
+	published, err := app.DB.PublishDueArticles(t.CurrentTime())
+	if err != nil {
+		return err
+	}
+
+	if published > 0 {
+		app.Logger.Info("published scheduled articles", slog.Int64("rows", published))
+	}
+	return nil
+}
+
+// nextArticleWait returns how long the scheduler sleeps before its next pass. Timestamps have
+// a one second resolution, which is also the shortest wait.
+func (app *Application) nextArticleWait() time.Duration {
+	next, ok, err := app.DB.NextScheduledArticle()
+	if err != nil {
+		app.Logger.Error(err.Error(), slog.String("job", "publish scheduled articles"))
+		return time.Minute
+	}
+	if !ok {
+		return maxArticleSchedulerSleep
+	}
+
+	return min(max(time.Until(next), time.Second), maxArticleSchedulerSleep)
+}
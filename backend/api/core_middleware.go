@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"slices"
 
 	// "strconv"
 	// "strings"
@@ -151,6 +152,24 @@ func (app *Application) authorize(next http.Handler) http.Handler {
 	})
 }
 
+// requireAdmin restricts the next handler to the users listed in Config.AdminIDs.
+func (app *Application) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user := contextGetAuthenticatedUser(r)
+		if user == nil {
+			app.authenticationRequired(w, r)
+			return
+		}
+
+		if !slices.Contains(app.Config.AdminIDs, user.ID) {
+			app.Unauthorized(w, r)
+			return
+		}
+
+		next(w, r)
+	}
+}
+
 //func (app *Application) authenticate(next http.Handler) http.Handler {
 // return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 // 	w.Header().Add("Vary", "Authorization")
@@ -0,0 +1,17 @@
+package api
+
+import (
+	"brainbook-api/internal/database"
+	"brainbook-api/internal/policy"
+)
+
+// canViewProfile reports whether viewerID (0 for guests) may see the full profile of target.
+func (app *Application) canViewProfile(viewerID int, target *database.User) (bool, error) {
+	rel, err := app.DB.Relation(viewerID, target.ID)
+	if err != nil {
+		return false, err
+	}
+
+	resource := policy.Resource{Kind: policy.KindUser, OwnerID: target.ID, OwnerIsPublic: target.IsPublic}
+	return policy.Allowed(policy.Viewer{ID: viewerID}, policy.ViewProfile, resource, rel), nil
+}
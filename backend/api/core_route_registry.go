@@ -56,6 +56,22 @@ func (rr *RouteRegistry) PostMethod(path string, handler http.HandlerFunc) *Rout
 	return rr
 }
 
+// PATCH registers a PATCH route on the appropriate mux.
+func (rr *RouteRegistry) PatchMethod(path string, handler http.HandlerFunc) *RouteRegistry {
+	rr.routes[path] = append(rr.routes[path], "PATCH")
+	mux, finalPath := rr.routeToMux(path)
+	mux.HandleFunc("PATCH "+finalPath, handler)
+	return rr
+}
+
+// DELETE registers a DELETE route on the appropriate mux.
+func (rr *RouteRegistry) DeleteMethod(path string, handler http.HandlerFunc) *RouteRegistry {
+	rr.routes[path] = append(rr.routes[path], "DELETE")
+	mux, finalPath := rr.routeToMux(path)
+	mux.HandleFunc("DELETE "+finalPath, handler)
+	return rr
+}
+
 // HandleFunc registers a route without method prefix
 func (rr *RouteRegistry) HandleFunc(pattern string, handler http.HandlerFunc) *RouteRegistry {
 	mux, finalPattern := rr.routeToMux(pattern)
@@ -29,7 +29,12 @@ func (app *Application) routes() http.Handler {
 		GetMethod("/protected/v1/private-messages/user/{id}", app.getConversation).
 		GetMethod("/protected/v1/posts", app.getPosts).
 		GetMethod("/protected/v1/posts/{post_id}/comments", app.getPostComments).
+		GetMethod("/protected/v1/posts/{post_id}/comments/{comment_id}/replies", app.getCommentReplies).
+		GetMethod("/protected/v1/posts/{post_id}/revisions", app.getPostRevisions).
+		GetMethod("/protected/v1/posts/{post_id}/comments/{comment_id}/revisions", app.getCommentRevisions).
 		GetMethod("/protected/v1/notifications", app.getNotifications).
+		GetMethod("/protected/v1/tags/following", app.getFollowedTags).
+		GetMethod("/protected/v1/tags/{tag}/posts", app.getTagPosts).
 		GetMethod("/protected/v1/groups", app.getGroups).
 		PostMethod("/protected/v1/groups", app.createGroup).
 		GetMethod("/protected/v1/user/groups", app.userGroups).
@@ -39,22 +44,84 @@ func (app *Application) routes() http.Handler {
 		GetMethod("/protected/v1/groups/{group_id}/messages", app.requireGroupMember(app.getGroupMessages)).
 		GetMethod("/protected/v1/groups/{group_id}/events", app.requireGroupMember(app.listGroupEvents)).
 		GetMethod("/protected/v1/groups/{group_id}/posts/{post_id}/comments", app.requireGroupMember(app.getGroupPostComments)).
+		GetMethod("/protected/v1/groups/{group_id}/posts/{post_id}/comments/{comment_id}/replies", app.requireGroupMember(app.getGroupPostCommentReplies)).
+		GetMethod("/protected/v1/groups/{group_id}/posts/{post_id}/revisions", app.requireGroupMember(app.getGroupPostRevisions)).
+		GetMethod("/protected/v1/groups/{group_id}/posts/{post_id}/comments/{comment_id}/revisions", app.requireGroupMember(app.getGroupPostCommentRevisions)).
+		GetMethod("/protected/v1/articles", app.getArticles).
+		GetMethod("/protected/v1/articles/drafts", app.getArticleDrafts).
+		GetMethod("/protected/v1/articles/{article_id}", app.getArticle).
+		GetMethod("/protected/v1/posts/{post_id}/poll", app.getPoll(app.postPollTarget)).
+		GetMethod("/protected/v1/posts/{post_id}/poll/voters", app.getPollVoters(app.postPollTarget)).
+		GetMethod("/protected/v1/groups/{group_id}/posts/{post_id}/poll", app.requireGroupMember(app.getPoll(app.groupPostPollTarget))).
+		GetMethod("/protected/v1/groups/{group_id}/posts/{post_id}/poll/voters", app.requireGroupMember(app.getPollVoters(app.groupPostPollTarget))).
+		GetMethod("/protected/v1/bookmarks", app.getBookmarks).
+		GetMethod("/protected/v1/bookmarks/collections", app.getBookmarkCollections).
+		GetMethod("/protected/v1/media/{media_id}", app.getMedia).
+		GetMethod("/protected/v1/storage", app.getMyStorage).
+		GetMethod("/protected/v1/groups/{group_id}/storage", app.requireGroupMember(app.getGroupStorage)).
+		GetMethod("/protected/v1/admin/storage", app.requireAdmin(app.getStorageReport)).
+		GetMethod("/protected/v1/reactions", app.getReactionKinds).
+		GetMethod("/protected/v1/posts/{post_id}/reactions", app.getReactors(app.postReactionTarget)).
+		GetMethod("/protected/v1/posts/{post_id}/comments/{comment_id}/reactions", app.getReactors(app.commentReactionTarget)).
+		GetMethod("/protected/v1/private-messages/messages/{message_id}/reactions", app.getReactors(app.messageReactionTarget)).
+		GetMethod("/protected/v1/groups/{group_id}/posts/{post_id}/reactions", app.requireGroupMember(app.getReactors(app.groupPostReactionTarget))).
+		GetMethod("/protected/v1/groups/{group_id}/posts/{post_id}/comments/{comment_id}/reactions", app.requireGroupMember(app.getReactors(app.groupPostCommentReactionTarget))).
+		GetMethod("/protected/v1/groups/{group_id}/messages/{message_id}/reactions", app.requireGroupMember(app.getReactors(app.groupMessageReactionTarget))).
 		PostMethod("/protected/v1/posts", app.createPost).
+		PostMethod("/protected/v1/media", app.uploadMedia).
 		PostMethod("/protected/v1/posts/{post_id}/comments", app.createComment).
+		PostMethod("/protected/v1/posts/{post_id}/reposts", app.createRepost).
+		PostMethod("/protected/v1/posts/{post_id}/poll/votes", app.votePoll(app.postPollTarget)).
+		PostMethod("/protected/v1/groups/{group_id}/posts/{post_id}/poll/votes", app.requireGroupMember(app.votePoll(app.groupPostPollTarget))).
+		PostMethod("/protected/v1/posts/{post_id}/bookmark", app.saveBookmark(app.postBookmarkTarget)).
+		PostMethod("/protected/v1/groups/{group_id}/posts/{post_id}/bookmark", app.requireGroupMember(app.saveBookmark(app.groupPostBookmarkTarget))).
+		PostMethod("/protected/v1/bookmarks/collections", app.createBookmarkCollection).
+		PostMethod("/protected/v1/articles", app.createArticle).
+		PostMethod("/protected/v1/articles/{article_id}/publish", app.publishArticle).
 		PostMethod("/protected/v1/logout", app.logout).
 		PostMethod("/protected/v1/profile/update", app.updateProfile).
 		PostMethod("/protected/v1/notifications/{notification_id}/read", app.markNotificationRead).
 		PostMethod("/protected/v1/users/{user_id}/follow", app.sendFollowRequest).
 		PostMethod("/protected/v1/users/{user_id}/unfollow", app.unfollowUser).
 		PostMethod("/protected/v1/follow-requests/{request_id}", app.respondFollowRequest).
+		PostMethod("/protected/v1/follow-requests/bulk", app.respondFollowRequestsBulk).
 		GetMethod("/protected/v1/follow-requests", app.getPendingFollowRequests).
+		PostMethod("/protected/v1/tags/{tag}/follow", app.followTag).
+		PostMethod("/protected/v1/tags/{tag}/unfollow", app.unfollowTag).
 		PostMethod("/protected/v1/groups/{group_id}/create", app.requireGroupMember(app.groupPostCreate)).
 		PostMethod("/protected/v1/groups/{group_id}/posts/{post_id}/comments", app.requireGroupMember(app.createGroupPostComment)).
 		PostMethod("/protected/v1/groups/{group_id}/events", app.requireGroupOwner(app.createGroupEvent)).
 		PostMethod("/protected/v1/groups/{group_id}/events/{event_id}/rsvp", app.requireGroupMember(app.rsvpGroupEvent)).
 		PostMethod("/protected/v1/groups/{group_id}/join", app.withGroup(app.joinGroupRequest)).
 		PostMethod("/protected/v1/groups/{group_id}/send", app.requireGroupOwner(app.SendGroupInvite)).
-		PostMethod("/protected/v1/groups/{group_id}/requests/{request_id}", app.withGroup(app.respondGroupRequest))
+		PostMethod("/protected/v1/groups/{group_id}/requests/{request_id}", app.withGroup(app.respondGroupRequest)).
+		PostMethod("/protected/v1/posts/{post_id}/reactions", app.toggleReaction(app.postReactionTarget)).
+		PostMethod("/protected/v1/posts/{post_id}/comments/{comment_id}/reactions", app.toggleReaction(app.commentReactionTarget)).
+		PostMethod("/protected/v1/private-messages/messages/{message_id}/reactions", app.toggleReaction(app.messageReactionTarget)).
+		PostMethod("/protected/v1/groups/{group_id}/posts/{post_id}/reactions", app.requireGroupMember(app.toggleReaction(app.groupPostReactionTarget))).
+		PostMethod("/protected/v1/groups/{group_id}/posts/{post_id}/comments/{comment_id}/reactions", app.requireGroupMember(app.toggleReaction(app.groupPostCommentReactionTarget))).
+		PostMethod("/protected/v1/groups/{group_id}/messages/{message_id}/reactions", app.requireGroupMember(app.toggleReaction(app.groupMessageReactionTarget))).
+		PatchMethod("/protected/v1/media/{media_id}", app.editMedia).
+		PatchMethod("/protected/v1/bookmarks/{bookmark_id}", app.editBookmark).
+		PatchMethod("/protected/v1/bookmarks/collections/{collection_id}", app.renameBookmarkCollection).
+		PatchMethod("/protected/v1/articles/{article_id}", app.editArticle).
+		PatchMethod("/protected/v1/posts/{post_id}", app.editPost).
+		PatchMethod("/protected/v1/posts/{post_id}/comments/{comment_id}", app.editComment).
+		PatchMethod("/protected/v1/groups/{group_id}/posts/{post_id}", app.requireGroupMember(app.editGroupPost)).
+		PatchMethod("/protected/v1/groups/{group_id}/posts/{post_id}/comments/{comment_id}", app.requireGroupMember(app.editGroupPostComment)).
+		DeleteMethod("/protected/v1/posts/{post_id}/repost", app.deleteRepost).
+		DeleteMethod("/protected/v1/posts/{post_id}/poll/votes", app.retractPollVote(app.postPollTarget)).
+		DeleteMethod("/protected/v1/groups/{group_id}/posts/{post_id}/poll/votes", app.requireGroupMember(app.retractPollVote(app.groupPostPollTarget))).
+		DeleteMethod("/protected/v1/posts/{post_id}/bookmark", app.removeBookmark(app.postBookmarkTarget)).
+		DeleteMethod("/protected/v1/groups/{group_id}/posts/{post_id}/bookmark", app.requireGroupMember(app.removeBookmark(app.groupPostBookmarkTarget))).
+		DeleteMethod("/protected/v1/bookmarks/{bookmark_id}", app.deleteBookmark).
+		DeleteMethod("/protected/v1/bookmarks/collections/{collection_id}", app.deleteBookmarkCollection).
+		DeleteMethod("/protected/v1/articles/{article_id}", app.deleteArticle).
+		DeleteMethod("/protected/v1/articles/{article_id}/schedule", app.unscheduleArticle).
+		DeleteMethod("/protected/v1/posts/{post_id}", app.deletePost).
+		DeleteMethod("/protected/v1/posts/{post_id}/comments/{comment_id}", app.deleteComment).
+		DeleteMethod("/protected/v1/groups/{group_id}/posts/{post_id}", app.requireGroupMember(app.deleteGroupPost)).
+		DeleteMethod("/protected/v1/groups/{group_id}/posts/{post_id}/comments/{comment_id}", app.requireGroupMember(app.deleteGroupPostComment))
 
 	publicMux, guestMux, protectedMux := registry.GetMuxes()
 
@@ -3,6 +3,10 @@ package api
 import (
 	"brainbook-api/api/websocket"
 	"brainbook-api/internal/database"
+	"brainbook-api/internal/imaging"
+	"brainbook-api/internal/media"
+	"brainbook-api/internal/scan"
+	"brainbook-api/internal/unfurl"
 	"context"
 	"errors"
 	"fmt"
@@ -28,6 +32,30 @@ type Config struct {
 		DSN         string
 		Automigrate bool
 	}
+	Content struct {
+		// RetentionPeriod is how long soft-deleted posts and comments are kept before being purged.
+		// Zero disables purging.
+		RetentionPeriod time.Duration
+		// MaxCommentDepth is the deepest reply level allowed in comment threads; top-level comments are at depth 0.
+		MaxCommentDepth int
+	}
+	Media struct {
+		// Dir is the directory of the media store.
+		Dir string
+		// Types are the kinds of files POST /protected/v1/media accepts, with their size limits.
+		Types *media.Registry
+		// ImageLimits bound the dimensions of uploaded images.
+		ImageLimits imaging.Limits
+		// UserQuota bounds the bytes of the uploads of a user, resized variants included, and
+		// GroupQuota the bytes of the files shared in a group. Zero disables a quota.
+		UserQuota  int64
+		GroupQuota int64
+		// OrphanGracePeriod is how long a file nothing references is kept before being
+		// collected, leaving time to attach new uploads. Zero disables collection.
+		OrphanGracePeriod time.Duration
+	}
+	// AdminIDs are the users allowed to read the reports under /protected/v1/admin.
+	AdminIDs []int
 	// JWT struct {
 	// 	SecretKey string
 	// }
@@ -41,6 +69,17 @@ type Application struct {
 	Logger    *slog.Logger
 	WG        sync.WaitGroup
 	WSManager *websocket.WebsocketManager
+	Media     media.Store
+	// Unfurler builds link previews for posts; nil disables them.
+	Unfurler *unfurl.Unfurler
+	// Scanner checks uploads for malware before other users can see them.
+	Scanner scan.Scanner
+	// articleScheduled wakes the article scheduler when an article is scheduled.
+	articleScheduled chan struct{}
+	// mediaStored wakes the malware scanner when a file is uploaded.
+	mediaStored chan struct{}
+	// quotaLocks serializes the uploads of each user against their storage quota.
+	quotaLocks userLocks
 }
 
 const (
@@ -62,6 +101,11 @@ func (app *Application) ServeHTTP() error {
 
 	shutdownErrorChan := make(chan error)
 
+	// Background jobs stop when the server shuts down
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	app.startJobs(jobsCtx)
+
 	go func() {
 		quitChan := make(chan os.Signal, 1)
 		signal.Notify(quitChan, syscall.SIGINT, syscall.SIGTERM)
@@ -87,6 +131,7 @@ func (app *Application) ServeHTTP() error {
 
 	app.Logger.Info("stopped server", slog.Group("server", "addr", srv.Addr))
 
+	stopJobs()
 	app.WG.Wait()
 	return nil
 }
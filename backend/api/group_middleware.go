@@ -40,19 +40,13 @@ func (app *Application) requireGroupMember(next http.HandlerFunc) http.HandlerFu
 
 		group := contextGetGroup(r)
 
-		// Allow access if user is the group owner
-		if group.OwnerID == user.ID {
-			next(w, r)
-			return
-		}
-
-		// Otherwise, check if user is a member
-		isMember, err := app.DB.IsGroupMember(group.ID, user.ID)
+		// Owners and members may access the group content
+		canAccess, err := app.DB.CanAccessGroup(user.ID, group)
 		if err != nil {
 			app.serverError(w, r, err)
 			return
 		}
-		if !isMember {
+		if !canAccess {
 			app.Unauthorized(w, r)
 			return
 		}
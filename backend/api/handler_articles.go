@@ -0,0 +1,466 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+
+	"brainbook-api/internal/database"
+	"brainbook-api/internal/markdown"
+	"brainbook-api/internal/policy"
+	"brainbook-api/internal/request"
+	"brainbook-api/internal/response"
+	t "brainbook-api/internal/time"
+	"brainbook-api/internal/validator"
+)
+
+// Limits of articles. Bodies are Markdown.
+const (
+	maxArticleTitleRunes   = 200
+	maxArticleSummaryRunes = 500
+	maxArticleBodyRunes    = 100_000
+	// articleWordsPerMinute is the reading speed behind reading-time estimates.
+	articleWordsPerMinute = 200
+)
+
+// validateArticle checks the fields of an article and an optional new cover image, on creation and on edit.
+func validateArticle(v *validator.Validator, fields database.ArticleFields, cover []byte) {
+	v.CheckField(validator.NotBlank(fields.Title), "title", "Title must not be empty")
+	v.CheckField(validator.MaxRunes(fields.Title, maxArticleTitleRunes), "title", fmt.Sprintf("Title must not exceed %d characters", maxArticleTitleRunes))
+	v.CheckField(validator.MaxRunes(fields.Summary, maxArticleSummaryRunes), "summary", fmt.Sprintf("Summary must not exceed %d characters", maxArticleSummaryRunes))
+	v.CheckField(validator.NotBlank(fields.Body), "body", "Body must not be empty")
+	v.CheckField(validator.MaxRunes(fields.Body, maxArticleBodyRunes), "body", fmt.Sprintf("Body must not exceed %d characters", maxArticleBodyRunes))
+	if len(cover) > 0 {
+		v.CheckField(len(cover) <= maxFileBytes, "cover", "Cover image size must be 10MB or less")
+		v.CheckField(isAllowedImage(cover), "cover", "Cover image must be JPEG, PNG, or GIF")
+	}
+}
+
+// readingMinutes estimates the time needed to read body, rounded up to whole minutes.
+func readingMinutes(body string) int {
+	words := len(strings.Fields(body))
+	return max(1, int(math.Ceil(float64(words)/articleWordsPerMinute)))
+}
+
+// renderArticle fills in the fields derived from the article body.
+func renderArticle(fields *database.ArticleFields) {
+	fields.BodyHTML = markdown.Render(fields.Body)
+	fields.ReadingMinutes = readingMinutes(fields.Body)
+}
+
+// loadArticle reads the {article_id} path value and loads the article it names.
+// It writes the error response and returns false unless the authenticated user can view it.
+func (app *Application) loadArticle(w http.ResponseWriter, r *http.Request) (*database.Article, bool) {
+	idStr := r.PathValue("article_id")
+	articleID, err := parseStringID(idStr)
+	if err != nil || articleID <= 0 {
+		app.badRequest(w, r, fmt.Errorf("invalid article ID: %s", idStr))
+		return nil, false
+	}
+
+	article, exists, err := app.DB.ArticleByID(articleID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return nil, false
+	}
+	if !exists {
+		app.notFound(w, r)
+		return nil, false
+	}
+
+	user := contextGetAuthenticatedUser(r)
+	canView, err := app.DB.CanUserViewArticle(user.ID, article)
+	if err != nil {
+		app.serverError(w, r, err)
+		return nil, false
+	}
+	if !canView {
+		app.Unauthorized(w, r)
+		return nil, false
+	}
+
+	return article, true
+}
+
+// loadOwnArticle is loadArticle for actions restricted to the author.
+func (app *Application) loadOwnArticle(w http.ResponseWriter, r *http.Request) (*database.Article, bool) {
+	article, ok := app.loadArticle(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	if article.UserSummary.ID != contextGetAuthenticatedUser(r).ID {
+		app.Unauthorized(w, r)
+		return nil, false
+	}
+
+	return article, true
+}
+
+// respondWithArticle reloads an article after a change and writes it with the given status.
+// The allow list of a limited article is only shown to its author.
+func (app *Application) respondWithArticle(w http.ResponseWriter, r *http.Request, status, articleID int) {
+	article, exists, err := app.DB.ArticleByID(articleID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !exists {
+		app.notFound(w, r)
+		return
+	}
+
+	responseData := map[string]any{"article": article}
+	if article.Visibility == policy.VisibilityLimited && article.UserSummary.ID == contextGetAuthenticatedUser(r).ID {
+		allowed, err := app.DB.ArticleViewers(articleID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		responseData["allowed_user_ids"] = allowed
+	}
+
+	if err := response.JSON(w, status, responseData); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// createArticle handles POST /protected/v1/articles
+// Articles are saved as drafts unless publish is true, or publish_at schedules their publication.
+func (app *Application) createArticle(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Title          string              `json:"title"`
+		Summary        string              `json:"summary"`
+		Body           string              `json:"body"`
+		Cover          []byte              `json:"cover"`
+		CoverID        *int                `json:"cover_id"`
+		Visibility     string              `json:"visibility"`
+		AllowedUserIDs []int               `json:"allowed_user_ids"`
+		Publish        bool                `json:"publish"`
+		PublishAt      string              `json:"publish_at"`
+		Validator      validator.Validator `json:"-"`
+	}
+
+	if err := request.DecodeJSON(w, r, &input); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	user := contextGetAuthenticatedUser(r)
+
+	fields := database.ArticleFields{
+		Title:   strings.TrimSpace(input.Title),
+		Summary: strings.TrimSpace(input.Summary),
+		Body:    input.Body,
+	}
+	validateArticle(&input.Validator, fields, input.Cover)
+	if err := app.checkUpload(&input.Validator, "cover_id", input.CoverID, input.Cover, user.ID); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	visibility, err := app.checkVisibility(&input.Validator, input.Visibility, input.AllowedUserIDs, user.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	fields.Visibility = visibility
+
+	var publishAt string
+	if input.PublishAt != "" {
+		input.Validator.CheckField(!input.Publish, "publish_at", "Publish now or schedule a publish time, not both")
+		publishAt = checkFutureTime(&input.Validator, "publish_at", "Publish time", input.PublishAt)
+	}
+
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	renderArticle(&fields)
+	currentDateTime := t.CurrentTime()
+
+	fields.CoverID, err = app.attachMedia(user.ID, input.CoverID, input.Cover)
+	if err != nil {
+		app.mediaError(w, r, "cover", err)
+		return
+	}
+
+	articleID, err := app.DB.InsertArticle(user.ID, fields, currentDateTime)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if visibility == policy.VisibilityLimited {
+		if err := app.DB.SetArticleViewers(articleID, input.AllowedUserIDs); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	switch {
+	case input.Publish:
+		err = app.DB.PublishArticle(articleID, currentDateTime)
+	case publishAt != "":
+		err = app.DB.ScheduleArticle(articleID, publishAt, currentDateTime)
+		app.wakeArticleScheduler()
+	}
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.respondWithArticle(w, r, http.StatusCreated, articleID)
+}
+
+// getArticle handles GET /protected/v1/articles/{article_id}
+func (app *Application) getArticle(w http.ResponseWriter, r *http.Request) {
+	article, ok := app.loadArticle(w, r)
+	if !ok {
+		return
+	}
+
+	app.respondWithArticle(w, r, http.StatusOK, article.ID)
+}
+
+// getArticles handles GET /protected/v1/articles
+// It lists the published articles the user can view, newest first, without their bodies.
+// Query parameters: author_id (optional), limit (1-100, default 20) and offset.
+func (app *Application) getArticles(w http.ResponseWriter, r *http.Request) {
+	limit, offset, ok := app.parsePage(w, r)
+	if !ok {
+		return
+	}
+
+	authorID := parseQueryInt(r, "author_id", 0)
+	user := contextGetAuthenticatedUser(r)
+
+	articles, err := app.DB.ArticlesVisibleTo(user.ID, authorID, limit+1, offset)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.respondWithArticlePage(w, r, articles, limit, offset)
+}
+
+// getArticleDrafts handles GET /protected/v1/articles/drafts
+// It lists the draft and scheduled articles of the user, most recently updated first.
+func (app *Application) getArticleDrafts(w http.ResponseWriter, r *http.Request) {
+	limit, offset, ok := app.parsePage(w, r)
+	if !ok {
+		return
+	}
+
+	user := contextGetAuthenticatedUser(r)
+
+	articles, err := app.DB.ArticleDrafts(user.ID, limit+1, offset)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.respondWithArticlePage(w, r, articles, limit, offset)
+}
+
+// respondWithArticlePage writes a page of articles fetched with one extra row to detect more.
+func (app *Application) respondWithArticlePage(w http.ResponseWriter, r *http.Request, articles []database.Article, limit, offset int) {
+	hasMore := len(articles) > limit
+	if hasMore {
+		articles = articles[:limit]
+	}
+	if articles == nil {
+		articles = []database.Article{}
+	}
+
+	responseData := map[string]any{
+		"articles": articles,
+		"has_more": hasMore,
+	}
+	if hasMore {
+		responseData["next_offset"] = offset + limit
+	}
+
+	if err := response.JSON(w, http.StatusOK, responseData); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// editArticle handles PATCH /protected/v1/articles/{article_id}
+// Omitted fields are kept. allowed_user_ids is read along with visibility only.
+func (app *Application) editArticle(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Title          *string             `json:"title"`
+		Summary        *string             `json:"summary"`
+		Body           *string             `json:"body"`
+		Cover          []byte              `json:"cover"`
+		CoverID        *int                `json:"cover_id"`
+		RemoveCover    bool                `json:"remove_cover"`
+		Visibility     *string             `json:"visibility"`
+		AllowedUserIDs []int               `json:"allowed_user_ids"`
+		Validator      validator.Validator `json:"-"`
+	}
+
+	if err := request.DecodeJSON(w, r, &input); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	article, ok := app.loadOwnArticle(w, r)
+	if !ok {
+		return
+	}
+
+	fields := database.ArticleFields{
+		Title:      article.Title,
+		Summary:    article.Summary,
+		Body:       article.Body,
+		CoverID:    article.CoverID,
+		Visibility: article.Visibility,
+	}
+	if input.Title != nil {
+		fields.Title = strings.TrimSpace(*input.Title)
+	}
+	if input.Summary != nil {
+		fields.Summary = strings.TrimSpace(*input.Summary)
+	}
+	if input.Body != nil {
+		fields.Body = *input.Body
+	}
+	if input.RemoveCover {
+		fields.CoverID = nil
+	}
+	input.Validator.CheckField(!(input.RemoveCover && (len(input.Cover) > 0 || input.CoverID != nil)), "cover", "Provide a new cover or remove it, not both")
+	validateArticle(&input.Validator, fields, input.Cover)
+	if err := app.checkUpload(&input.Validator, "cover_id", input.CoverID, input.Cover, article.UserSummary.ID); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if input.Visibility != nil {
+		visibility, err := app.checkVisibility(&input.Validator, *input.Visibility, input.AllowedUserIDs, article.UserSummary.ID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		fields.Visibility = visibility
+	}
+
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	renderArticle(&fields)
+
+	if len(input.Cover) > 0 || input.CoverID != nil {
+		coverID, err := app.attachMedia(article.UserSummary.ID, input.CoverID, input.Cover)
+		if err != nil {
+			app.mediaError(w, r, "cover", err)
+			return
+		}
+		fields.CoverID = coverID
+	}
+
+	if err := app.DB.UpdateArticle(article.ID, fields, t.CurrentTime()); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if input.Visibility != nil {
+		var allowed []int
+		if fields.Visibility == policy.VisibilityLimited {
+			allowed = input.AllowedUserIDs
+		}
+		if err := app.DB.SetArticleViewers(article.ID, allowed); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	app.respondWithArticle(w, r, http.StatusOK, article.ID)
+}
+
+// publishArticle handles POST /protected/v1/articles/{article_id}/publish
+// The optional publish_at schedules the publication instead, or moves an existing schedule.
+func (app *Application) publishArticle(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		PublishAt string              `json:"publish_at"`
+		Validator validator.Validator `json:"-"`
+	}
+
+	if r.ContentLength != 0 {
+		if err := request.DecodeJSON(w, r, &input); err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+	}
+
+	article, ok := app.loadOwnArticle(w, r)
+	if !ok {
+		return
+	}
+
+	var publishAt string
+	if input.PublishAt != "" {
+		input.Validator.CheckField(article.Status != policy.ArticlePublished, "publish_at", "Published articles cannot be scheduled")
+		publishAt = checkFutureTime(&input.Validator, "publish_at", "Publish time", input.PublishAt)
+	}
+
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	var err error
+	if publishAt != "" {
+		err = app.DB.ScheduleArticle(article.ID, publishAt, t.CurrentTime())
+		app.wakeArticleScheduler()
+	} else {
+		err = app.DB.PublishArticle(article.ID, t.CurrentTime())
+	}
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.respondWithArticle(w, r, http.StatusOK, article.ID)
+}
+
+// unscheduleArticle handles DELETE /protected/v1/articles/{article_id}/schedule
+// The article goes back to being a draft.
+func (app *Application) unscheduleArticle(w http.ResponseWriter, r *http.Request) {
+	article, ok := app.loadOwnArticle(w, r)
+	if !ok {
+		return
+	}
+
+	if article.Status != policy.ArticleScheduled {
+		app.badRequest(w, r, fmt.Errorf("article %d is not scheduled", article.ID))
+		return
+	}
+
+	if err := app.DB.UnscheduleArticle(article.ID, t.CurrentTime()); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.respondWithArticle(w, r, http.StatusOK, article.ID)
+}
+
+// deleteArticle handles DELETE /protected/v1/articles/{article_id}
+func (app *Application) deleteArticle(w http.ResponseWriter, r *http.Request) {
+	article, ok := app.loadOwnArticle(w, r)
+	if !ok {
+		return
+	}
+
+	if err := app.DB.DeleteArticle(article.ID); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,420 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"brainbook-api/internal/database"
+	"brainbook-api/internal/request"
+	"brainbook-api/internal/response"
+	t "brainbook-api/internal/time"
+	"brainbook-api/internal/validator"
+)
+
+const (
+	maxBookmarkNoteRunes   = 1000
+	maxCollectionNameRunes = 100
+)
+
+// bookmarkTargetResolver loads the post or group post addressed by a bookmark route and checks
+// that the authenticated user can see it. It writes the error response and returns false otherwise.
+type bookmarkTargetResolver func(w http.ResponseWriter, r *http.Request) (targetType string, targetID int, ok bool)
+
+// postBookmarkTarget resolves /protected/v1/posts/{post_id}/bookmark
+func (app *Application) postBookmarkTarget(w http.ResponseWriter, r *http.Request) (string, int, bool) {
+	postID, _, ok := app.loadEditableContent(w, r, database.ContentPost, "post_id", 0)
+	if !ok || !app.requirePostViewer(w, r, postID) {
+		return "", 0, false
+	}
+	return database.ContentPost, postID, true
+}
+
+// groupPostBookmarkTarget resolves /protected/v1/groups/{group_id}/posts/{post_id}/bookmark
+func (app *Application) groupPostBookmarkTarget(w http.ResponseWriter, r *http.Request) (string, int, bool) {
+	group := contextGetGroup(r)
+
+	postID, _, ok := app.loadEditableContent(w, r, database.ContentGroupPost, "post_id", group.ID)
+	if !ok {
+		return "", 0, false
+	}
+	return database.ContentGroupPost, postID, true
+}
+
+// checkBookmarkFields validates the note and collection of a bookmark. A collectionID of 0
+// leaves the bookmark unfiled; the returned collection is nil then.
+func (app *Application) checkBookmarkFields(v *validator.Validator, userID int, collectionID *int, note string) (*int, error) {
+	v.CheckField(validator.MaxRunes(note, maxBookmarkNoteRunes), "note", fmt.Sprintf("Note must not exceed %d characters", maxBookmarkNoteRunes))
+
+	if collectionID == nil || *collectionID == 0 {
+		return nil, nil
+	}
+
+	exists, err := app.DB.BookmarkCollectionExists(userID, *collectionID)
+	if err != nil {
+		return nil, err
+	}
+	v.CheckField(exists, "collection_id", "Collection not found")
+
+	return collectionID, nil
+}
+
+// saveBookmark returns the handler bookmarking a post or group post, or updating the collection
+// and note of an existing bookmark.
+func (app *Application) saveBookmark(resolve bookmarkTargetResolver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var input struct {
+			CollectionID *int                `json:"collection_id"`
+			Note         string              `json:"note"`
+			Validator    validator.Validator `json:"-"`
+		}
+
+		if r.ContentLength != 0 {
+			if err := request.DecodeJSON(w, r, &input); err != nil {
+				app.badRequest(w, r, err)
+				return
+			}
+		}
+
+		targetType, targetID, ok := resolve(w, r)
+		if !ok {
+			return
+		}
+
+		user := contextGetAuthenticatedUser(r)
+		note := strings.TrimSpace(input.Note)
+
+		collectionID, err := app.checkBookmarkFields(&input.Validator, user.ID, input.CollectionID, note)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		if input.Validator.HasErrors() {
+			app.failedValidation(w, r, input.Validator)
+			return
+		}
+
+		bookmarkID, err := app.DB.SaveBookmark(user.ID, targetType, targetID, collectionID, note, t.CurrentTime())
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		responseData := map[string]any{
+			"bookmark_id":   bookmarkID,
+			"target_type":   targetType,
+			"target_id":     targetID,
+			"collection_id": collectionID,
+			"note":          note,
+		}
+
+		if err := response.JSON(w, http.StatusOK, responseData); err != nil {
+			app.serverError(w, r, err)
+		}
+	}
+}
+
+// removeBookmark returns the handler removing the bookmark of the authenticated user on a post or group post.
+func (app *Application) removeBookmark(resolve bookmarkTargetResolver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		targetType, targetID, ok := resolve(w, r)
+		if !ok {
+			return
+		}
+
+		user := contextGetAuthenticatedUser(r)
+		if err := app.DB.DeleteBookmarkOf(user.ID, targetType, targetID); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// getBookmarks handles GET /protected/v1/bookmarks
+// Query parameters: collection_id (optional), limit (1-100, default 20) and offset.
+// Bookmarked content the user can no longer see is left out.
+func (app *Application) getBookmarks(w http.ResponseWriter, r *http.Request) {
+	limit, offset, ok := app.parsePage(w, r)
+	if !ok {
+		return
+	}
+
+	user := contextGetAuthenticatedUser(r)
+	collectionID := parseQueryInt(r, "collection_id", 0)
+
+	bookmarks, err := app.DB.BookmarksForUser(user.ID, collectionID, limit+1, offset)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	hasMore := len(bookmarks) > limit
+	if hasMore {
+		bookmarks = bookmarks[:limit]
+	}
+	if bookmarks == nil {
+		bookmarks = []database.Bookmark{}
+	}
+
+	responseData := map[string]any{
+		"bookmarks": bookmarks,
+		"has_more":  hasMore,
+	}
+	if hasMore {
+		responseData["next_offset"] = offset + limit
+	}
+
+	if err := response.JSON(w, http.StatusOK, responseData); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// loadOwnBookmark reads the {bookmark_id} path value and loads that bookmark of the authenticated user.
+func (app *Application) loadOwnBookmark(w http.ResponseWriter, r *http.Request) (*database.Bookmark, bool) {
+	idStr := r.PathValue("bookmark_id")
+	bookmarkID, err := parseStringID(idStr)
+	if err != nil || bookmarkID <= 0 {
+		app.badRequest(w, r, fmt.Errorf("invalid bookmark ID: %s", idStr))
+		return nil, false
+	}
+
+	user := contextGetAuthenticatedUser(r)
+	bookmark, exists, err := app.DB.BookmarkByID(user.ID, bookmarkID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return nil, false
+	}
+	if !exists {
+		app.notFound(w, r)
+		return nil, false
+	}
+
+	return bookmark, true
+}
+
+// editBookmark handles PATCH /protected/v1/bookmarks/{bookmark_id}
+// Omitted fields are kept; a collection_id of 0 unfiles the bookmark.
+func (app *Application) editBookmark(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		CollectionID *int                `json:"collection_id"`
+		Note         *string             `json:"note"`
+		Validator    validator.Validator `json:"-"`
+	}
+
+	if err := request.DecodeJSON(w, r, &input); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	bookmark, ok := app.loadOwnBookmark(w, r)
+	if !ok {
+		return
+	}
+
+	note := bookmark.Note
+	if input.Note != nil {
+		note = strings.TrimSpace(*input.Note)
+	}
+	collectionID := bookmark.CollectionID
+	if input.CollectionID != nil {
+		collectionID = input.CollectionID
+	}
+
+	user := contextGetAuthenticatedUser(r)
+	collectionID, err := app.checkBookmarkFields(&input.Validator, user.ID, collectionID, note)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	if err := app.DB.UpdateBookmark(bookmark.ID, collectionID, note); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	responseData := map[string]any{
+		"bookmark_id":   bookmark.ID,
+		"target_type":   bookmark.TargetType,
+		"target_id":     bookmark.TargetID,
+		"collection_id": collectionID,
+		"note":          note,
+	}
+
+	if err := response.JSON(w, http.StatusOK, responseData); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// deleteBookmark handles DELETE /protected/v1/bookmarks/{bookmark_id}
+func (app *Application) deleteBookmark(w http.ResponseWriter, r *http.Request) {
+	bookmark, ok := app.loadOwnBookmark(w, r)
+	if !ok {
+		return
+	}
+
+	if err := app.DB.DeleteBookmark(bookmark.ID); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getBookmarkCollections handles GET /protected/v1/bookmarks/collections
+func (app *Application) getBookmarkCollections(w http.ResponseWriter, r *http.Request) {
+	user := contextGetAuthenticatedUser(r)
+
+	collections, err := app.DB.BookmarkCollections(user.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if collections == nil {
+		collections = []database.BookmarkCollection{}
+	}
+
+	if err := response.JSON(w, http.StatusOK, map[string]any{"collections": collections}); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// checkCollectionName validates the name of a collection of userID. exceptID is the collection
+// being renamed, 0 otherwise.
+func (app *Application) checkCollectionName(v *validator.Validator, userID int, name string, exceptID int) error {
+	v.CheckField(validator.NotBlank(name), "name", "Name must not be empty")
+	v.CheckField(validator.MaxRunes(name, maxCollectionNameRunes), "name", fmt.Sprintf("Name must not exceed %d characters", maxCollectionNameRunes))
+	if v.HasErrors() {
+		return nil
+	}
+
+	taken, err := app.DB.BookmarkCollectionNameTaken(userID, name, exceptID)
+	if err != nil {
+		return err
+	}
+	v.CheckField(!taken, "name", "You already have a collection with this name")
+
+	return nil
+}
+
+// createBookmarkCollection handles POST /protected/v1/bookmarks/collections
+func (app *Application) createBookmarkCollection(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name      string              `json:"name"`
+		Validator validator.Validator `json:"-"`
+	}
+
+	if err := request.DecodeJSON(w, r, &input); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	user := contextGetAuthenticatedUser(r)
+	name := strings.TrimSpace(input.Name)
+
+	if err := app.checkCollectionName(&input.Validator, user.ID, name, 0); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	collectionID, err := app.DB.InsertBookmarkCollection(user.ID, name, t.CurrentTime())
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if err := response.JSON(w, http.StatusCreated, map[string]any{"collection_id": collectionID, "name": name}); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// loadOwnCollection reads the {collection_id} path value and checks that the authenticated user owns it.
+func (app *Application) loadOwnCollection(w http.ResponseWriter, r *http.Request) (int, bool) {
+	idStr := r.PathValue("collection_id")
+	collectionID, err := parseStringID(idStr)
+	if err != nil || collectionID <= 0 {
+		app.badRequest(w, r, fmt.Errorf("invalid collection ID: %s", idStr))
+		return 0, false
+	}
+
+	user := contextGetAuthenticatedUser(r)
+	exists, err := app.DB.BookmarkCollectionExists(user.ID, collectionID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return 0, false
+	}
+	if !exists {
+		app.notFound(w, r)
+		return 0, false
+	}
+
+	return collectionID, true
+}
+
+// renameBookmarkCollection handles PATCH /protected/v1/bookmarks/collections/{collection_id}
+func (app *Application) renameBookmarkCollection(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name      string              `json:"name"`
+		Validator validator.Validator `json:"-"`
+	}
+
+	if err := request.DecodeJSON(w, r, &input); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	collectionID, ok := app.loadOwnCollection(w, r)
+	if !ok {
+		return
+	}
+
+	user := contextGetAuthenticatedUser(r)
+	name := strings.TrimSpace(input.Name)
+
+	if err := app.checkCollectionName(&input.Validator, user.ID, name, collectionID); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	if err := app.DB.RenameBookmarkCollection(collectionID, name); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if err := response.JSON(w, http.StatusOK, map[string]any{"collection_id": collectionID, "name": name}); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// deleteBookmarkCollection handles DELETE /protected/v1/bookmarks/collections/{collection_id}
+// The bookmarks filed in it are kept, unfiled.
+func (app *Application) deleteBookmarkCollection(w http.ResponseWriter, r *http.Request) {
+	collectionID, ok := app.loadOwnCollection(w, r)
+	if !ok {
+		return
+	}
+
+	if err := app.DB.DeleteBookmarkCollection(collectionID); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
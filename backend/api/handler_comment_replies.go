@@ -0,0 +1,162 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"brainbook-api/internal/database"
+	"brainbook-api/internal/response"
+	"brainbook-api/internal/validator"
+)
+
+// checkReplyParent validates the parent_id of a new comment on postID. The parent must be a
+// live comment of the same post, shallow enough for a reply to stay within the configured depth.
+// It returns the parent comment, or nil for top-level comments; false means the response is written.
+func (app *Application) checkReplyParent(w http.ResponseWriter, r *http.Request, v *validator.Validator, targetType string, parentID *int, postID int) (*database.EditableContent, bool) {
+	if parentID == nil {
+		return nil, true
+	}
+
+	parent, exists, err := app.DB.EditableContentByID(targetType, *parentID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return nil, false
+	}
+	if !exists || parent.ParentID != postID {
+		v.AddFieldError("parent_id", "Parent comment does not exist on this post")
+		app.failedValidation(w, r, *v)
+		return nil, false
+	}
+
+	depth, err := app.DB.CommentDepth(targetType, *parentID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return nil, false
+	}
+	if depth+1 > app.Config.Content.MaxCommentDepth {
+		v.AddFieldError("parent_id", fmt.Sprintf("Replies cannot be nested more than %d levels deep", app.Config.Content.MaxCommentDepth))
+		app.failedValidation(w, r, *v)
+		return nil, false
+	}
+
+	return parent, true
+}
+
+// parsePage reads the limit (1-100, default 20) and offset query parameters of paginated listings.
+func (app *Application) parsePage(w http.ResponseWriter, r *http.Request) (int, int, bool) {
+	limit := parseQueryInt(r, "limit", 20)
+	if limit < 1 || limit > 100 {
+		app.badRequest(w, r, fmt.Errorf("limit must be between 1 and 100"))
+		return 0, 0, false
+	}
+
+	offset := parseQueryInt(r, "offset", 0)
+	if offset < 0 {
+		app.badRequest(w, r, fmt.Errorf("offset must not be negative"))
+		return 0, 0, false
+	}
+
+	return limit, offset, true
+}
+
+// getCommentReplies handles GET /protected/v1/posts/{post_id}/comments/{comment_id}/replies
+// Replies share the visibility of their post, which is checked before listing any level.
+func (app *Application) getCommentReplies(w http.ResponseWriter, r *http.Request) {
+	limit, offset, ok := app.parsePage(w, r)
+	if !ok {
+		return
+	}
+
+	postID, err := parseStringID(r.PathValue("post_id"))
+	if err != nil || postID <= 0 {
+		app.badRequest(w, r, fmt.Errorf("invalid post ID: %s", r.PathValue("post_id")))
+		return
+	}
+
+	commentID, err := parseStringID(r.PathValue("comment_id"))
+	if err != nil || commentID <= 0 {
+		app.badRequest(w, r, fmt.Errorf("invalid comment ID: %s", r.PathValue("comment_id")))
+		return
+	}
+
+	if !app.requirePostViewer(w, r, postID) {
+		return
+	}
+
+	viewer := contextGetAuthenticatedUser(r)
+	replies, err := app.DB.CommentReplies(viewer.ID, postID, commentID, limit+1, offset)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	hasMore := len(replies) > limit
+	if hasMore {
+		replies = replies[:limit]
+	}
+
+	replyMaps := []map[string]any{}
+	for _, reply := range replies {
+		replyMaps = append(replyMaps, commentResponse(reply))
+	}
+
+	responseData := map[string]any{
+		"replies":  replyMaps,
+		"has_more": hasMore,
+	}
+	if hasMore {
+		responseData["next_offset"] = offset + limit
+	}
+
+	if err := response.JSON(w, http.StatusOK, responseData); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// getGroupPostCommentReplies handles GET /protected/v1/groups/{group_id}/posts/{post_id}/comments/{comment_id}/replies
+func (app *Application) getGroupPostCommentReplies(w http.ResponseWriter, r *http.Request) {
+	limit, offset, ok := app.parsePage(w, r)
+	if !ok {
+		return
+	}
+
+	group := contextGetGroup(r)
+
+	postID, _, ok := app.loadEditableContent(w, r, database.ContentGroupPost, "post_id", group.ID)
+	if !ok {
+		return
+	}
+
+	commentID, err := parseStringID(r.PathValue("comment_id"))
+	if err != nil || commentID <= 0 {
+		app.badRequest(w, r, fmt.Errorf("invalid comment ID: %s", r.PathValue("comment_id")))
+		return
+	}
+
+	user := contextGetAuthenticatedUser(r)
+	replies, err := app.DB.GroupPostCommentReplies(user.ID, postID, commentID, limit+1, offset)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	hasMore := len(replies) > limit
+	if hasMore {
+		replies = replies[:limit]
+	}
+	if replies == nil {
+		replies = []database.GroupPostComment{}
+	}
+
+	responseData := map[string]any{
+		"replies":  replies,
+		"has_more": hasMore,
+	}
+	if hasMore {
+		responseData["next_offset"] = offset + limit
+	}
+
+	if err := response.JSON(w, http.StatusOK, responseData); err != nil {
+		app.serverError(w, r, err)
+	}
+}
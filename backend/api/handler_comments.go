@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 
+	"brainbook-api/internal/database"
 	"brainbook-api/internal/response"
 )
 
@@ -31,7 +32,7 @@ func (app *Application) getPostComments(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	comments, err := app.DB.CommentsForPost(postID)
+	comments, err := app.DB.CommentsForPost(viewer.ID, postID)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
@@ -41,16 +42,7 @@ func (app *Application) getPostComments(w http.ResponseWriter, r *http.Request)
 	var commentsWithFullName []map[string]any
 
 	for _, comment := range comments {
-		commentsWithFullName = append(commentsWithFullName, map[string]any{
-			"id":             comment.ID,
-			"user_id":        comment.UserSummary.ID,
-			"user_full_name": comment.FullName(),
-			"user_avatar":    comment.Avatar,
-			"content":        comment.Content,
-			"file":           comment.File,
-			// AI suggets .UTC().Format(time.RFC3339). Not sure what difference it makes.
-			"created_at": comment.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-		})
+		commentsWithFullName = append(commentsWithFullName, commentResponse(comment))
 	}
 
 	responseData := map[string]interface{}{
@@ -63,3 +55,37 @@ func (app *Application) getPostComments(w http.ResponseWriter, r *http.Request)
 		return
 	}
 }
+
+// commentResponse shapes a post comment with the full name of its author.
+func commentResponse(comment database.Comment) map[string]any {
+	resp := map[string]any{
+		"id":                   comment.ID,
+		"user_id":              comment.UserSummary.ID,
+		"user_full_name":       comment.FullName(),
+		"user_avatar_id":       comment.AvatarID,
+		"user_avatar_alt_text": comment.AvatarAltText,
+		"content":              comment.Content,
+		"media_id":             comment.MediaID,
+		"media_alt_text":       comment.MediaAltText,
+		// AI suggets .UTC().Format(time.RFC3339). Not sure what difference it makes.
+		"created_at": comment.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+		"edited_at":  comment.EditedAt,
+		// Set on tombstones of deleted comments
+		"deleted_at":   comment.DeletedAt,
+		"parent_id":    comment.ParentID,
+		"depth":        comment.Depth,
+		"reply_count":  comment.ReplyCount,
+		"reactions":    comment.Reactions,
+		"my_reactions": comment.MyReactions,
+		"mentions":     comment.Mentions,
+	}
+
+	// Tombstones do not reveal who wrote the deleted comment, what it referenced or how others
+	// reacted to it
+	if comment.DeletedAt != nil {
+		for _, key := range []string{"user_id", "user_full_name", "user_avatar_id", "user_avatar_alt_text", "media_id", "media_alt_text", "reactions", "my_reactions", "mentions"} {
+			resp[key] = nil
+		}
+	}
+	return resp
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 
+	"brainbook-api/internal/database"
 	"brainbook-api/internal/request"
 	"brainbook-api/internal/response"
 	time "brainbook-api/internal/time"
@@ -14,6 +15,8 @@ func (app *Application) createComment(w http.ResponseWriter, r *http.Request) {
 	var input struct {
 		Content   string              `json:"content"`
 		File      []byte              `json:"file"`
+		MediaID   *int                `json:"media_id"`
+		ParentID  *int                `json:"parent_id"`
 		Validator validator.Validator `json:"-"`
 	}
 
@@ -32,11 +35,10 @@ func (app *Application) createComment(w http.ResponseWriter, r *http.Request) {
 
 	user := contextGetAuthenticatedUser(r)
 
-	input.Validator.CheckField(validator.NotBlank(input.Content), "content", "Content must not be empty")
-	input.Validator.CheckField(validator.MaxRunes(input.Content, 500), "content", "Content must not exceed 500 characters")
-	if len(input.File) > 0 {
-		input.Validator.CheckField(len(input.File) <= 10_000_000, "file", "File size must be 10MB or less")
-		input.Validator.CheckField(isAllowedImage(input.File), "file", "File must be JPEG, PNG, or GIF")
+	validateContent(&input.Validator, "content", input.Content, input.File)
+	if err := app.checkUpload(&input.Validator, "media_id", input.MediaID, input.File, user.ID); err != nil {
+		app.serverError(w, r, err)
+		return
 	}
 
 	if input.Validator.HasErrors() {
@@ -55,22 +57,58 @@ func (app *Application) createComment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	parent, ok := app.checkReplyParent(w, r, &input.Validator, database.ContentComment, input.ParentID, postID)
+	if !ok {
+		return
+	}
+
 	currentDateTime := time.CurrentTime()
 
+	mediaID, err := app.attachMedia(user.ID, input.MediaID, input.File)
+	if err != nil {
+		app.mediaError(w, r, "file", err)
+		return
+	}
+
 	// Insert the comment into the database
-	commentID, err := app.DB.InsertComment(postID, user.ID, input.Content, input.File, currentDateTime)
+	commentID, err := app.DB.InsertComment(postID, user.ID, input.Content, mediaID, currentDateTime, input.ParentID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	mentionPayload := map[string]interface{}{"post_id": postID, "comment_id": commentID}
+	if err := app.indexMentions(database.ContentComment, commentID, input.Content, user, mentionPayload, app.postViewers(postID)); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if parent != nil && parent.AuthorID != user.ID {
+		app.notifyUser(parent.AuthorID, NotificationTypeCommentReply, map[string]interface{}{
+			"post_id":           postID,
+			"comment_id":        commentID,
+			"parent_comment_id": *input.ParentID,
+			"user_id":           user.ID,
+			"user_full_name":    user.FullName(),
+		})
+	}
+
+	mediaAltText, err := app.mediaAltText(mediaID)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
 	responseData := map[string]any{
-		"comment_id":     commentID,
-		"user_full_name": user.FullName(),
-		"user_avatar":    user.Avatar,
-		"content":        input.Content,
-		"file":           input.File,
-		"created_at":     currentDateTime,
+		"comment_id":           commentID,
+		"user_full_name":       user.FullName(),
+		"user_avatar_id":       user.AvatarID,
+		"user_avatar_alt_text": user.AvatarAltText,
+		"content":              input.Content,
+		"media_id":             mediaID,
+		"media_alt_text":       mediaAltText,
+		"created_at":           currentDateTime,
+		"parent_id":            input.ParentID,
 	}
 
 	err = response.JSON(w, http.StatusCreated, responseData)
@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"strings"
 
+	"brainbook-api/internal/database"
+	"brainbook-api/internal/hashtag"
 	"brainbook-api/internal/request"
 	"brainbook-api/internal/response"
 	t "brainbook-api/internal/time"
@@ -15,9 +17,13 @@ func (app *Application) createPost(w http.ResponseWriter, r *http.Request) {
 	// Define the input structure to decode JSON
 	var input struct {
 		Content        string              `json:"content"`
+		ContentFormat  string              `json:"content_format"`
 		File           []byte              `json:"file"`
+		MediaID        *int                `json:"media_id"`
+		AttachmentIDs  []int               `json:"attachment_ids"`
 		Visibility     string              `json:"visibility"`
 		AllowedUserIDs []int               `json:"allowed_user_ids"`
+		Poll           *pollInput          `json:"poll"`
 		Validator      validator.Validator `json:"-"`
 	}
 
@@ -31,51 +37,25 @@ func (app *Application) createPost(w http.ResponseWriter, r *http.Request) {
 	// Get the authenticated user from context
 	user := contextGetAuthenticatedUser(r)
 
-	input.Validator.CheckField(validator.NotBlank(input.Content), "post-content", "Content must not be empty")
-	input.Validator.CheckField(validator.MaxRunes(input.Content, 500), "post-content", "Content must not exceed 500 characters")
-	if len(input.File) > 0 {
-		input.Validator.CheckField(len(input.File) <= 10_000_000, "file", "File size must be 10MB or less")
-		input.Validator.CheckField(isAllowedImage(input.File), "file", "File must be JPEG, PNG, or GIF")
+	format := strings.ToLower(strings.TrimSpace(input.ContentFormat))
+	if format == "" {
+		format = database.FormatPlain
 	}
-
-	visibility := strings.ToLower(strings.TrimSpace(input.Visibility))
-	if visibility == "" {
-		visibility = "public"
+	validatePostContent(&input.Validator, "post-content", input.Content, format, input.File)
+	poll := checkPoll(&input.Validator, input.Poll)
+	if err := app.checkUpload(&input.Validator, "media_id", input.MediaID, input.File, user.ID); err != nil {
+		app.serverError(w, r, err)
+		return
 	}
-
-	var dbVisibility string
-	switch visibility {
-	case "public":
-		dbVisibility = "public"
-	case "almost_private", "followers", "followers_only":
-		dbVisibility = "private" // followers-only
-	case "private":
-		dbVisibility = "limited" // selected followers
-	default:
-		input.Validator.AddFieldError("visibility", "Visibility must be one of public, almost_private, or private")
+	if err := app.checkAttachments(&input.Validator, input.AttachmentIDs, user.ID); err != nil {
+		app.serverError(w, r, err)
+		return
 	}
 
-	// Validate allow-list when using selected followers privacy.
-	if dbVisibility == "limited" {
-		if len(input.AllowedUserIDs) == 0 {
-			input.Validator.AddFieldError("allowed_user_ids", "Provide at least one allowed follower for private posts")
-		} else {
-			for _, uid := range input.AllowedUserIDs {
-				if uid <= 0 {
-					input.Validator.AddFieldError("allowed_user_ids", "All allowed user IDs must be positive")
-					break
-				}
-				isFollower, err := app.DB.IsFollowing(uid, user.ID)
-				if err != nil {
-					app.serverError(w, r, err)
-					return
-				}
-				if !isFollower {
-					input.Validator.AddFieldError("allowed_user_ids", fmt.Sprintf("User %d is not a follower", uid))
-					break
-				}
-			}
-		}
+	dbVisibility, err := app.checkVisibility(&input.Validator, input.Visibility, input.AllowedUserIDs, user.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
 	}
 
 	if input.Validator.HasErrors() {
@@ -85,8 +65,15 @@ func (app *Application) createPost(w http.ResponseWriter, r *http.Request) {
 
 	currentDateTime := t.CurrentTime()
 
+	mediaID, err := app.attachMedia(user.ID, input.MediaID, input.File)
+	if err != nil {
+		app.mediaError(w, r, "file", err)
+		return
+	}
+
 	// Insert the post into the database
-	postID, err := app.DB.InsertPost(user.ID, input.Content, input.File, dbVisibility, currentDateTime)
+	contentHTML := renderContent(format, input.Content)
+	postID, err := app.DB.InsertPost(user.ID, input.Content, format, contentHTML, mediaID, dbVisibility, currentDateTime)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
@@ -100,15 +87,58 @@ func (app *Application) createPost(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	if err := app.DB.InsertAttachments(database.ContentPost, postID, input.AttachmentIDs); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	var pollID *int
+	if poll != nil {
+		id, err := app.DB.InsertPoll(database.ContentPost, postID, *poll, currentDateTime)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		pollID = &id
+	}
+
+	if err := app.DB.IndexPostTags(postID, hashtag.Parse(input.Content)); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	mentionPayload := map[string]interface{}{"post_id": postID}
+	if err := app.indexMentions(database.ContentPost, postID, input.Content, user, mentionPayload, app.postViewers(postID)); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if err := app.refreshLinkPreview(r, postID, input.Content); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	mediaAltText, err := app.mediaAltText(mediaID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
 	// Respond with the created post
 	responseData := map[string]any{
-		"post_id":        postID,
-		"user_full_name": user.FullName(),
-		"user_avatar":    user.Avatar,
-		"content":        input.Content,
-		"file":           input.File,
-		"visibility":     dbVisibility,
-		"created_at":     currentDateTime,
+		"post_id":              postID,
+		"user_full_name":       user.FullName(),
+		"user_avatar_id":       user.AvatarID,
+		"user_avatar_alt_text": user.AvatarAltText,
+		"content":              input.Content,
+		"content_format":       format,
+		"content_html":         contentHTML,
+		"media_id":             mediaID,
+		"media_alt_text":       mediaAltText,
+		"attachment_ids":       input.AttachmentIDs,
+		"visibility":           dbVisibility,
+		"poll_id":              pollID,
+		"created_at":           currentDateTime,
 	}
 
 	err = response.JSON(w, http.StatusCreated, responseData)
@@ -117,3 +147,49 @@ func (app *Application) createPost(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// checkVisibility validates the visibility requested for a post or article by authorID and
+// returns the stored value: public, private (followers only) or limited (the allowed_user_ids
+// followers). Errors are reported on v; the error result is for database failures only.
+func (app *Application) checkVisibility(v *validator.Validator, rawVisibility string, allowedUserIDs []int, authorID int) (string, error) {
+	visibility := strings.ToLower(strings.TrimSpace(rawVisibility))
+	if visibility == "" {
+		visibility = "public"
+	}
+
+	var dbVisibility string
+	switch visibility {
+	case "public":
+		dbVisibility = "public"
+	case "almost_private", "followers", "followers_only":
+		dbVisibility = "private" // followers-only
+	case "private":
+		dbVisibility = "limited" // selected followers
+	default:
+		v.AddFieldError("visibility", "Visibility must be one of public, almost_private, or private")
+	}
+
+	// Validate allow-list when using selected followers privacy.
+	if dbVisibility == "limited" {
+		if len(allowedUserIDs) == 0 {
+			v.AddFieldError("allowed_user_ids", "Provide at least one allowed follower for private posts")
+		} else {
+			for _, uid := range allowedUserIDs {
+				if uid <= 0 {
+					v.AddFieldError("allowed_user_ids", "All allowed user IDs must be positive")
+					break
+				}
+				isFollower, err := app.DB.IsFollowing(uid, authorID)
+				if err != nil {
+					return "", err
+				}
+				if !isFollower {
+					v.AddFieldError("allowed_user_ids", fmt.Sprintf("User %d is not a follower", uid))
+					break
+				}
+			}
+		}
+	}
+
+	return dbVisibility, nil
+}
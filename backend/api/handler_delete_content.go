@@ -0,0 +1,100 @@
+package api
+
+import (
+	"net/http"
+
+	"brainbook-api/internal/database"
+	t "brainbook-api/internal/time"
+)
+
+// softDeleteContent marks the content as deleted and answers with 204 No Content.
+// Deleted posts disappear from every listing; deleted comments stay in their thread as tombstones.
+// Rows are purged for good by the purge job once the retention window has passed.
+func (app *Application) softDeleteContent(w http.ResponseWriter, r *http.Request, targetType string, targetID int) {
+	if err := app.DB.SoftDeleteContent(targetType, targetID, t.CurrentTime()); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// deletePost handles DELETE /protected/v1/posts/{post_id}
+func (app *Application) deletePost(w http.ResponseWriter, r *http.Request) {
+	postID, content, ok := app.loadEditableContent(w, r, database.ContentPost, "post_id", 0)
+	if !ok {
+		return
+	}
+
+	user := contextGetAuthenticatedUser(r)
+	if content.AuthorID != user.ID {
+		app.Unauthorized(w, r)
+		return
+	}
+
+	app.softDeleteContent(w, r, database.ContentPost, postID)
+}
+
+// deleteComment handles DELETE /protected/v1/posts/{post_id}/comments/{comment_id}
+func (app *Application) deleteComment(w http.ResponseWriter, r *http.Request) {
+	postID, _, ok := app.loadEditableContent(w, r, database.ContentPost, "post_id", 0)
+	if !ok {
+		return
+	}
+
+	commentID, content, ok := app.loadEditableContent(w, r, database.ContentComment, "comment_id", postID)
+	if !ok {
+		return
+	}
+
+	user := contextGetAuthenticatedUser(r)
+	if content.AuthorID != user.ID {
+		app.Unauthorized(w, r)
+		return
+	}
+
+	app.softDeleteContent(w, r, database.ContentComment, commentID)
+}
+
+// deleteGroupPost handles DELETE /protected/v1/groups/{group_id}/posts/{post_id}
+// The author or the group owner may delete a group post.
+func (app *Application) deleteGroupPost(w http.ResponseWriter, r *http.Request) {
+	group := contextGetGroup(r)
+
+	postID, content, ok := app.loadEditableContent(w, r, database.ContentGroupPost, "post_id", group.ID)
+	if !ok {
+		return
+	}
+
+	user := contextGetAuthenticatedUser(r)
+	if content.AuthorID != user.ID && group.OwnerID != user.ID {
+		app.Unauthorized(w, r)
+		return
+	}
+
+	app.softDeleteContent(w, r, database.ContentGroupPost, postID)
+}
+
+// deleteGroupPostComment handles DELETE /protected/v1/groups/{group_id}/posts/{post_id}/comments/{comment_id}
+// The author or the group owner may delete a group post comment.
+func (app *Application) deleteGroupPostComment(w http.ResponseWriter, r *http.Request) {
+	group := contextGetGroup(r)
+
+	postID, _, ok := app.loadEditableContent(w, r, database.ContentGroupPost, "post_id", group.ID)
+	if !ok {
+		return
+	}
+
+	commentID, content, ok := app.loadEditableContent(w, r, database.ContentGroupPostComment, "comment_id", postID)
+	if !ok {
+		return
+	}
+
+	user := contextGetAuthenticatedUser(r)
+	if content.AuthorID != user.ID && group.OwnerID != user.ID {
+		app.Unauthorized(w, r)
+		return
+	}
+
+	app.softDeleteContent(w, r, database.ContentGroupPostComment, commentID)
+}
@@ -0,0 +1,396 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"brainbook-api/api/websocket"
+	"brainbook-api/internal/database"
+	"brainbook-api/internal/hashtag"
+	"brainbook-api/internal/request"
+	"brainbook-api/internal/response"
+	t "brainbook-api/internal/time"
+	"brainbook-api/internal/validator"
+)
+
+// editContentInput is the body of every post and comment edit.
+// An omitted file or media_id keeps the current file; remove_file drops it. An omitted content_format keeps the current one.
+type editContentInput struct {
+	Content       string              `json:"content"`
+	ContentFormat string              `json:"content_format"`
+	File          []byte              `json:"file"`
+	MediaID       *int                `json:"media_id"`
+	RemoveFile    bool                `json:"remove_file"`
+	Validator     validator.Validator `json:"-"`
+}
+
+// loadEditableContent reads the {idParam} path value and loads the post or comment it names.
+// The content must belong to parentID (see database.EditableContent); parentID 0 skips the check.
+// It writes the error response and returns false when the content cannot be addressed.
+func (app *Application) loadEditableContent(w http.ResponseWriter, r *http.Request, targetType, idParam string, parentID int) (int, *database.EditableContent, bool) {
+	idStr := r.PathValue(idParam)
+	id, err := parseStringID(idStr)
+	if err != nil || id <= 0 {
+		app.badRequest(w, r, fmt.Errorf("invalid %s: %s", idParam, idStr))
+		return 0, nil, false
+	}
+
+	content, exists, err := app.DB.EditableContentByID(targetType, id)
+	if err != nil {
+		app.serverError(w, r, err)
+		return 0, nil, false
+	}
+	if !exists || (parentID != 0 && content.ParentID != parentID) {
+		app.notFound(w, r)
+		return 0, nil, false
+	}
+
+	return id, content, true
+}
+
+// applyContentEdit decodes and validates an edit by the authenticated user, stores it and
+// broadcasts it to the online users allowed by canReceive. It writes the response.
+func (app *Application) applyContentEdit(w http.ResponseWriter, r *http.Request, event websocket.ContentEditedEvent, content *database.EditableContent, contentField string, canReceive func(userID int) bool) (bool, string) {
+	user := contextGetAuthenticatedUser(r)
+	if content.AuthorID != user.ID {
+		app.Unauthorized(w, r)
+		return false, ""
+	}
+
+	var input editContentInput
+	if err := request.DecodeJSON(w, r, &input); err != nil {
+		app.badRequest(w, r, err)
+		return false, ""
+	}
+
+	format := strings.ToLower(strings.TrimSpace(input.ContentFormat))
+	if format == "" {
+		format = content.ContentFormat
+	}
+	if !database.HasContentFormat(event.TargetType) && format != database.FormatPlain {
+		input.Validator.AddFieldError("content_format", "Comments are plain text")
+	}
+
+	validatePostContent(&input.Validator, contentField, input.Content, format, input.File)
+	input.Validator.CheckField(!(input.RemoveFile && (len(input.File) > 0 || input.MediaID != nil)), "file", "Cannot upload and remove a file at once")
+	if err := app.checkUpload(&input.Validator, "media_id", input.MediaID, input.File, user.ID); err != nil {
+		app.serverError(w, r, err)
+		return false, ""
+	}
+	if group := contextGetGroup(r); group != nil && !input.Validator.HasErrors() {
+		if err := app.checkGroupQuota(&input.Validator, "file", group.ID, input.MediaID, input.File, nil); err != nil {
+			app.serverError(w, r, err)
+			return false, ""
+		}
+	}
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return false, ""
+	}
+
+	mediaID, err := app.attachMedia(user.ID, input.MediaID, input.File)
+	if err != nil {
+		app.mediaError(w, r, "file", err)
+		return false, ""
+	}
+
+	editedAt := t.CurrentTime()
+	contentHTML := renderContent(format, input.Content)
+	if err := app.DB.EditContent(event.TargetType, event.TargetID, input.Content, format, contentHTML, mediaID, input.RemoveFile, editedAt); err != nil {
+		app.serverError(w, r, err)
+		return false, ""
+	}
+
+	if app.WSManager != nil {
+		event.Content = input.Content
+		if database.HasContentFormat(event.TargetType) {
+			event.ContentFormat = format
+			event.ContentHTML = contentHTML
+		}
+		event.FileChanged = mediaID != nil || input.RemoveFile
+		event.EditedAt = editedAt
+		app.WSManager.BroadcastEvent(websocket.EventContentEdited, event, canReceive)
+	}
+
+	return true, input.Content
+}
+
+// postViewers returns a broadcast filter matching the users who can view postID.
+func (app *Application) postViewers(postID int) func(userID int) bool {
+	return func(userID int) bool {
+		canView, err := app.DB.CanUserViewPost(userID, postID)
+		if err != nil {
+			log.Printf("postViewers error: %v", err)
+			return false
+		}
+		return canView
+	}
+}
+
+// groupViewers returns a broadcast filter matching the users who can access group.
+func (app *Application) groupViewers(group *database.Group) func(userID int) bool {
+	return func(userID int) bool {
+		canAccess, err := app.DB.CanAccessGroup(userID, group)
+		if err != nil {
+			log.Printf("groupViewers error: %v", err)
+			return false
+		}
+		return canAccess
+	}
+}
+
+// editPost handles PATCH /protected/v1/posts/{post_id}
+func (app *Application) editPost(w http.ResponseWriter, r *http.Request) {
+	postID, content, ok := app.loadEditableContent(w, r, database.ContentPost, "post_id", 0)
+	if !ok {
+		return
+	}
+
+	// A plain repost has no content of its own to edit.
+	if originalID, plain, err := app.DB.RepostOf(postID); err != nil {
+		app.serverError(w, r, err)
+		return
+	} else if originalID != 0 && plain {
+		app.badRequest(w, r, fmt.Errorf("reposts cannot be edited"))
+		return
+	}
+
+	event := websocket.ContentEditedEvent{TargetType: database.ContentPost, TargetID: postID, PostID: postID}
+	ok, newContent := app.applyContentEdit(w, r, event, content, "post-content", app.postViewers(postID))
+	if !ok {
+		return
+	}
+
+	if err := app.DB.IndexPostTags(postID, hashtag.Parse(newContent)); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	mentionPayload := map[string]interface{}{"post_id": postID}
+	if err := app.indexMentions(database.ContentPost, postID, newContent, contextGetAuthenticatedUser(r), mentionPayload, app.postViewers(postID)); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if err := app.refreshLinkPreview(r, postID, newContent); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.respondEditedContent(w, r, database.ContentPost, postID)
+}
+
+// editComment handles PATCH /protected/v1/posts/{post_id}/comments/{comment_id}
+func (app *Application) editComment(w http.ResponseWriter, r *http.Request) {
+	postIDStr := r.PathValue("post_id")
+	postID, err := parseStringID(postIDStr)
+	if err != nil || postID <= 0 {
+		app.badRequest(w, r, fmt.Errorf("invalid post ID: %s", postIDStr))
+		return
+	}
+
+	commentID, content, ok := app.loadEditableContent(w, r, database.ContentComment, "comment_id", postID)
+	if !ok {
+		return
+	}
+
+	// The author must still be able to see the post they commented on
+	user := contextGetAuthenticatedUser(r)
+	canView, err := app.DB.CanUserViewPost(user.ID, postID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !canView {
+		app.Unauthorized(w, r)
+		return
+	}
+
+	event := websocket.ContentEditedEvent{TargetType: database.ContentComment, TargetID: commentID, PostID: postID}
+	ok, newContent := app.applyContentEdit(w, r, event, content, "content", app.postViewers(postID))
+	if !ok {
+		return
+	}
+
+	mentionPayload := map[string]interface{}{"post_id": postID, "comment_id": commentID}
+	if err := app.indexMentions(database.ContentComment, commentID, newContent, user, mentionPayload, app.postViewers(postID)); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.respondEditedContent(w, r, database.ContentComment, commentID)
+}
+
+// editGroupPost handles PATCH /protected/v1/groups/{group_id}/posts/{post_id}
+func (app *Application) editGroupPost(w http.ResponseWriter, r *http.Request) {
+	group := contextGetGroup(r)
+
+	postID, content, ok := app.loadEditableContent(w, r, database.ContentGroupPost, "post_id", group.ID)
+	if !ok {
+		return
+	}
+
+	event := websocket.ContentEditedEvent{TargetType: database.ContentGroupPost, TargetID: postID, PostID: postID, GroupID: group.ID}
+	ok, newContent := app.applyContentEdit(w, r, event, content, "content", app.groupViewers(group))
+	if !ok {
+		return
+	}
+
+	if err := app.DB.IndexGroupPostTags(postID, hashtag.Parse(newContent)); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	mentionPayload := map[string]interface{}{"group_id": group.ID, "group_post_id": postID}
+	if err := app.indexMentions(database.ContentGroupPost, postID, newContent, contextGetAuthenticatedUser(r), mentionPayload, app.groupViewers(group)); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.respondEditedContent(w, r, database.ContentGroupPost, postID)
+}
+
+// editGroupPostComment handles PATCH /protected/v1/groups/{group_id}/posts/{post_id}/comments/{comment_id}
+func (app *Application) editGroupPostComment(w http.ResponseWriter, r *http.Request) {
+	group := contextGetGroup(r)
+
+	postID, _, ok := app.loadEditableContent(w, r, database.ContentGroupPost, "post_id", group.ID)
+	if !ok {
+		return
+	}
+
+	commentID, content, ok := app.loadEditableContent(w, r, database.ContentGroupPostComment, "comment_id", postID)
+	if !ok {
+		return
+	}
+
+	event := websocket.ContentEditedEvent{TargetType: database.ContentGroupPostComment, TargetID: commentID, PostID: postID, GroupID: group.ID}
+	ok, newContent := app.applyContentEdit(w, r, event, content, "content", app.groupViewers(group))
+	if !ok {
+		return
+	}
+
+	mentionPayload := map[string]interface{}{"group_id": group.ID, "group_post_id": postID, "group_post_comment_id": commentID}
+	if err := app.indexMentions(database.ContentGroupPostComment, commentID, newContent, contextGetAuthenticatedUser(r), mentionPayload, app.groupViewers(group)); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	app.respondEditedContent(w, r, database.ContentGroupPostComment, commentID)
+}
+
+// respondEditedContent answers an edit with the id of the content and its number of revisions.
+func (app *Application) respondEditedContent(w http.ResponseWriter, r *http.Request, targetType string, targetID int) {
+	revisions, err := app.DB.RevisionsFor(targetType, targetID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	responseData := map[string]any{
+		"target_type":    targetType,
+		"target_id":      targetID,
+		"revision_count": len(revisions),
+	}
+
+	if err := response.JSON(w, http.StatusOK, responseData); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// respondRevisions lists the previous versions of a post or comment.
+func (app *Application) respondRevisions(w http.ResponseWriter, r *http.Request, targetType string, targetID int) {
+	revisions, err := app.DB.RevisionsFor(targetType, targetID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	responseData := map[string]any{
+		"target_type": targetType,
+		"target_id":   targetID,
+		"revisions":   revisions,
+	}
+
+	if err := response.JSON(w, http.StatusOK, responseData); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// getPostRevisions handles GET /protected/v1/posts/{post_id}/revisions
+func (app *Application) getPostRevisions(w http.ResponseWriter, r *http.Request) {
+	postID, _, ok := app.loadEditableContent(w, r, database.ContentPost, "post_id", 0)
+	if !ok {
+		return
+	}
+
+	user := contextGetAuthenticatedUser(r)
+	canView, err := app.DB.CanUserViewPost(user.ID, postID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !canView {
+		app.Unauthorized(w, r)
+		return
+	}
+
+	app.respondRevisions(w, r, database.ContentPost, postID)
+}
+
+// getCommentRevisions handles GET /protected/v1/posts/{post_id}/comments/{comment_id}/revisions
+func (app *Application) getCommentRevisions(w http.ResponseWriter, r *http.Request) {
+	postID, _, ok := app.loadEditableContent(w, r, database.ContentPost, "post_id", 0)
+	if !ok {
+		return
+	}
+
+	commentID, _, ok := app.loadEditableContent(w, r, database.ContentComment, "comment_id", postID)
+	if !ok {
+		return
+	}
+
+	user := contextGetAuthenticatedUser(r)
+	canView, err := app.DB.CanUserViewPost(user.ID, postID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !canView {
+		app.Unauthorized(w, r)
+		return
+	}
+
+	app.respondRevisions(w, r, database.ContentComment, commentID)
+}
+
+// getGroupPostRevisions handles GET /protected/v1/groups/{group_id}/posts/{post_id}/revisions
+func (app *Application) getGroupPostRevisions(w http.ResponseWriter, r *http.Request) {
+	group := contextGetGroup(r)
+
+	postID, _, ok := app.loadEditableContent(w, r, database.ContentGroupPost, "post_id", group.ID)
+	if !ok {
+		return
+	}
+
+	app.respondRevisions(w, r, database.ContentGroupPost, postID)
+}
+
+// getGroupPostCommentRevisions handles GET /protected/v1/groups/{group_id}/posts/{post_id}/comments/{comment_id}/revisions
+func (app *Application) getGroupPostCommentRevisions(w http.ResponseWriter, r *http.Request) {
+	group := contextGetGroup(r)
+
+	postID, _, ok := app.loadEditableContent(w, r, database.ContentGroupPost, "post_id", group.ID)
+	if !ok {
+		return
+	}
+
+	commentID, _, ok := app.loadEditableContent(w, r, database.ContentGroupPostComment, "comment_id", postID)
+	if !ok {
+		return
+	}
+
+	app.respondRevisions(w, r, database.ContentGroupPostComment, commentID)
+}
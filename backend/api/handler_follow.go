@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"net/http"
 
+	"brainbook-api/internal/database"
 	"brainbook-api/internal/request"
 	"brainbook-api/internal/response"
+	"brainbook-api/internal/validator"
 )
 
 func (app *Application) sendFollowRequest(w http.ResponseWriter, r *http.Request) {
@@ -51,10 +53,7 @@ func (app *Application) sendFollowRequest(w http.ResponseWriter, r *http.Request
 	}
 
 	if status == "pending" {
-		app.notifyUser(targetID, NotificationTypeFollowRequest, map[string]interface{}{
-			"request_id":   req.ID,
-			"requester_id": user.ID,
-		})
+		app.refreshFollowRequestSummary(targetID, user.ID)
 	} else {
 		app.notifyUser(targetID, NotificationTypeFollowRequest, map[string]interface{}{
 			"request_id": req.ID,
@@ -113,21 +112,123 @@ func (app *Application) respondFollowRequest(w http.ResponseWriter, r *http.Requ
 		"request_id": fr.ID,
 		"status":     newStatus,
 	})
+	app.refreshFollowRequestSummary(user.ID, 0)
 
 	_ = response.JSON(w, http.StatusOK, map[string]any{"status": newStatus})
 }
 
-// getPendingFollowRequests returns pending follow requests for the authenticated user.
+// respondFollowRequestsBulk accepts or declines several pending follow requests at once,
+// either by id list or all of them, optionally narrowed down by the same filters as the listing.
+func (app *Application) respondFollowRequestsBulk(w http.ResponseWriter, r *http.Request) {
+	user := contextGetAuthenticatedUser(r)
+
+	var input struct {
+		Action        string              `json:"action"`
+		IDs           []int               `json:"ids"`
+		All           bool                `json:"all"`
+		HasMutual     bool                `json:"has_mutual"`
+		InSharedGroup bool                `json:"in_shared_group"`
+		Validator     validator.Validator `json:"-"`
+	}
+	if err := request.DecodeJSON(w, r, &input); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	var newStatus string
+	switch input.Action {
+	case "accept":
+		newStatus = "accepted"
+	case "decline":
+		newStatus = "declined"
+	default:
+		input.Validator.AddFieldError("action", "Action must be accept or decline")
+	}
+
+	if !input.All {
+		input.Validator.CheckField(len(input.IDs) > 0, "ids", "Provide request ids or set all to true")
+		input.Validator.CheckField(len(input.IDs) <= 500, "ids", "No more than 500 requests can be processed at once")
+		for _, id := range input.IDs {
+			if id <= 0 {
+				input.Validator.AddFieldError("ids", "All request ids must be positive")
+				break
+			}
+		}
+	}
+
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	filter := database.FollowRequestFilter{
+		HasMutualFollowers: input.HasMutual,
+		InSharedGroup:      input.InSharedGroup,
+	}
+
+	updated, err := app.DB.RespondFollowRequests(user.ID, input.IDs, input.All, filter, newStatus)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	updatedIDs := make([]int, len(updated))
+	for i, fr := range updated {
+		updatedIDs[i] = fr.ID
+		app.notifyUser(fr.RequesterID, NotificationTypeFollowRequest, map[string]interface{}{
+			"request_id": fr.ID,
+			"status":     newStatus,
+		})
+	}
+	app.refreshFollowRequestSummary(user.ID, 0)
+
+	_ = response.JSON(w, http.StatusOK, map[string]any{
+		"status":      newStatus,
+		"updated":     len(updated),
+		"request_ids": updatedIDs,
+	})
+}
+
+// getPendingFollowRequests returns pending follow requests for the authenticated user,
+// oldest first, paginated with an opaque cursor.
 func (app *Application) getPendingFollowRequests(w http.ResponseWriter, r *http.Request) {
 	user := contextGetAuthenticatedUser(r)
 
-	reqs, err := app.DB.PendingFollowRequests(user.ID)
+	limit := parseQueryInt(r, "limit", 25)
+	if limit < 1 || limit > 100 {
+		app.badRequest(w, r, fmt.Errorf("limit must be between 1 and 100"))
+		return
+	}
+
+	cursor, err := parseQueryCursor(r, "cursor")
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	filter := database.FollowRequestFilter{
+		HasMutualFollowers: parseQueryBool(r, "has_mutual"),
+		InSharedGroup:      parseQueryBool(r, "in_shared_group"),
+	}
+
+	reqs, err := app.DB.PendingFollowRequests(user.ID, filter, cursor, limit+1)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
-	_ = response.JSON(w, http.StatusOK, map[string]any{"requests": reqs})
+	var nextCursor string
+	if len(reqs) > limit {
+		reqs = reqs[:limit]
+		last := reqs[len(reqs)-1]
+		nextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	_ = response.JSON(w, http.StatusOK, map[string]any{
+		"requests":    reqs,
+		"next_cursor": nextCursor,
+		"has_more":    nextCursor != "",
+	})
 }
 
 // unfollowUser removes an accepted follow relation.
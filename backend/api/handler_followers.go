@@ -29,20 +29,17 @@ func (app *Application) getUserFollowers(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	isSelf := viewer.ID == targetUserID
-	if !targetUser.IsPublic && !isSelf {
-		isFollower, err := app.DB.IsFollowing(viewer.ID, targetUserID)
-		if err != nil {
+	canView, err := app.canViewProfile(viewer.ID, targetUser)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !canView {
+		if err := response.JSON(w, http.StatusOK, map[string]any{"followers": []any{}}); err != nil {
 			app.serverError(w, r, err)
 			return
 		}
-		if !isFollower {
-			if err := response.JSON(w, http.StatusOK, map[string]any{"followers": []any{}}); err != nil {
-				app.serverError(w, r, err)
-				return
-			}
-			return
-		}
+		return
 	}
 
 	followers, err := app.DB.FollowersByUserID(targetUserID)
@@ -7,11 +7,12 @@ import (
 )
 
 func (app *Application) getGroupMessages(w http.ResponseWriter, r *http.Request) {
+	user := contextGetAuthenticatedUser(r)
 	group := contextGetGroup(r)
 	limit := parseQueryInt(r, "limit", 50)
 	offset := parseQueryInt(r, "offset", 0)
 
-	messages, err := app.DB.GroupMessages(group.ID, limit, offset)
+	messages, err := app.DB.GroupMessages(user.ID, group.ID, limit, offset)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
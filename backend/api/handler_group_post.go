@@ -1,17 +1,21 @@
 package api
 
 import (
+	"brainbook-api/internal/database"
+	"brainbook-api/internal/hashtag"
 	"brainbook-api/internal/request"
 	"brainbook-api/internal/response"
 	t "brainbook-api/internal/time"
 	"brainbook-api/internal/validator"
 	"net/http"
+	"strings"
 )
 
 func (app *Application) groupPosts(w http.ResponseWriter, r *http.Request) {
+	user := contextGetAuthenticatedUser(r)
 	group := contextGetGroup(r)
 
-	posts, err := app.DB.GetGroupPosts(group.ID)
+	posts, err := app.DB.GetGroupPosts(user.ID, group.ID)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
@@ -31,9 +35,13 @@ func (app *Application) groupPosts(w http.ResponseWriter, r *http.Request) {
 func (app *Application) groupPostCreate(w http.ResponseWriter, r *http.Request) {
 
 	var input struct {
-		Content   string              `json:"content"`
-		File      []byte              `json:"file"`
-		Validator validator.Validator `json:"-"`
+		Content       string              `json:"content"`
+		ContentFormat string              `json:"content_format"`
+		File          []byte              `json:"file"`
+		MediaID       *int                `json:"media_id"`
+		AttachmentIDs []int               `json:"attachment_ids"`
+		Poll          *pollInput          `json:"poll"`
+		Validator     validator.Validator `json:"-"`
 	}
 
 	if err := request.DecodeJSON(w, r, &input); err != nil {
@@ -41,31 +49,84 @@ func (app *Application) groupPostCreate(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	input.Validator.CheckField(validator.NotBlank(input.Content), "content", "Content must not be empty")
-	input.Validator.CheckField(validator.MaxRunes(input.Content, 500), "content", "Content must not exceed 500 characters")
-	if len(input.File) > 0 {
-		input.Validator.CheckField(len(input.File) <= 10_000_000, "file", "File size must be 10MB or less")
-		input.Validator.CheckField(isAllowedImage(input.File), "file", "File must be JPEG, PNG, or GIF")
+	format := strings.ToLower(strings.TrimSpace(input.ContentFormat))
+	if format == "" {
+		format = database.FormatPlain
+	}
+	validatePostContent(&input.Validator, "content", input.Content, format, input.File)
+	poll := checkPoll(&input.Validator, input.Poll)
+
+	ctx := contextGetAuthenticatedUser(r)
+	userID := ctx.ID
+
+	if err := app.checkUpload(&input.Validator, "media_id", input.MediaID, input.File, userID); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if err := app.checkAttachments(&input.Validator, input.AttachmentIDs, userID); err != nil {
+		app.serverError(w, r, err)
+		return
 	}
 	if input.Validator.HasErrors() {
 		app.failedValidation(w, r, input.Validator)
 		return
 	}
 
-	ctx := contextGetAuthenticatedUser(r)
-	userID := ctx.ID
-
 	group := contextGetGroup(r)
 
+	if err := app.checkGroupQuota(&input.Validator, "file", group.ID, input.MediaID, input.File, input.AttachmentIDs); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
 	//content string, image []byte, currentDateTime string, userID int, groupID int
-	postID, err := app.DB.InsertGroupPost(input.Content, input.File, t.CurrentTime(), userID, group.ID)
+	currentDateTime := t.CurrentTime()
+	mediaID, err := app.attachMedia(userID, input.MediaID, input.File)
+	if err != nil {
+		app.mediaError(w, r, "file", err)
+		return
+	}
+
+	postID, err := app.DB.InsertGroupPost(input.Content, format, renderContent(format, input.Content), mediaID, currentDateTime, userID, group.ID)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
+	if err := app.DB.InsertAttachments(database.ContentGroupPost, postID, input.AttachmentIDs); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	var pollID *int
+	if poll != nil {
+		id, err := app.DB.InsertPoll(database.ContentGroupPost, postID, *poll, currentDateTime)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		pollID = &id
+	}
+
+	if err := app.DB.IndexGroupPostTags(postID, hashtag.Parse(input.Content)); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	mentionPayload := map[string]interface{}{"group_id": group.ID, "group_post_id": postID}
+	if err := app.indexMentions(database.ContentGroupPost, postID, input.Content, ctx, mentionPayload, app.groupViewers(group)); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
 	responseData := map[string]interface{}{
-		"post_id": postID,
+		"post_id":        postID,
+		"poll_id":        pollID,
+		"attachment_ids": input.AttachmentIDs,
 	}
 
 	err = response.JSON(w, http.StatusCreated, responseData)
@@ -1,9 +1,9 @@
 package api
 
 import (
-	"fmt"
 	"net/http"
 
+	"brainbook-api/internal/database"
 	"brainbook-api/internal/request"
 	"brainbook-api/internal/response"
 	t "brainbook-api/internal/time"
@@ -11,15 +11,16 @@ import (
 )
 
 func (app *Application) getGroupPostComments(w http.ResponseWriter, r *http.Request) {
+	group := contextGetGroup(r)
 
-	postIDStr := r.PathValue("post_id")
-	postID, err := parseStringID(postIDStr)
-	if err != nil || postID <= 0 {
-		app.badRequest(w, r, fmt.Errorf("invalid post ID: %s", postIDStr))
+	// The post must exist in this group and not be deleted
+	postID, _, ok := app.loadEditableContent(w, r, database.ContentGroupPost, "post_id", group.ID)
+	if !ok {
 		return
 	}
 
-	comments, err := app.DB.GetCommentsForGroupPost(postID)
+	user := contextGetAuthenticatedUser(r)
+	comments, err := app.DB.GetCommentsForGroupPost(user.ID, postID)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
@@ -35,6 +36,8 @@ func (app *Application) createGroupPostComment(w http.ResponseWriter, r *http.Re
 	var input struct {
 		Content   string              `json:"content"`
 		File      []byte              `json:"file"`
+		MediaID   *int                `json:"media_id"`
+		ParentID  *int                `json:"parent_id"`
 		Validator validator.Validator `json:"-"`
 	}
 
@@ -43,35 +46,73 @@ func (app *Application) createGroupPostComment(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	input.Validator.CheckField(validator.NotBlank(input.Content), "content", "Content must not be empty")
-	input.Validator.CheckField(validator.MaxRunes(input.Content, 500), "content", "Content must not exceed 500 characters")
-	if len(input.File) > 0 {
-		input.Validator.CheckField(len(input.File) <= 10_000_000, "file", "File size must be 10MB or less")
-		input.Validator.CheckField(isAllowedImage(input.File), "file", "File must be JPEG, PNG, or GIF")
+	validateContent(&input.Validator, "content", input.Content, input.File)
+
+	ctx := contextGetAuthenticatedUser(r)
+	userID := ctx.ID
+
+	if err := app.checkUpload(&input.Validator, "media_id", input.MediaID, input.File, userID); err != nil {
+		app.serverError(w, r, err)
+		return
 	}
 	if input.Validator.HasErrors() {
 		app.failedValidation(w, r, input.Validator)
 		return
 	}
 
-	ctx := contextGetAuthenticatedUser(r)
-	userID := ctx.ID
+	group := contextGetGroup(r)
 
-	postIDStr := r.PathValue("post_id")
-	postID, err := parseStringID(postIDStr)
-	if err != nil || postID <= 0 {
-		app.badRequest(w, r, fmt.Errorf("invalid post ID: %s", postIDStr))
+	if err := app.checkGroupQuota(&input.Validator, "file", group.ID, input.MediaID, input.File, nil); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
 		return
 	}
 
-	commentID, err := app.DB.InsertGroupPostComment(input.Content, input.File, t.CurrentTime(), postID, userID)
+	postID, _, ok := app.loadEditableContent(w, r, database.ContentGroupPost, "post_id", group.ID)
+	if !ok {
+		return
+	}
+
+	parent, ok := app.checkReplyParent(w, r, &input.Validator, database.ContentGroupPostComment, input.ParentID, postID)
+	if !ok {
+		return
+	}
+
+	mediaID, err := app.attachMedia(userID, input.MediaID, input.File)
+	if err != nil {
+		app.mediaError(w, r, "file", err)
+		return
+	}
+
+	commentID, err := app.DB.InsertGroupPostComment(input.Content, mediaID, t.CurrentTime(), postID, userID, input.ParentID)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
+	mentionPayload := map[string]interface{}{"group_id": group.ID, "group_post_id": postID, "group_post_comment_id": commentID}
+	if err := app.indexMentions(database.ContentGroupPostComment, commentID, input.Content, ctx, mentionPayload, app.groupViewers(group)); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if parent != nil && parent.AuthorID != userID {
+		app.notifyUser(parent.AuthorID, NotificationTypeCommentReply, map[string]interface{}{
+			"group_id":              group.ID,
+			"group_post_id":         postID,
+			"group_post_comment_id": commentID,
+			"parent_comment_id":     *input.ParentID,
+			"user_id":               userID,
+			"user_full_name":        ctx.FullName(),
+		})
+	}
+
 	responseData := map[string]any{
 		"comment_id": commentID,
+		"parent_id":  input.ParentID,
 	}
 	if err := response.JSON(w, http.StatusCreated, responseData); err != nil {
 		app.serverError(w, r, err)
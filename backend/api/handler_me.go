@@ -20,6 +20,8 @@ func (app *Application) getCurrentUser(w http.ResponseWriter, r *http.Request) {
 		"f_name":   user.FName,
 		"l_name":   user.LName,
 		"nickname": user.Nickname,
+		// handle is what other users type after @ to mention the user
+		"handle": user.Handle,
 	}
 
 	if err := response.JSON(w, http.StatusOK, payload); err != nil {
@@ -0,0 +1,298 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"brainbook-api/internal/database"
+	"brainbook-api/internal/media"
+	"brainbook-api/internal/request"
+	"brainbook-api/internal/response"
+	"brainbook-api/internal/validator"
+)
+
+const (
+	// uploadTimeout replaces the server read and write timeouts for uploads, which take longer
+	// to receive and to process than JSON bodies.
+	uploadTimeout = 2 * time.Minute
+	// multipartOverhead is the room left above the file size cap for boundaries and part headers.
+	multipartOverhead = 64 << 10
+)
+
+// uploadMedia handles POST /protected/v1/media
+// It stores the "file" part of a multipart/form-data body and returns the id that posts,
+// comments, articles and profile updates reference instead of inline base64. An "alt_text" part
+// sent before the file describes it for screen readers. The type is
+// detected from the first 512 bytes, before the rest of the file is read, and must be one of
+// the configured types, whose size limit then applies, as does the storage quota of the user.
+// other files are stored as they are (see storeFile). New files are quarantined: only the
+// other files are streamed to the store as they are. New files are quarantined: only the
+// uploader sees them until the malware scan finds them clean (see scanPendingMedia).
+func (app *Application) uploadMedia(w http.ResponseWriter, r *http.Request) {
+	types := app.Config.Media.Types
+
+	controller := http.NewResponseController(w)
+	deadline := time.Now().Add(uploadTimeout)
+	if err := controller.SetReadDeadline(deadline); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if err := controller.SetWriteDeadline(deadline); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, types.MaxBytes()+multipartOverhead)
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		app.badRequest(w, r, errors.New("body must be multipart/form-data"))
+		return
+	}
+
+	var part *multipart.Part
+	var rawAltText []byte
+	for part == nil {
+		next, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			app.badRequest(w, r, errors.New("body must contain a file part"))
+			return
+		}
+		if err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+		switch next.FormName() {
+		case "file":
+			part = next
+		case "alt_text":
+			// Longer text fails validation whatever the rest says.
+			rawAltText, err = io.ReadAll(io.LimitReader(next, 4*maxAltTextRunes+1))
+			next.Close()
+			if err != nil {
+				app.badRequest(w, r, err)
+				return
+			}
+		default:
+			next.Close()
+		}
+	}
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(part, head)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		app.badRequest(w, r, err)
+		return
+	}
+	head = head[:n]
+
+	var v validator.Validator
+	kind, allowed := types.Detect(head, part.FileName())
+	v.CheckField(n > 0, "file", "File must not be empty")
+	v.CheckField(n == 0 || allowed, "file", "File type must be one of: "+strings.Join(types.Names(), ", "))
+	altText := checkAltText(&v, "alt_text", string(rawAltText))
+	if v.HasErrors() {
+		app.failedValidation(w, r, v)
+		return
+	}
+
+	// Uploads to a full quota fail before the rest of the body is read; storeImage and
+	// storeFile check the quota again under its lock.
+	user := contextGetAuthenticatedUser(r)
+	if err := app.checkUserQuota(user.ID, int64(n)); err != nil {
+		app.mediaError(w, r, "file", err)
+		return
+	}
+	content := media.LimitReader(io.MultiReader(bytes.NewReader(head), part), kind.MaxBytes)
+
+	var file *database.Media
+	if kind.Image {
+		var data []byte
+		data, err = io.ReadAll(content)
+		if err == nil {
+			file, err = app.storeImage(user.ID, data)
+		}
+	} else {
+		file, err = app.storeFile(user.ID, kind, part.FileName(), content)
+	}
+
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, media.ErrTooLarge), errors.As(err, &maxBytesErr):
+		v.AddFieldError("file", fmt.Sprintf("File size must be %dMB or less for %s files", kind.MaxBytes/1_000_000, kind.Name))
+		app.failedValidation(w, r, v)
+		return
+	case errors.Is(err, media.ErrNotText):
+		v.AddFieldError("file", "Text files must be UTF-8")
+		app.failedValidation(w, r, v)
+		return
+	case err != nil:
+		app.mediaError(w, r, "file", err)
+		return
+	}
+
+	if altText != nil {
+		if err := app.DB.UpdateMediaAltText(file.ID, altText); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	responseData := map[string]any{
+		"media_id":    file.ID,
+		"mime_type":   file.MimeType,
+		"size":        file.Size,
+		"width":       file.Width,
+		"height":      file.Height,
+		"file_name":   file.FileName,
+		"alt_text":    altText,
+		"scan_status": file.ScanStatus,
+	}
+	if err := response.JSON(w, http.StatusCreated, responseData); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// editMedia handles PATCH /protected/v1/media/{media_id}
+// It replaces the alt text of one of the authenticated user's uploads; blank alt text removes
+// it. The alt text shows wherever the file is referenced.
+func (app *Application) editMedia(w http.ResponseWriter, r *http.Request) {
+	mediaID, err := parseStringID(r.PathValue("media_id"))
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	var input struct {
+		AltText   *string             `json:"alt_text"`
+		Validator validator.Validator `json:"-"`
+	}
+
+	if err := request.DecodeJSON(w, r, &input); err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	file, exists, err := app.DB.MediaByID(mediaID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !exists {
+		app.notFound(w, r)
+		return
+	}
+	if file.OwnerID != contextGetAuthenticatedUser(r).ID {
+		app.Unauthorized(w, r)
+		return
+	}
+
+	input.Validator.CheckField(input.AltText != nil, "alt_text", "Alt text must be provided")
+	var altText *string
+	if input.AltText != nil {
+		altText = checkAltText(&input.Validator, "alt_text", *input.AltText)
+	}
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	if err := app.DB.UpdateMediaAltText(mediaID, altText); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	responseData := map[string]any{
+		"media_id": mediaID,
+		"alt_text": altText,
+	}
+	if err := response.JSON(w, http.StatusOK, responseData); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// getMedia handles GET /protected/v1/media/{media_id}
+// It streams a file the authenticated user may see. Files never change under an id, so the
+// SHA-256 of the content is a strong ETag; conditional and range requests are answered by
+// http.ServeContent. The variant query parameter selects a resized copy of an image; images
+// smaller than the variant are served as they are. For animated GIFs, variant=preview is a PNG
+// of the first frame and variant=short the first frames only. Files other than images are served as
+// attachments under their upload name, so browsers download them instead of rendering them.
+func (app *Application) getMedia(w http.ResponseWriter, r *http.Request) {
+	mediaID, err := parseStringID(r.PathValue("media_id"))
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	file, exists, err := app.DB.MediaByID(mediaID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !exists {
+		app.notFound(w, r)
+		return
+	}
+
+	user := contextGetAuthenticatedUser(r)
+	canView, err := app.DB.CanUserViewMedia(user.ID, mediaID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !canView {
+		app.Unauthorized(w, r)
+		return
+	}
+
+	key, mimeType := file.SHA256, file.MimeType
+	if name := r.URL.Query().Get("variant"); name != "" {
+		if !isMediaVariant(name) {
+			app.badRequest(w, r, fmt.Errorf("unknown variant: %s", name))
+			return
+		}
+		variant, found, err := app.DB.MediaVariantByName(mediaID, name)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		if found {
+			key, mimeType = variant.SHA256, variant.MimeType
+		}
+	}
+
+	content, err := app.Media.Open(key)
+	if errors.Is(err, media.ErrNotFound) {
+		app.notFound(w, r)
+		return
+	}
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	defer content.Close()
+
+	w.Header().Set("Content-Type", mimeType)
+	w.Header().Set("ETag", `"`+key+`"`)
+	w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; sandbox")
+	if !isImage(mimeType) {
+		disposition := "attachment"
+		if file.FileName != nil {
+			if withName := mime.FormatMediaType("attachment", map[string]string{"filename": *file.FileName}); withName != "" {
+				disposition = withName
+			}
+		}
+		w.Header().Set("Content-Disposition", disposition)
+	}
+
+	http.ServeContent(w, r, "", file.CreatedAt, content)
+}
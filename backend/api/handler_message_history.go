@@ -6,14 +6,22 @@ import (
 	"strconv"
 	"strings"
 
+	"brainbook-api/internal/database"
 	"brainbook-api/internal/response"
 	v "brainbook-api/internal/validator"
 )
 
 type MessageHistoryResponse struct {
+	ID        int    `json:"id"`
 	SenderID  int    `json:"sender_id"`
 	Content   string `json:"content"`
 	CreatedAt string `json:"created_at"`
+	// Mentions lists the @handle spans resolved to users.
+	Mentions database.JSONPayload `json:"mentions"`
+	// Attachments lists the attached files.
+	Attachments database.JSONPayload `json:"attachments"`
+
+	database.ReactionSummary
 }
 
 func (app *Application) getConversation(w http.ResponseWriter, r *http.Request) {
@@ -86,7 +94,7 @@ func (app *Application) getConversation(w http.ResponseWriter, r *http.Request)
 
 	offset := (page - 1) * limit
 
-	messages, err := app.DB.PaginatedConversationMessages(conversation.ID, offset, limit)
+	messages, err := app.DB.PaginatedConversationMessages(contextUser.ID, conversation.ID, offset, limit)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
@@ -101,9 +109,13 @@ func (app *Application) getConversation(w http.ResponseWriter, r *http.Request)
 	var messageHistory []MessageHistoryResponse
 	for _, Message := range messages {
 		messageHistory = append(messageHistory, MessageHistoryResponse{
-			SenderID:  Message.SenderID,
-			Content:   Message.Content,
-			CreatedAt: Message.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+			ID:              Message.ID,
+			SenderID:        Message.SenderID,
+			Content:         Message.Content,
+			CreatedAt:       Message.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
+			Mentions:        Message.Mentions,
+			Attachments:     Message.Attachments,
+			ReactionSummary: Message.ReactionSummary,
 		})
 	}
 
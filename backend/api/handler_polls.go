@@ -0,0 +1,307 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"brainbook-api/api/websocket"
+	"brainbook-api/internal/database"
+	"brainbook-api/internal/request"
+	"brainbook-api/internal/response"
+	t "brainbook-api/internal/time"
+	"brainbook-api/internal/validator"
+)
+
+// Limits of polls attached to posts.
+const (
+	minPollOptions       = 2
+	maxPollOptions       = 10
+	maxPollOptionRunes   = 100
+	defaultPollVoterPage = 50
+)
+
+// pollInput is the poll attached to a post on creation.
+type pollInput struct {
+	Options        []string `json:"options"`
+	MultipleChoice bool     `json:"multiple_choice"`
+	Anonymous      bool     `json:"anonymous"`
+	// ClosesAt is an optional RFC 3339 time after which votes are refused.
+	ClosesAt string `json:"closes_at"`
+}
+
+// checkPoll validates a poll attached to a new post. It returns nil when input is nil.
+func checkPoll(v *validator.Validator, input *pollInput) *database.NewPoll {
+	if input == nil {
+		return nil
+	}
+
+	poll := &database.NewPoll{MultipleChoice: input.MultipleChoice, Anonymous: input.Anonymous}
+
+	v.CheckField(len(input.Options) >= minPollOptions && len(input.Options) <= maxPollOptions, "poll.options",
+		fmt.Sprintf("A poll must have between %d and %d options", minPollOptions, maxPollOptions))
+
+	seen := make(map[string]bool, len(input.Options))
+	for _, option := range input.Options {
+		label := strings.TrimSpace(option)
+		key := strings.ToLower(label)
+		switch {
+		case label == "":
+			v.AddFieldError("poll.options", "Poll options must not be empty")
+		case !validator.MaxRunes(label, maxPollOptionRunes):
+			v.AddFieldError("poll.options", fmt.Sprintf("Poll options must not exceed %d characters", maxPollOptionRunes))
+		case seen[key]:
+			v.AddFieldError("poll.options", "Poll options must be different")
+		}
+		seen[key] = true
+		poll.Options = append(poll.Options, label)
+	}
+
+	if input.ClosesAt != "" {
+		if closesAt := checkFutureTime(v, "poll.closes_at", "Closing time", input.ClosesAt); closesAt != "" {
+			poll.ClosesAt = &closesAt
+		}
+	}
+
+	return poll
+}
+
+// pollTarget is the post or group post addressed by a poll route.
+type pollTarget struct {
+	event websocket.PollUpdatedEvent
+	// viewers matches the users who can see the post and vote.
+	viewers func(userID int) bool
+}
+
+// pollTargetResolver loads the target of a poll route and checks that the authenticated user
+// can see it. It writes the error response and returns false otherwise.
+type pollTargetResolver func(w http.ResponseWriter, r *http.Request) (*pollTarget, bool)
+
+// postPollTarget resolves /protected/v1/posts/{post_id}/poll
+func (app *Application) postPollTarget(w http.ResponseWriter, r *http.Request) (*pollTarget, bool) {
+	postID, _, ok := app.loadEditableContent(w, r, database.ContentPost, "post_id", 0)
+	if !ok || !app.requirePostViewer(w, r, postID) {
+		return nil, false
+	}
+
+	return &pollTarget{
+		event:   websocket.PollUpdatedEvent{TargetType: database.ContentPost, TargetID: postID},
+		viewers: app.postViewers(postID),
+	}, true
+}
+
+// groupPostPollTarget resolves /protected/v1/groups/{group_id}/posts/{post_id}/poll
+func (app *Application) groupPostPollTarget(w http.ResponseWriter, r *http.Request) (*pollTarget, bool) {
+	group := contextGetGroup(r)
+
+	postID, _, ok := app.loadEditableContent(w, r, database.ContentGroupPost, "post_id", group.ID)
+	if !ok {
+		return nil, false
+	}
+
+	return &pollTarget{
+		event:   websocket.PollUpdatedEvent{TargetType: database.ContentGroupPost, TargetID: postID, GroupID: group.ID},
+		viewers: app.groupViewers(group),
+	}, true
+}
+
+// loadPoll resolves a poll route to its target and poll. It writes the error response and
+// returns false when the target cannot be seen or has no poll.
+func (app *Application) loadPoll(w http.ResponseWriter, r *http.Request, resolve pollTargetResolver) (*pollTarget, *database.Poll, bool) {
+	target, ok := resolve(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+
+	poll, exists, err := app.DB.PollFor(target.event.TargetType, target.event.TargetID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return nil, nil, false
+	}
+	if !exists {
+		app.notFound(w, r)
+		return nil, nil, false
+	}
+
+	return target, poll, true
+}
+
+// respondWithPoll writes the poll results as seen by the authenticated user.
+func (app *Application) respondWithPoll(w http.ResponseWriter, r *http.Request, target *pollTarget) {
+	user := contextGetAuthenticatedUser(r)
+	results, err := app.DB.PollResults(target.event.TargetType, target.event.TargetID, user.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if err := response.JSON(w, http.StatusOK, map[string]any{"poll": results}); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// getPoll returns the handler showing the results of a poll.
+func (app *Application) getPoll(resolve pollTargetResolver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		target, _, ok := app.loadPoll(w, r, resolve)
+		if !ok {
+			return
+		}
+
+		app.respondWithPoll(w, r, target)
+	}
+}
+
+// votePoll returns the handler casting or changing the vote of the authenticated user.
+// The vote replaces any previous one; single-choice polls take exactly one option.
+func (app *Application) votePoll(resolve pollTargetResolver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var input struct {
+			OptionIDs []int               `json:"option_ids"`
+			Validator validator.Validator `json:"-"`
+		}
+
+		if err := request.DecodeJSON(w, r, &input); err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+
+		target, poll, ok := app.loadPoll(w, r, resolve)
+		if !ok {
+			return
+		}
+
+		optionIDs, err := app.DB.PollOptionIDs(poll.ID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		valid := make(map[int]bool, len(optionIDs))
+		for _, id := range optionIDs {
+			valid[id] = true
+		}
+
+		input.Validator.CheckField(!poll.Closed(), "poll", "This poll is closed")
+		input.Validator.CheckField(len(input.OptionIDs) > 0, "option_ids", "Choose at least one option")
+		input.Validator.CheckField(poll.MultipleChoice || len(input.OptionIDs) <= 1, "option_ids", "Choose a single option")
+
+		chosen := make(map[int]bool, len(input.OptionIDs))
+		for _, id := range input.OptionIDs {
+			if !valid[id] || chosen[id] {
+				input.Validator.AddFieldError("option_ids", "Options must be distinct options of this poll")
+				break
+			}
+			chosen[id] = true
+		}
+
+		if input.Validator.HasErrors() {
+			app.failedValidation(w, r, input.Validator)
+			return
+		}
+
+		user := contextGetAuthenticatedUser(r)
+		if err := app.DB.SetPollVotes(poll.ID, user.ID, input.OptionIDs, t.CurrentTime()); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		app.broadcastPoll(target)
+		app.respondWithPoll(w, r, target)
+	}
+}
+
+// retractPollVote returns the handler removing the vote of the authenticated user.
+func (app *Application) retractPollVote(resolve pollTargetResolver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		target, poll, ok := app.loadPoll(w, r, resolve)
+		if !ok {
+			return
+		}
+
+		if poll.Closed() {
+			var v validator.Validator
+			v.AddFieldError("poll", "This poll is closed")
+			app.failedValidation(w, r, v)
+			return
+		}
+
+		user := contextGetAuthenticatedUser(r)
+		if err := app.DB.SetPollVotes(poll.ID, user.ID, nil, t.CurrentTime()); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		app.broadcastPoll(target)
+		app.respondWithPoll(w, r, target)
+	}
+}
+
+// getPollVoters returns the handler listing who chose an option of a poll whose voters are visible.
+// Query parameters: option_id, limit (1-100, default 50) and offset.
+func (app *Application) getPollVoters(resolve pollTargetResolver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := parseQueryInt(r, "limit", defaultPollVoterPage)
+		if limit < 1 || limit > 100 {
+			app.badRequest(w, r, fmt.Errorf("limit must be between 1 and 100"))
+			return
+		}
+		offset := parseQueryInt(r, "offset", 0)
+		optionID := parseQueryInt(r, "option_id", 0)
+
+		_, poll, ok := app.loadPoll(w, r, resolve)
+		if !ok {
+			return
+		}
+
+		if poll.Anonymous {
+			app.Unauthorized(w, r)
+			return
+		}
+
+		optionIDs, err := app.DB.PollOptionIDs(poll.ID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		found := false
+		for _, id := range optionIDs {
+			found = found || id == optionID
+		}
+		if !found {
+			app.badRequest(w, r, fmt.Errorf("invalid option_id: %d", optionID))
+			return
+		}
+
+		voters, err := app.DB.PollVoters(optionID, limit, offset)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		if voters == nil {
+			voters = []database.PollVoter{}
+		}
+
+		if err := response.JSON(w, http.StatusOK, map[string]any{"option_id": optionID, "voters": voters}); err != nil {
+			app.serverError(w, r, err)
+		}
+	}
+}
+
+// broadcastPoll sends the updated results of a poll to the users who can see it.
+// Failures are logged: the vote itself has been recorded.
+func (app *Application) broadcastPoll(target *pollTarget) {
+	if app.WSManager == nil {
+		return
+	}
+
+	results, err := app.DB.PollResults(target.event.TargetType, target.event.TargetID, 0)
+	if err != nil {
+		app.Logger.Error(err.Error())
+		return
+	}
+
+	event := target.event
+	event.Poll = json.RawMessage(results)
+	app.WSManager.BroadcastEvent(websocket.EventPollUpdated, event, target.viewers)
+}
@@ -1,38 +1,114 @@
 package api
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
 
+	"brainbook-api/internal/database"
 	"brainbook-api/internal/response"
 )
 
 // for home feed
+// Query parameters:
+//   - mode: chronological (default), following or ranked
+//   - limit: page size, 1-100 (default 25)
+//   - cursor: next page of the chronological and following modes
+//   - since: posts newer than this cursor, oldest first (polling)
+//   - offset: next page of the ranked mode
 func (app *Application) getPosts(w http.ResponseWriter, r *http.Request) {
 	contextUser := contextGetAuthenticatedUser(r)
-	// Retrieve paginated posts from the database
-	posts, err := app.DB.AllPostsByUserID(contextUser.ID)
+
+	mode := database.FeedMode(strings.ToLower(strings.TrimSpace(r.URL.Query().Get("mode"))))
+	switch mode {
+	case "":
+		mode = database.FeedChronological
+	case database.FeedChronological, database.FeedFollowing, database.FeedRanked:
+	default:
+		app.badRequest(w, r, fmt.Errorf("mode must be one of chronological, following or ranked"))
+		return
+	}
+
+	limit := parseQueryInt(r, "limit", 25)
+	if limit < 1 || limit > 100 {
+		app.badRequest(w, r, fmt.Errorf("limit must be between 1 and 100"))
+		return
+	}
+
+	after, err := parseQueryCursor(r, "cursor")
 	if err != nil {
-		app.serverError(w, r, err)
+		app.badRequest(w, r, err)
+		return
+	}
+	since, err := parseQueryCursor(r, "since")
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+	offset := parseQueryInt(r, "offset", 0)
+	if offset < 0 {
+		app.badRequest(w, r, fmt.Errorf("offset must not be negative"))
 		return
 	}
 
-	// Can look better, probably
-	var postsWithFullName []map[string]any
+	if mode == database.FeedRanked && (after != nil || since != nil) {
+		app.badRequest(w, r, fmt.Errorf("ranked mode is paginated with offset, not cursor or since"))
+		return
+	}
+	if after != nil && since != nil {
+		app.badRequest(w, r, fmt.Errorf("cursor and since cannot be combined"))
+		return
+	}
+
+	// Fetch one extra row to know whether another page exists
+	posts, err := app.DB.FeedPosts(contextUser.ID, database.FeedOptions{
+		Mode:   mode,
+		After:  after,
+		Since:  since,
+		Offset: offset,
+		Limit:  limit + 1,
+	})
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
 
-	for _, post := range posts {
-		postsWithFullName = append(postsWithFullName, map[string]any{
-			"user_full_name": post.FullName(),
-			"user_avatar":    post.Avatar,
-			"content":        post.Content,
-			"image":          post.File,
-			// AI suggets .UTC().Format(time.RFC3339). Not sure what difference it makes.
-			"created_at": post.CreatedAt.Format("2006-01-02T15:04:05Z07:00"),
-		})
+	hasMore := len(posts) > limit
+	if hasMore {
+		posts = posts[:limit]
 	}
 
-	// Prepare response with pagination metadata
 	responseData := map[string]any{
-		"posts": posts,
+		"posts":    posts,
+		"mode":     mode,
+		"has_more": hasMore,
+	}
+
+	switch {
+	case mode == database.FeedRanked:
+		if hasMore {
+			responseData["next_offset"] = offset + limit
+		}
+	case since != nil:
+		// Posts come oldest first; the newest one is where the next poll starts
+		sinceCursor := r.URL.Query().Get("since")
+		if len(posts) > 0 {
+			last := posts[len(posts)-1]
+			sinceCursor = encodeCursor(last.CreatedAt, last.ID)
+		}
+		responseData["since_cursor"] = sinceCursor
+	default:
+		var nextCursor string
+		if hasMore {
+			last := posts[len(posts)-1]
+			nextCursor = encodeCursor(last.CreatedAt, last.ID)
+		}
+		responseData["next_cursor"] = nextCursor
+
+		// The first page also tells the client where polling for new posts starts
+		if after == nil && len(posts) > 0 {
+			responseData["since_cursor"] = encodeCursor(posts[0].CreatedAt, posts[0].ID)
+		}
 	}
 
 	// Send the posts as JSON response
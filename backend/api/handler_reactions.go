@@ -0,0 +1,275 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"brainbook-api/api/websocket"
+	"brainbook-api/internal/database"
+	"brainbook-api/internal/reaction"
+	"brainbook-api/internal/request"
+	"brainbook-api/internal/response"
+	t "brainbook-api/internal/time"
+)
+
+// reactionTarget is the post, comment or message addressed by a reaction request.
+type reactionTarget struct {
+	// event carries the target identifiers broadcast with reaction updates.
+	event websocket.ReactionUpdatedEvent
+	// viewers matches the users who can see the target.
+	viewers func(userID int) bool
+}
+
+// reactionTargetResolver loads the target of a reaction route and checks that the authenticated
+// user can see it. It writes the error response and returns false otherwise.
+type reactionTargetResolver func(w http.ResponseWriter, r *http.Request) (*reactionTarget, bool)
+
+// getReactionKinds handles GET /protected/v1/reactions
+func (app *Application) getReactionKinds(w http.ResponseWriter, r *http.Request) {
+	if err := response.JSON(w, http.StatusOK, map[string]any{"kinds": reaction.Kinds}); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// toggleReaction returns the handler adding or removing a reaction of the authenticated user.
+func (app *Application) toggleReaction(resolve reactionTargetResolver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var input struct {
+			Kind string `json:"kind"`
+		}
+
+		if err := request.DecodeJSON(w, r, &input); err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+
+		kind := strings.ToLower(strings.TrimSpace(input.Kind))
+		if !reaction.IsValid(kind) {
+			app.badRequest(w, r, fmt.Errorf("unknown reaction: %s", input.Kind))
+			return
+		}
+
+		target, ok := resolve(w, r)
+		if !ok {
+			return
+		}
+
+		user := contextGetAuthenticatedUser(r)
+		event := target.event
+
+		reacted, err := app.DB.ToggleReaction(event.TargetType, event.TargetID, user.ID, kind, t.CurrentTime())
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		counts, err := app.DB.ReactionCounts(event.TargetType, event.TargetID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		if app.WSManager != nil {
+			event.UserID = user.ID
+			event.Kind = kind
+			event.Reacted = reacted
+			event.Counts = counts
+			app.WSManager.BroadcastEvent(websocket.EventReactionUpdated, event, target.viewers)
+		}
+
+		responseData := map[string]any{
+			"target_type": event.TargetType,
+			"target_id":   event.TargetID,
+			"kind":        kind,
+			"reacted":     reacted,
+			"counts":      counts,
+		}
+
+		if err := response.JSON(w, http.StatusOK, responseData); err != nil {
+			app.serverError(w, r, err)
+		}
+	}
+}
+
+// getReactors returns the handler listing who reacted to a target.
+// Query parameters: kind (optional filter), limit (1-100, default 50) and offset.
+func (app *Application) getReactors(resolve reactionTargetResolver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		kind := strings.ToLower(strings.TrimSpace(r.URL.Query().Get("kind")))
+		if kind != "" && !reaction.IsValid(kind) {
+			app.badRequest(w, r, fmt.Errorf("unknown reaction: %s", kind))
+			return
+		}
+
+		limit := parseQueryInt(r, "limit", 50)
+		if limit < 1 || limit > 100 {
+			app.badRequest(w, r, fmt.Errorf("limit must be between 1 and 100"))
+			return
+		}
+		offset := parseQueryInt(r, "offset", 0)
+
+		target, ok := resolve(w, r)
+		if !ok {
+			return
+		}
+
+		reactors, err := app.DB.Reactors(target.event.TargetType, target.event.TargetID, kind, limit, offset)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		responseData := map[string]any{
+			"target_type": target.event.TargetType,
+			"target_id":   target.event.TargetID,
+			"reactors":    reactors,
+		}
+
+		if err := response.JSON(w, http.StatusOK, responseData); err != nil {
+			app.serverError(w, r, err)
+		}
+	}
+}
+
+// requirePostViewer writes an error and returns false unless the authenticated user can view postID.
+func (app *Application) requirePostViewer(w http.ResponseWriter, r *http.Request, postID int) bool {
+	user := contextGetAuthenticatedUser(r)
+	canView, err := app.DB.CanUserViewPost(user.ID, postID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return false
+	}
+	if !canView {
+		app.Unauthorized(w, r)
+		return false
+	}
+	return true
+}
+
+// postReactionTarget resolves /protected/v1/posts/{post_id}/reactions
+func (app *Application) postReactionTarget(w http.ResponseWriter, r *http.Request) (*reactionTarget, bool) {
+	postID, _, ok := app.loadEditableContent(w, r, database.ContentPost, "post_id", 0)
+	if !ok || !app.requirePostViewer(w, r, postID) {
+		return nil, false
+	}
+
+	return &reactionTarget{
+		event:   websocket.ReactionUpdatedEvent{TargetType: database.ContentPost, TargetID: postID, PostID: postID},
+		viewers: app.postViewers(postID),
+	}, true
+}
+
+// commentReactionTarget resolves /protected/v1/posts/{post_id}/comments/{comment_id}/reactions
+func (app *Application) commentReactionTarget(w http.ResponseWriter, r *http.Request) (*reactionTarget, bool) {
+	postID, _, ok := app.loadEditableContent(w, r, database.ContentPost, "post_id", 0)
+	if !ok {
+		return nil, false
+	}
+
+	commentID, _, ok := app.loadEditableContent(w, r, database.ContentComment, "comment_id", postID)
+	if !ok || !app.requirePostViewer(w, r, postID) {
+		return nil, false
+	}
+
+	return &reactionTarget{
+		event:   websocket.ReactionUpdatedEvent{TargetType: database.ContentComment, TargetID: commentID, PostID: postID},
+		viewers: app.postViewers(postID),
+	}, true
+}
+
+// groupPostReactionTarget resolves /protected/v1/groups/{group_id}/posts/{post_id}/reactions
+func (app *Application) groupPostReactionTarget(w http.ResponseWriter, r *http.Request) (*reactionTarget, bool) {
+	group := contextGetGroup(r)
+
+	postID, _, ok := app.loadEditableContent(w, r, database.ContentGroupPost, "post_id", group.ID)
+	if !ok {
+		return nil, false
+	}
+
+	return &reactionTarget{
+		event:   websocket.ReactionUpdatedEvent{TargetType: database.ContentGroupPost, TargetID: postID, PostID: postID, GroupID: group.ID},
+		viewers: app.groupViewers(group),
+	}, true
+}
+
+// groupPostCommentReactionTarget resolves /protected/v1/groups/{group_id}/posts/{post_id}/comments/{comment_id}/reactions
+func (app *Application) groupPostCommentReactionTarget(w http.ResponseWriter, r *http.Request) (*reactionTarget, bool) {
+	group := contextGetGroup(r)
+
+	postID, _, ok := app.loadEditableContent(w, r, database.ContentGroupPost, "post_id", group.ID)
+	if !ok {
+		return nil, false
+	}
+
+	commentID, _, ok := app.loadEditableContent(w, r, database.ContentGroupPostComment, "comment_id", postID)
+	if !ok {
+		return nil, false
+	}
+
+	return &reactionTarget{
+		event:   websocket.ReactionUpdatedEvent{TargetType: database.ContentGroupPostComment, TargetID: commentID, PostID: postID, GroupID: group.ID},
+		viewers: app.groupViewers(group),
+	}, true
+}
+
+// messageReactionTarget resolves /protected/v1/private-messages/messages/{message_id}/reactions
+// Only the two users of the conversation can see and react to a direct message.
+func (app *Application) messageReactionTarget(w http.ResponseWriter, r *http.Request) (*reactionTarget, bool) {
+	messageIDStr := r.PathValue("message_id")
+	messageID, err := parseStringID(messageIDStr)
+	if err != nil || messageID <= 0 {
+		app.badRequest(w, r, fmt.Errorf("invalid message ID: %s", messageIDStr))
+		return nil, false
+	}
+
+	user1ID, user2ID, exists, err := app.DB.MessageParticipants(messageID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return nil, false
+	}
+	if !exists {
+		app.notFound(w, r)
+		return nil, false
+	}
+
+	user := contextGetAuthenticatedUser(r)
+	if user.ID != user1ID && user.ID != user2ID {
+		app.Unauthorized(w, r)
+		return nil, false
+	}
+
+	return &reactionTarget{
+		event: websocket.ReactionUpdatedEvent{TargetType: database.ContentMessage, TargetID: messageID},
+		viewers: func(userID int) bool {
+			return userID == user1ID || userID == user2ID
+		},
+	}, true
+}
+
+// groupMessageReactionTarget resolves /protected/v1/groups/{group_id}/messages/{message_id}/reactions
+func (app *Application) groupMessageReactionTarget(w http.ResponseWriter, r *http.Request) (*reactionTarget, bool) {
+	group := contextGetGroup(r)
+
+	messageIDStr := r.PathValue("message_id")
+	messageID, err := parseStringID(messageIDStr)
+	if err != nil || messageID <= 0 {
+		app.badRequest(w, r, fmt.Errorf("invalid message ID: %s", messageIDStr))
+		return nil, false
+	}
+
+	groupID, exists, err := app.DB.GroupMessageGroupID(messageID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return nil, false
+	}
+	if !exists || groupID != group.ID {
+		app.notFound(w, r)
+		return nil, false
+	}
+
+	return &reactionTarget{
+		event:   websocket.ReactionUpdatedEvent{TargetType: database.ContentGroupMessage, TargetID: messageID, GroupID: group.ID},
+		viewers: app.groupViewers(group),
+	}, true
+}
@@ -60,6 +60,8 @@ func (app *Application) createUser(w http.ResponseWriter, r *http.Request) {
 	input.Validator.CheckField(len(input.Avatar) <= 5_000_000, "avatar", "Avatar size limit exceeded (5MB)")
 	if len(input.Avatar) > 0 {
 		input.Validator.CheckField(isAllowedImage(input.Avatar), "avatar", "Avatar must be JPEG, PNG, or GIF")
+		// The user exists by the time the avatar is stored, so its dimensions are checked up front.
+		app.checkImageDimensions(&input.Validator, "avatar", input.Avatar)
 	}
 
 	// Email validation
@@ -87,11 +89,24 @@ func (app *Application) createUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err = app.DB.InsertUser(input.FName, input.LName, input.Email, hashedPassword, input.Nickname, input.Bio, input.DOB, input.Avatar)
+	userID, err := app.DB.InsertUser(input.FName, input.LName, input.Email, hashedPassword, input.Nickname, input.Bio, input.DOB)
 	if err != nil {
 		app.serverError(w, r, err)
 		return
 	}
 
+	// The avatar is owned by the new user, so it is stored once the account exists.
+	if len(input.Avatar) > 0 {
+		avatarID, err := app.storeMedia(userID, input.Avatar)
+		if err != nil {
+			app.mediaError(w, r, "avatar", err)
+			return
+		}
+		if err := app.DB.UpdateAvatar(userID, avatarID); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
 	w.WriteHeader(http.StatusNoContent)
 }
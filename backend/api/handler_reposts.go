@@ -0,0 +1,244 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"brainbook-api/internal/database"
+	"brainbook-api/internal/hashtag"
+	"brainbook-api/internal/policy"
+	"brainbook-api/internal/request"
+	"brainbook-api/internal/response"
+	t "brainbook-api/internal/time"
+	"brainbook-api/internal/validator"
+)
+
+// loadRepostOriginal loads the post addressed by {post_id} and returns the original to repost:
+// the post itself, or its original when it is a plain repost. The authenticated user must be
+// able to see the original. It writes the error response and returns false otherwise.
+func (app *Application) loadRepostOriginal(w http.ResponseWriter, r *http.Request) (int, *database.EditableContent, bool) {
+	postID, content, ok := app.loadEditableContent(w, r, database.ContentPost, "post_id", 0)
+	if !ok || !app.requirePostViewer(w, r, postID) {
+		return 0, nil, false
+	}
+
+	originalID, plain, err := app.DB.RepostOf(postID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return 0, nil, false
+	}
+	if originalID == 0 || !plain {
+		return postID, content, true
+	}
+
+	content, exists, err := app.DB.EditableContentByID(database.ContentPost, originalID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return 0, nil, false
+	}
+	if !exists {
+		app.notFound(w, r)
+		return 0, nil, false
+	}
+	if !app.requirePostViewer(w, r, originalID) {
+		return 0, nil, false
+	}
+
+	return originalID, content, true
+}
+
+// checkRepostAudience reports an error on v unless everybody in the audience of a repost can
+// already see the original: a repost never widens the audience of a post.
+func (app *Application) checkRepostAudience(v *validator.Validator, originalID int, original *database.EditableContent, originalVisibility, visibility string, allowedUserIDs []int, reposterID int) error {
+	const message = "This post can only be reposted to people who can already see it"
+
+	switch visibility {
+	case policy.VisibilityPublic:
+		v.CheckField(originalVisibility == policy.VisibilityPublic, "visibility", message)
+	case policy.VisibilityFollowers:
+		// The followers of the reposter see the original when it is public, or when the reposter
+		// is its author and it is followers-only.
+		v.CheckField(originalVisibility == policy.VisibilityPublic ||
+			(originalVisibility == policy.VisibilityFollowers && original.AuthorID == reposterID), "visibility", message)
+	case policy.VisibilityLimited:
+		for _, uid := range allowedUserIDs {
+			canView, err := app.DB.CanUserViewPost(uid, originalID)
+			if err != nil {
+				return err
+			}
+			if !canView {
+				v.AddFieldError("allowed_user_ids", message)
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// createRepost handles POST /protected/v1/posts/{post_id}/reposts
+// Without content it reposts the post, at most once per user; with content it creates a quote post.
+// Reposting a plain repost references its original.
+func (app *Application) createRepost(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Content        string              `json:"content"`
+		ContentFormat  string              `json:"content_format"`
+		Visibility     string              `json:"visibility"`
+		AllowedUserIDs []int               `json:"allowed_user_ids"`
+		Validator      validator.Validator `json:"-"`
+	}
+
+	if r.ContentLength != 0 {
+		if err := request.DecodeJSON(w, r, &input); err != nil {
+			app.badRequest(w, r, err)
+			return
+		}
+	}
+
+	originalID, original, ok := app.loadRepostOriginal(w, r)
+	if !ok {
+		return
+	}
+
+	user := contextGetAuthenticatedUser(r)
+	quote := strings.TrimSpace(input.Content) != ""
+
+	format := strings.ToLower(strings.TrimSpace(input.ContentFormat))
+	if format == "" {
+		format = database.FormatPlain
+	}
+
+	if quote {
+		validatePostContent(&input.Validator, "post-content", input.Content, format, nil)
+	} else {
+		_, reposted, err := app.DB.UserRepost(user.ID, originalID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		input.Validator.CheckField(!reposted, "post_id", "You already reposted this post")
+		input.Content = ""
+		format = database.FormatPlain
+	}
+
+	visibility, err := app.checkVisibility(&input.Validator, input.Visibility, input.AllowedUserIDs, user.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	originalVisibility, err := app.DB.PostVisibility(originalID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if err := app.checkRepostAudience(&input.Validator, originalID, original, originalVisibility, visibility, input.AllowedUserIDs, user.ID); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if input.Validator.HasErrors() {
+		app.failedValidation(w, r, input.Validator)
+		return
+	}
+
+	currentDateTime := t.CurrentTime()
+	contentHTML := renderContent(format, input.Content)
+
+	repostID, err := app.DB.InsertRepost(user.ID, originalID, input.Content, format, contentHTML, visibility, currentDateTime)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if visibility == policy.VisibilityLimited {
+		if err := app.DB.AddPostViewers(repostID, input.AllowedUserIDs); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	if quote {
+		if err := app.DB.IndexPostTags(repostID, hashtag.Parse(input.Content)); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		mentionPayload := map[string]interface{}{"post_id": repostID}
+		if err := app.indexMentions(database.ContentPost, repostID, input.Content, user, mentionPayload, app.postViewers(repostID)); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+
+		if err := app.refreshLinkPreview(r, repostID, input.Content); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
+
+	// The author of the original is only told about reposts they can see.
+	if original.AuthorID != user.ID {
+		canView, err := app.DB.CanUserViewPost(original.AuthorID, repostID)
+		if err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+		if canView {
+			app.notifyUser(original.AuthorID, NotificationTypeRepost, map[string]interface{}{
+				"post_id":        originalID,
+				"repost_id":      repostID,
+				"quote":          quote,
+				"user_id":        user.ID,
+				"user_full_name": user.FullName(),
+			})
+		}
+	}
+
+	responseData := map[string]any{
+		"post_id":              repostID,
+		"repost_of_id":         originalID,
+		"user_full_name":       user.FullName(),
+		"user_avatar_id":       user.AvatarID,
+		"user_avatar_alt_text": user.AvatarAltText,
+		"content":              input.Content,
+		"content_format":       format,
+		"content_html":         contentHTML,
+		"visibility":           visibility,
+		"created_at":           currentDateTime,
+	}
+
+	if err := response.JSON(w, http.StatusCreated, responseData); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// deleteRepost handles DELETE /protected/v1/posts/{post_id}/repost
+// It undoes the plain repost of the authenticated user; quote posts are deleted like other posts.
+func (app *Application) deleteRepost(w http.ResponseWriter, r *http.Request) {
+	postID, _, ok := app.loadEditableContent(w, r, database.ContentPost, "post_id", 0)
+	if !ok {
+		return
+	}
+
+	originalID, plain, err := app.DB.RepostOf(postID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if originalID == 0 || !plain {
+		originalID = postID
+	}
+
+	user := contextGetAuthenticatedUser(r)
+	repostID, exists, err := app.DB.UserRepost(user.ID, originalID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !exists {
+		app.notFound(w, r)
+		return
+	}
+
+	app.softDeleteContent(w, r, database.ContentPost, repostID)
+}
@@ -15,13 +15,15 @@ func (app *Application) getSessionProfile(w http.ResponseWriter, r *http.Request
 	}
 
 	payload := map[string]any{
-		"user_id":   user.ID,
-		"full_name": user.FullName(),
-		"email":     user.Email,
+		"user_id":          user.ID,
+		"full_name":        user.FullName(),
+		"email":            user.Email,
+		"require_alt_text": user.RequireAltText,
 	}
 
-	if len(user.Avatar) > 0 {
-		payload["avatar"] = user.Avatar
+	if user.AvatarID != nil {
+		payload["avatar_id"] = user.AvatarID
+		payload["avatar_alt_text"] = user.AvatarAltText
 	}
 
 	if err := response.JSON(w, http.StatusOK, payload); err != nil {
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"brainbook-api/internal/response"
+)
+
+// getMyStorage handles GET /protected/v1/storage
+// It returns the space taken by the uploads of the authenticated user, resized variants
+// included, against their quota (null when unlimited), and their largest files.
+func (app *Application) getMyStorage(w http.ResponseWriter, r *http.Request) {
+	limit := parseQueryInt(r, "limit", 10)
+	if limit < 1 || limit > 100 {
+		app.badRequest(w, r, fmt.Errorf("limit must be between 1 and 100"))
+		return
+	}
+
+	user := contextGetAuthenticatedUser(r)
+
+	usage, err := app.DB.UserStorageUsage(user.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	largest, err := app.DB.LargestUserMedia(user.ID, limit)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	responseData := map[string]any{
+		"used_bytes":    usage.Bytes,
+		"file_count":    usage.Files,
+		"quota_bytes":   quotaBytes(app.Config.Media.UserQuota),
+		"largest_files": largest,
+	}
+	if err := response.JSON(w, http.StatusOK, responseData); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// getGroupStorage handles GET /protected/v1/groups/{group_id}/storage
+// It returns the space taken by the files shared in the group against its quota.
+func (app *Application) getGroupStorage(w http.ResponseWriter, r *http.Request) {
+	group := contextGetGroup(r)
+
+	usage, err := app.DB.GroupStorageUsage(group.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	responseData := map[string]any{
+		"used_bytes":  usage.Bytes,
+		"file_count":  usage.Files,
+		"quota_bytes": quotaBytes(app.Config.Media.GroupQuota),
+	}
+	if err := response.JSON(w, http.StatusOK, responseData); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// getStorageReport handles GET /protected/v1/admin/storage
+// It returns the space taken by all media and the users and groups taking the most.
+func (app *Application) getStorageReport(w http.ResponseWriter, r *http.Request) {
+	limit := parseQueryInt(r, "limit", 25)
+	if limit < 1 || limit > 100 {
+		app.badRequest(w, r, fmt.Errorf("limit must be between 1 and 100"))
+		return
+	}
+
+	total, err := app.DB.TotalStorageUsage()
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	users, err := app.DB.TopUserStorage(limit)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	groups, err := app.DB.TopGroupStorage(limit)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	responseData := map[string]any{
+		"used_bytes":        total.Bytes,
+		"file_count":        total.Files,
+		"user_quota_bytes":  quotaBytes(app.Config.Media.UserQuota),
+		"group_quota_bytes": quotaBytes(app.Config.Media.GroupQuota),
+		"users":             users,
+		"groups":            groups,
+	}
+	if err := response.JSON(w, http.StatusOK, responseData); err != nil {
+		app.serverError(w, r, err)
+	}
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"brainbook-api/internal/hashtag"
+	"brainbook-api/internal/response"
+)
+
+// parseTagName reads and normalizes the {tag} path value.
+func parseTagName(r *http.Request) (string, error) {
+	rawTag := r.PathValue("tag")
+	tag, ok := hashtag.Normalize(rawTag)
+	if !ok {
+		return "", fmt.Errorf("invalid tag: %s", rawTag)
+	}
+	return tag, nil
+}
+
+// getTagPosts handles GET /protected/v1/tags/{tag}/posts
+// Returns the public posts indexed under the tag, and the group posts indexed under it in the
+// groups the user belongs to.
+func (app *Application) getTagPosts(w http.ResponseWriter, r *http.Request) {
+	user := contextGetAuthenticatedUser(r)
+
+	tagName, err := parseTagName(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	limit := parseQueryInt(r, "limit", 25)
+	offset := parseQueryInt(r, "offset", 0)
+
+	tag, exists, err := app.DB.TagByName(tagName)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !exists {
+		app.notFound(w, r)
+		return
+	}
+
+	posts, err := app.DB.PublicPostsByTag(user.ID, tagName, limit, offset)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	groupPosts, err := app.DB.GroupPostsByTag(user.ID, tagName, limit, offset)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	isFollowing, err := app.DB.IsFollowingTag(user.ID, tagName)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	responseData := map[string]any{
+		"tag":          tag,
+		"is_following": isFollowing,
+		"posts":        posts,
+		"group_posts":  groupPosts,
+	}
+
+	if err := response.JSON(w, http.StatusOK, responseData); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+// getFollowedTags handles GET /protected/v1/tags/following
+func (app *Application) getFollowedTags(w http.ResponseWriter, r *http.Request) {
+	user := contextGetAuthenticatedUser(r)
+
+	tags, err := app.DB.FollowedTags(user.ID)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	if err := response.JSON(w, http.StatusOK, map[string]any{"tags": tags}); err != nil {
+		app.serverError(w, r, err)
+	}
+}
+
+func (app *Application) followTag(w http.ResponseWriter, r *http.Request) {
+	user := contextGetAuthenticatedUser(r)
+
+	tagName, err := parseTagName(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	// Tags come into existence when a post uses them.
+	_, exists, err := app.DB.TagByName(tagName)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+	if !exists {
+		app.notFound(w, r)
+		return
+	}
+
+	if err := app.DB.FollowTag(user.ID, tagName); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	_ = response.JSON(w, http.StatusOK, map[string]any{"tag": tagName, "status": "following"})
+}
+
+func (app *Application) unfollowTag(w http.ResponseWriter, r *http.Request) {
+	user := contextGetAuthenticatedUser(r)
+
+	tagName, err := parseTagName(r)
+	if err != nil {
+		app.badRequest(w, r, err)
+		return
+	}
+
+	if err := app.DB.UnfollowTag(user.ID, tagName); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
+	_ = response.JSON(w, http.StatusOK, map[string]any{"tag": tagName, "status": "unfollowed"})
+}
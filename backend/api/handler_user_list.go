@@ -39,12 +39,13 @@ func (app *Application) getUserList(w http.ResponseWriter, r *http.Request) {
 		}
 
 		usersWithFullName = append(usersWithFullName, map[string]any{
-			"user_id":           listedUser.ID,
-			"user_full_name":    listedUser.FullName(),
-			"user_avatar":       listedUser.Avatar,
-			"last_message_time": listedUser.LastMessageTime,
-			"follows":           follows,
-			"followed_by":       followedBy,
+			"user_id":              listedUser.ID,
+			"user_full_name":       listedUser.FullName(),
+			"user_avatar_id":       listedUser.AvatarID,
+			"user_avatar_alt_text": listedUser.AvatarAltText,
+			"last_message_time":    listedUser.LastMessageTime,
+			"follows":              follows,
+			"followed_by":          followedBy,
 			"follow_request_status": func() any {
 				if followStatusExists {
 					return followStatus
@@ -35,17 +35,12 @@ func (app *Application) getUserProfile(w http.ResponseWriter, r *http.Request) {
 		viewerID = viewer.ID
 		isSelf = viewerID == targetUserID
 	}
-	isFollower := false
-	if viewer != nil && !targetUser.IsPublic && !isSelf {
-		isFollower, err = app.DB.IsFollowing(viewerID, targetUserID)
-		if err != nil {
-			app.serverError(w, r, err)
-			return
-		}
+	canViewPrivate, err := app.canViewProfile(viewerID, targetUser)
+	if err != nil {
+		app.serverError(w, r, err)
+		return
 	}
 
-	canViewPrivate := targetUser.IsPublic || isSelf || isFollower
-
 	followRequestStatus := ""
 	if viewer != nil && !isSelf {
 		status, exists, err := app.DB.FollowRequestStatus(viewerID, targetUserID)
@@ -67,12 +62,16 @@ func (app *Application) getUserProfile(w http.ResponseWriter, r *http.Request) {
 			"follow_request_status": followRequestStatus,
 		}
 
-		if targetUser.Avatar != nil {
-			userProfileResponse["avatar"] = targetUser.Avatar
+		if targetUser.AvatarID != nil {
+			userProfileResponse["avatar_id"] = targetUser.AvatarID
+			userProfileResponse["avatar_alt_text"] = targetUser.AvatarAltText
 		}
 		if targetUser.Nickname != "" {
 			userProfileResponse["nickname"] = targetUser.Nickname
 		}
+		if targetUser.Handle != nil {
+			userProfileResponse["handle"] = targetUser.Handle
+		}
 		if targetUser.Bio != "" {
 			userProfileResponse["bio"] = targetUser.Bio
 		}
@@ -125,12 +124,19 @@ func (app *Application) getUserProfile(w http.ResponseWriter, r *http.Request) {
 		"follow_request_status":         followRequestStatus,
 	}
 
-	if targetUser.Avatar != nil {
-		userProfileResponse["avatar"] = targetUser.Avatar
+	if targetUser.AvatarID != nil {
+		userProfileResponse["avatar_id"] = targetUser.AvatarID
+		userProfileResponse["avatar_alt_text"] = targetUser.AvatarAltText
+	}
+	if isSelf {
+		userProfileResponse["require_alt_text"] = targetUser.RequireAltText
 	}
 	if targetUser.Nickname != "" {
 		userProfileResponse["nickname"] = targetUser.Nickname
 	}
+	if targetUser.Handle != nil {
+		userProfileResponse["handle"] = targetUser.Handle
+	}
 	if targetUser.Bio != "" {
 		userProfileResponse["bio"] = targetUser.Bio
 	}
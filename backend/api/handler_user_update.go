@@ -13,11 +13,14 @@ func (app *Application) updateProfile(w http.ResponseWriter, r *http.Request) {
 
 	// Use pointers to detect presence vs. absence
 	var input struct {
-		Nickname  *string             `json:"nickname,omitempty"`
-		Bio       *string             `json:"bio,omitempty"`
-		Avatar    *[]byte             `json:"avatar,omitempty"` // base64 in JSON -> []byte
-		IsPublic  *bool               `json:"is_public,omitempty"`
-		Validator validator.Validator `json:"-"`
+		Nickname *string `json:"nickname,omitempty"`
+		Bio      *string `json:"bio,omitempty"`
+		Avatar   *[]byte `json:"avatar,omitempty"`    // base64 in JSON -> []byte
+		AvatarID *int    `json:"avatar_id,omitempty"` // id from POST /protected/v1/media
+		IsPublic *bool   `json:"is_public,omitempty"`
+		// RequireAltText makes alt text mandatory on the images the user posts.
+		RequireAltText *bool               `json:"require_alt_text,omitempty"`
+		Validator      validator.Validator `json:"-"`
 	}
 
 	if err := request.DecodeJSON(w, r, &input); err != nil {
@@ -53,17 +56,38 @@ func (app *Application) updateProfile(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	var inlineAvatar []byte
+	if input.Avatar != nil {
+		inlineAvatar = *input.Avatar
+	}
+	if err := app.checkUpload(&v, "avatar_id", input.AvatarID, inlineAvatar, targetUserID); err != nil {
+		app.serverError(w, r, err)
+		return
+	}
+
 	if v.HasErrors() {
 		app.failedValidation(w, r, v)
 		return
 	}
 
 	// If nothing to update, return 204 without hitting DB
-	if input.Nickname == nil && input.Bio == nil && input.Avatar == nil && input.IsPublic == nil {
+	if input.Nickname == nil && input.Bio == nil && input.Avatar == nil && input.AvatarID == nil && input.IsPublic == nil && input.RequireAltText == nil {
 		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
+	// The avatar goes first: it is the only update that can still be rejected.
+	if input.Avatar != nil || input.AvatarID != nil {
+		avatarID, err := app.attachMedia(targetUserID, input.AvatarID, inlineAvatar)
+		if err != nil {
+			app.mediaError(w, r, "avatar", err)
+			return
+		}
+		if err := app.DB.UpdateAvatar(targetUserID, avatarID); err != nil {
+			app.serverError(w, r, err)
+			return
+		}
+	}
 	if input.Nickname != nil {
 		if err := app.DB.UpdateNickname(targetUserID, *input.Nickname); err != nil {
 			app.serverError(w, r, err)
@@ -76,14 +100,14 @@ func (app *Application) updateProfile(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
-	if input.Avatar != nil {
-		if err := app.DB.UpdateAvatar(targetUserID, *input.Avatar); err != nil {
+	if input.IsPublic != nil {
+		if err := app.DB.UpdatePrivacy(targetUserID, *input.IsPublic); err != nil {
 			app.serverError(w, r, err)
 			return
 		}
 	}
-	if input.IsPublic != nil {
-		if err := app.DB.UpdatePrivacy(targetUserID, *input.IsPublic); err != nil {
+	if input.RequireAltText != nil {
+		if err := app.DB.UpdateRequireAltText(targetUserID, *input.RequireAltText); err != nil {
 			app.serverError(w, r, err)
 			return
 		}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"brainbook-api/internal/database"
+	"brainbook-api/internal/imaging"
+	t "brainbook-api/internal/time"
+	"brainbook-api/internal/unfurl"
+)
+
+// linkPreviewTTL is how long a fetched preview, or a failure, is reused for other posts linking
+// the same URL instead of fetching it again.
+const linkPreviewTTL = 24 * time.Hour
+
+// refreshLinkPreview records the first link of new or edited post content and fetches its
+// preview after the response has been sent. Unchanged links keep their preview.
+func (app *Application) refreshLinkPreview(r *http.Request, postID int, content string) error {
+	if app.Unfurler == nil {
+		return nil
+	}
+
+	link := unfurl.FindURL(content)
+	changed, err := app.DB.SetPostLink(postID, link)
+	if err != nil || !changed {
+		return err
+	}
+
+	app.backgroundTask(r, func() error {
+		return app.fetchLinkPreview(postID, link)
+	})
+	return nil
+}
+
+// fetchLinkPreview completes the pending preview of a post link, from the cache when the URL was
+// fetched recently. Pages that cannot be previewed are recorded as failed and only logged.
+// Images are stored like uploads, see storeLinkPreviewImage; one that cannot be stored is left out.
+func (app *Application) fetchLinkPreview(postID int, link string) error {
+	freshSince := time.Now().UTC().Add(-linkPreviewTTL).Format("2006-01-02 15:04:05")
+	cached, found, err := app.DB.CachedLinkPreview(link, freshSince)
+	if err != nil {
+		return err
+	}
+	if found {
+		return app.DB.CompletePostLinkPreview(postID, *cached, t.CurrentTime())
+	}
+
+	result := database.LinkPreview{URL: link, Status: database.LinkPreviewFailed}
+
+	preview, err := app.Unfurler.Unfurl(context.Background(), link)
+	if err != nil {
+		app.Logger.Info("link preview failed", slog.String("url", link), slog.String("error", err.Error()))
+	} else {
+		result.Status = database.LinkPreviewReady
+		result.Title = preview.Title
+		result.Description = preview.Description
+		result.SiteName = preview.SiteName
+
+		if preview.Image != nil {
+			image, err := app.storeLinkPreviewImage(preview)
+			if err != nil {
+				return err
+			}
+			if image != nil {
+				result.ImageURL = preview.ImageURL
+				result.ImageID = &image.ID
+			}
+		}
+	}
+
+	return app.DB.CompletePostLinkPreview(postID, result, t.CurrentTime())
+}
+
+// storeLinkPreviewImage stores the image of a preview with its alt text. The image comes from a
+// third-party page rather than the post author, so it is owned by database.SystemOwnerID and
+// counts against no quota. It returns nil, only logging why, for images that cannot be decoded
+// or exceed the configured limits.
+func (app *Application) storeLinkPreviewImage(preview *unfurl.Preview) (*database.Media, error) {
+	image, err := app.storeImage(database.SystemOwnerID, preview.Image)
+	switch {
+	case errors.Is(err, imaging.ErrInvalid), errors.Is(err, imaging.ErrTooLarge), errors.Is(err, imaging.ErrTooManyFrames):
+		app.Logger.Info("link preview image skipped", slog.String("url", preview.ImageURL), slog.String("error", err.Error()))
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	if preview.ImageAlt != "" {
+		if err := app.DB.UpdateMediaAltText(image.ID, &preview.ImageAlt); err != nil {
+			return nil, err
+		}
+	}
+	return image, nil
+}
@@ -0,0 +1,487 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"brainbook-api/internal/database"
+	"brainbook-api/internal/imaging"
+	"brainbook-api/internal/media"
+	t "brainbook-api/internal/time"
+	"brainbook-api/internal/validator"
+)
+
+// legacyMediaBatch is how many database-held files are moved to the store per query.
+const legacyMediaBatch = 20
+
+// mediaVariants are the resized copies made of every uploaded image, selected with the variant
+// query parameter of GET /protected/v1/media/{media_id}. Resized GIFs keep all their frames;
+// animated GIFs also get a still preview of their first frame, for feeds and notifications that
+// must not autoplay, and a short cut of their first frames.
+var mediaVariants = []imaging.Variant{
+	{Name: "avatar_small", Width: 48, Height: 48, Crop: true},
+	{Name: "avatar", Width: 256, Height: 256, Crop: true},
+	{Name: "thumbnail", Width: 320, Height: 320},
+	{Name: "feed", Width: 1080, Height: 1350},
+	{Name: "preview", Width: 1080, Height: 1350, Static: true},
+	{Name: "short", MaxFrames: 50},
+}
+
+// isMediaVariant reports whether name is one of mediaVariants.
+func isMediaVariant(name string) bool {
+	for _, v := range mediaVariants {
+		if v.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// storeMedia saves an uploaded file to the media store and records it for ownerID.
+// It returns nil for an empty upload.
+func (app *Application) storeMedia(ownerID int, data []byte) (*int, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	file, err := app.storeImage(ownerID, data)
+	if err != nil {
+		return nil, err
+	}
+	return &file.ID, nil
+}
+
+// storeImage strips the metadata of an uploaded image, stores it with its resized variants and
+// records it for ownerID. Images that cannot be decoded or exceed the configured dimensions fail
+// with an imaging error, and images that do not fit in the quota of ownerID with
+// errQuotaExceeded, see mediaError.
+func (app *Application) storeImage(ownerID int, data []byte) (*database.Media, error) {
+	img, err := imaging.Process(data, app.Config.Media.ImageLimits)
+	if err != nil {
+		return nil, err
+	}
+	variants, err := resizeImage(img)
+	if err != nil {
+		return nil, err
+	}
+
+	total := int64(len(img.Data))
+	for _, v := range variants {
+		total += int64(len(v.data))
+	}
+
+	unlock := app.lockUserQuota(ownerID)
+	defer unlock()
+	if err := app.checkUserQuota(ownerID, total); err != nil {
+		return nil, err
+	}
+
+	key, size, err := app.Media.Put(bytes.NewReader(img.Data))
+	if err != nil {
+		return nil, err
+	}
+
+	file := &database.Media{
+		OwnerID:    ownerID,
+		SHA256:     key,
+		MimeType:   img.MimeType(),
+		Size:       size,
+		Width:      &img.Width,
+		Height:     &img.Height,
+		ScanStatus: database.MediaScanPending,
+	}
+	file.ID, err = app.DB.InsertMedia(ownerID, key, file.MimeType, size, file.Width, file.Height, nil, t.CurrentTime())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := app.storeVariants(file.ID, variants); err != nil {
+		return nil, err
+	}
+	app.wakeMediaScanner()
+	return file, nil
+}
+
+// storeFile stores an upload of a type other than images, read from r, and records it for
+// ownerID under fileName. Text types fail with media.ErrNotText unless they are UTF-8, and files
+// that do not fit in the quota of ownerID with errQuotaExceeded. r is spooled to a temporary
+// file first, so that a slow upload does not hold the quota lock of ownerID.
+func (app *Application) storeFile(ownerID int, kind media.Type, fileName string, r io.Reader) (*database.Media, error) {
+	if kind.Text {
+		r = media.TextReader(r)
+	}
+
+	// Spooled next to the store, whose disk is sized for uploads unlike the system temp dir.
+	spool, err := os.CreateTemp(filepath.Join(app.Config.Media.Dir, "tmp"), "spool-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	spooled, err := io.Copy(spool, r)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	unlock := app.lockUserQuota(ownerID)
+	defer unlock()
+	if err := app.checkUserQuota(ownerID, spooled); err != nil {
+		return nil, err
+	}
+
+	key, size, err := app.Media.Put(spool)
+	if err != nil {
+		return nil, err
+	}
+
+	file := &database.Media{
+		OwnerID:    ownerID,
+		SHA256:     key,
+		MimeType:   kind.MimeType,
+		Size:       size,
+		FileName:   cleanFileName(fileName),
+		ScanStatus: database.MediaScanPending,
+	}
+	file.ID, err = app.DB.InsertMedia(ownerID, key, file.MimeType, size, nil, nil, file.FileName, t.CurrentTime())
+	if err != nil {
+		return nil, err
+	}
+	app.wakeMediaScanner()
+	return file, nil
+}
+
+// maxFileNameBytes bounds the stored name of uploaded files.
+const maxFileNameBytes = 255
+
+// cleanFileName returns the last element of a client supplied file name without control
+// characters, truncated to maxFileNameBytes, or nil when nothing is left.
+func cleanFileName(name string) *string {
+	if i := strings.LastIndexAny(name, `/\`); i >= 0 {
+		name = name[i+1:]
+	}
+	name = strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == '"' {
+			return -1
+		}
+		return r
+	}, strings.ToValidUTF8(name, "")))
+
+	for len(name) > maxFileNameBytes {
+		_, size := utf8.DecodeLastRuneInString(name)
+		name = name[:len(name)-size]
+	}
+	if name == "" || name == "." || name == ".." {
+		return nil
+	}
+	return &name
+}
+
+// resizedImage is a variant of an image waiting to be stored.
+type resizedImage struct {
+	variant database.MediaVariant
+	data    []byte
+}
+
+// resizeImage makes the mediaVariants of img that are smaller than the image itself. It runs
+// before anything is stored, since decoding is where corrupt images fail.
+func resizeImage(img *imaging.Image) ([]resizedImage, error) {
+	var resized []resizedImage
+	for _, v := range mediaVariants {
+		data, width, height, ok, err := img.Resize(v)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+
+		resized = append(resized, resizedImage{
+			variant: database.MediaVariant{
+				Name:     v.Name,
+				MimeType: img.VariantMimeType(v),
+				Width:    width,
+				Height:   height,
+			},
+			data: data,
+		})
+	}
+	return resized, nil
+}
+
+// storeVariants saves the output of resizeImage for mediaID.
+func (app *Application) storeVariants(mediaID int, resized []resizedImage) error {
+	for _, r := range resized {
+		key, size, err := app.Media.Put(bytes.NewReader(r.data))
+		if err != nil {
+			return err
+		}
+
+		variant := r.variant
+		variant.MediaID = mediaID
+		variant.SHA256 = key
+		variant.Size = size
+		if err := app.DB.InsertMediaVariant(variant); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// imageTooLarge is the validation error for images beyond the configured dimensions.
+const imageTooLarge = "Image dimensions are too large"
+
+// tooManyFrames is the validation error for GIFs beyond the configured frame limits.
+func (app *Application) tooManyFrames() string {
+	return fmt.Sprintf("Animations can have at most %d frames, fewer at large sizes", app.Config.Media.ImageLimits.MaxFrames)
+}
+
+// checkImageDimensions reports on v an image whose header declares dimensions, or a GIF whose
+// blocks hold frames, beyond the configured limits, for handlers that must reject it before
+// storing anything.
+func (app *Application) checkImageDimensions(v *validator.Validator, field string, data []byte) {
+	err := imaging.Check(data, app.Config.Media.ImageLimits)
+	switch {
+	case errors.Is(err, imaging.ErrTooLarge):
+		v.AddFieldError(field, imageTooLarge)
+	case errors.Is(err, imaging.ErrTooManyFrames):
+		v.AddFieldError(field, app.tooManyFrames())
+	}
+}
+
+// mediaError writes the response for an error of storeMedia or attachMedia: a validation error
+// under field for images that cannot be decoded or are too large and for files over the storage
+// quota, a server error otherwise.
+func (app *Application) mediaError(w http.ResponseWriter, r *http.Request, field string, err error) {
+	var v validator.Validator
+	switch {
+	case errors.Is(err, imaging.ErrTooLarge):
+		v.AddFieldError(field, imageTooLarge)
+	case errors.Is(err, imaging.ErrTooManyFrames):
+		v.AddFieldError(field, app.tooManyFrames())
+	case errors.Is(err, imaging.ErrInvalid):
+		v.AddFieldError(field, "Image could not be read")
+	case errors.Is(err, errQuotaExceeded):
+		v.AddFieldError(field, app.userQuotaFull())
+	default:
+		app.serverError(w, r, err)
+		return
+	}
+	app.failedValidation(w, r, v)
+}
+
+// checkUpload validates a reference to a file uploaded through POST /protected/v1/media, which
+// must be one of ownerID's uploads not rejected by the malware scan, with alt text when ownerID
+// requires it. inline is the base64 file the same request may carry instead; the two cannot be
+// combined, and inline files, which have no alt text, are refused to users requiring it.
+// Errors are reported on v under field.
+func (app *Application) checkUpload(v *validator.Validator, field string, uploadID *int, inline []byte, ownerID int) error {
+	if uploadID == nil {
+		if len(inline) == 0 {
+			return nil
+		}
+		required, err := app.requiresAltText(ownerID)
+		if err != nil {
+			return err
+		}
+		v.CheckField(!required, field, "Upload images through /protected/v1/media to give them alt text")
+		return nil
+	}
+	v.CheckField(len(inline) == 0, field, "Provide either an inline file or an upload, not both")
+
+	upload, exists, err := app.DB.MediaByID(*uploadID)
+	if err != nil {
+		return err
+	}
+	v.CheckField(exists && upload.OwnerID == ownerID, field, "Upload not found")
+	v.CheckField(!exists || isImage(upload.MimeType), field, "Upload must be an image")
+	v.CheckField(!exists || !scanFailed(upload), field, "Upload was rejected by the malware scan")
+
+	if exists && isImage(upload.MimeType) && upload.AltText == nil {
+		required, err := app.requiresAltText(ownerID)
+		if err != nil {
+			return err
+		}
+		v.CheckField(!required, field, "Add alt text to the image before posting it")
+	}
+	return nil
+}
+
+// checkAttachments validates the uploads attached to a post by ownerID: at most
+// database.MaxAttachments of their own uploads, each once, none rejected by the malware scan,
+// images with alt text when ownerID requires it. Errors are reported on v.
+func (app *Application) checkAttachments(v *validator.Validator, mediaIDs []int, ownerID int) error {
+	if len(mediaIDs) == 0 {
+		return nil
+	}
+	v.CheckField(len(mediaIDs) <= database.MaxAttachments, "attachment_ids", fmt.Sprintf("At most %d files can be attached", database.MaxAttachments))
+
+	required, err := app.requiresAltText(ownerID)
+	if err != nil {
+		return err
+	}
+
+	for i, mediaID := range mediaIDs {
+		if slices.Contains(mediaIDs[:i], mediaID) {
+			v.AddFieldError("attachment_ids", "Each file can only be attached once")
+			return nil
+		}
+
+		upload, exists, err := app.DB.MediaByID(mediaID)
+		if err != nil {
+			return err
+		}
+		if !exists || upload.OwnerID != ownerID {
+			v.AddFieldError("attachment_ids", fmt.Sprintf("Upload %d not found", mediaID))
+			return nil
+		}
+		if scanFailed(upload) {
+			v.AddFieldError("attachment_ids", fmt.Sprintf("Upload %d was rejected by the malware scan", mediaID))
+			return nil
+		}
+		if required && isImage(upload.MimeType) && upload.AltText == nil {
+			v.AddFieldError("attachment_ids", fmt.Sprintf("Add alt text to upload %d before posting it", mediaID))
+			return nil
+		}
+	}
+	return nil
+}
+
+// maxAltTextRunes bounds the alt text of media.
+const maxAltTextRunes = 1500
+
+// checkAltText validates alt text written for media, reported on v under field. It returns the
+// text trimmed, or nil when blank, which removes the alt text.
+func checkAltText(v *validator.Validator, field, text string) *string {
+	text = strings.TrimSpace(text)
+	v.CheckField(validator.MaxRunes(text, maxAltTextRunes), field, fmt.Sprintf("Alt text must be %d characters or less", maxAltTextRunes))
+	if text == "" {
+		return nil
+	}
+	return &text
+}
+
+// requiresAltText reports whether userID turned on the setting that requires alt text on the
+// images they post.
+func (app *Application) requiresAltText(userID int) (bool, error) {
+	user, exists, err := app.DB.UserById(userID)
+	if err != nil {
+		return false, err
+	}
+	return exists && user.RequireAltText, nil
+}
+
+// mediaAltText returns the alt text of the media mediaID refers to, for responses; nil when
+// there is no media or it has no alt text.
+func (app *Application) mediaAltText(mediaID *int) (*string, error) {
+	if mediaID == nil {
+		return nil, nil
+	}
+	file, exists, err := app.DB.MediaByID(*mediaID)
+	if err != nil || !exists {
+		return nil, err
+	}
+	return file.AltText, nil
+}
+
+// scanFailed reports whether the malware scan rejected file or could not scan it, which
+// keeps it hidden for good. Pending files may be referenced; they show once found clean.
+func scanFailed(file *database.Media) bool {
+	return file.ScanStatus == database.MediaScanRejected || file.ScanStatus == database.MediaScanError
+}
+
+// CheckMessageAttachments validates the files attached to a chat message by senderID like those
+// of posts, with the quota of the group for group messages; groupID is 0 for direct messages.
+// It is the websocket.WebsocketManager CheckAttachments hook.
+func (app *Application) CheckMessageAttachments(v *validator.Validator, senderID, groupID int, mediaIDs []int) error {
+	if err := app.checkAttachments(v, mediaIDs, senderID); err != nil {
+		return err
+	}
+	if groupID == 0 || v.HasErrors() {
+		return nil
+	}
+	return app.checkGroupQuota(v, "attachment_ids", groupID, nil, nil, mediaIDs)
+}
+
+// isImage reports whether files of mimeType are images, shown inline rather than downloaded.
+func isImage(mimeType string) bool {
+	return strings.HasPrefix(mimeType, "image/")
+}
+
+// attachMedia returns the media to attach to new content: the upload checked by checkUpload,
+// or else the inline file, stored for ownerID. It returns nil when there is neither.
+func (app *Application) attachMedia(ownerID int, uploadID *int, inline []byte) (*int, error) {
+	if uploadID != nil {
+		return uploadID, nil
+	}
+	return app.storeMedia(ownerID, inline)
+}
+
+// ExtractLegacyMedia moves the file bytes that the media migration took out of content rows
+// from the database to the media store. It runs on startup, before requests are served, and
+// resumes where it stopped if interrupted. Images are processed like new uploads; files that
+// cannot be processed are moved as they are.
+func (app *Application) ExtractLegacyMedia() error {
+	extracted := 0
+
+	for {
+		rows, err := app.DB.LegacyMediaBatch(legacyMediaBatch)
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			break
+		}
+
+		for _, row := range rows {
+			if err := app.extractLegacyMedia(row); err != nil {
+				return err
+			}
+			extracted++
+		}
+	}
+
+	if extracted > 0 {
+		app.Logger.Info("moved database files to the media store", slog.Int("files", extracted))
+	}
+	return nil
+}
+
+func (app *Application) extractLegacyMedia(row database.LegacyMedia) error {
+	img, err := imaging.Process(row.Data, app.Config.Media.ImageLimits)
+	var variants []resizedImage
+	if err == nil {
+		variants, err = resizeImage(img)
+	}
+	if err != nil {
+		app.Logger.Warn("legacy media kept unprocessed", slog.Int("media_id", row.ID), slog.String("error", err.Error()))
+
+		key, size, err := app.Media.Put(bytes.NewReader(row.Data))
+		if err != nil {
+			return err
+		}
+		return app.DB.CompleteLegacyMedia(row.ID, key, media.DetectType(row.Data), size, nil, nil)
+	}
+
+	key, size, err := app.Media.Put(bytes.NewReader(img.Data))
+	if err != nil {
+		return err
+	}
+	if err := app.storeVariants(row.ID, variants); err != nil {
+		return err
+	}
+	return app.DB.CompleteLegacyMedia(row.ID, key, img.MimeType(), size, &img.Width, &img.Height)
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"brainbook-api/internal/database"
+	"brainbook-api/internal/media"
+	"brainbook-api/internal/scan"
+	t "brainbook-api/internal/time"
+)
+
+// mediaScanBatch is how many pending files are read per query by the malware scanner.
+const mediaScanBatch = 20
+
+// scanPendingMedia runs the malware scan of the files waiting in quarantine. Clean files become
+// visible to others; rejected files, and files the scanner cannot handle, stay hidden for good
+// and their uploader is notified. Other scanner errors, such as an unreachable daemon, end the
+// pass and leave the files pending for the next one.
+func (app *Application) scanPendingMedia(ctx context.Context) error {
+	scanned, rejected := 0, 0
+
+	for {
+		files, err := app.DB.PendingScanMedia(mediaScanBatch)
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			break
+		}
+
+		for _, file := range files {
+			status, result, err := app.scanMedia(ctx, file)
+			if err != nil {
+				return err
+			}
+			if err := app.DB.CompleteMediaScan(file.ID, status, result, t.CurrentTime()); err != nil {
+				return err
+			}
+
+			scanned++
+			if status != database.MediaScanClean {
+				rejected++
+				app.notifyMediaRejected(file, status, result)
+			}
+		}
+	}
+
+	if scanned > 0 {
+		app.Logger.Info("scanned uploaded media", slog.Int("files", scanned), slog.Int("rejected", rejected))
+	}
+	return nil
+}
+
+// scanMedia scans one stored file and returns its scan status, with the signature found or the
+// reason it could not be scanned. The error is only set for failures worth retrying.
+func (app *Application) scanMedia(ctx context.Context, file database.Media) (string, *string, error) {
+	content, err := app.Media.Open(file.SHA256)
+	if errors.Is(err, media.ErrNotFound) {
+		reason := "file missing from the media store"
+		return database.MediaScanError, &reason, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	defer content.Close()
+
+	result, err := app.Scanner.Scan(ctx, content)
+	if errors.Is(err, scan.ErrUnscannable) {
+		reason := err.Error()
+		app.Logger.Warn("media could not be scanned", slog.Int("media_id", file.ID), slog.String("error", reason))
+		return database.MediaScanError, &reason, nil
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	if !result.Clean {
+		app.Logger.Warn("media rejected by the malware scan", slog.Int("media_id", file.ID), slog.String("signature", result.Signature))
+		return database.MediaScanRejected, &result.Signature, nil
+	}
+	return database.MediaScanClean, nil, nil
+}
+
+// notifyMediaRejected tells the uploader that a file will not be shown to anyone.
+func (app *Application) notifyMediaRejected(file database.Media, status string, result *string) {
+	payload := map[string]interface{}{
+		"media_id":    file.ID,
+		"mime_type":   file.MimeType,
+		"file_name":   file.FileName,
+		"scan_status": status,
+	}
+	if status == database.MediaScanRejected {
+		payload["signature"] = *result
+	}
+	app.notifyUser(file.OwnerID, NotificationTypeMediaRejected, payload)
+}
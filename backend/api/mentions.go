@@ -0,0 +1,29 @@
+package api
+
+import (
+	"brainbook-api/internal/database"
+	"brainbook-api/internal/mention"
+)
+
+// indexMentions stores the @mentions of new or edited content and sends a mention notification
+// to each newly mentioned user that canSee the content. The author is never notified.
+// payload identifies the content; the target type and the author are added to it.
+func (app *Application) indexMentions(targetType string, targetID int, content string, author *database.User, payload map[string]interface{}, canSee func(userID int) bool) error {
+	mentioned, err := app.DB.IndexMentions(targetType, targetID, mention.Parse(content))
+	if err != nil {
+		return err
+	}
+
+	payload["target_type"] = targetType
+	payload["user_id"] = author.ID
+	payload["user_full_name"] = author.FullName()
+
+	for _, userID := range mentioned {
+		if userID == author.ID || !canSee(userID) {
+			continue
+		}
+		app.notifyUser(userID, NotificationTypeMention, payload)
+	}
+
+	return nil
+}
@@ -3,6 +3,9 @@ package api
 import (
 	"encoding/json"
 	"log"
+
+	"brainbook-api/internal/database"
+	"brainbook-api/internal/mention"
 )
 
 const (
@@ -12,6 +15,14 @@ const (
 	NotificationTypeGroupJoin     = "group_join_request"
 	NotificationTypeGroupEvent    = "group_event"
 	NotificationTypeFollowRequest = "follow_request"
+	NotificationTypeCommentReply  = database.NotificationCommentReply
+	NotificationTypeMention       = mention.NotificationType
+	NotificationTypeRepost        = database.NotificationRepost
+	// NotificationTypeMediaRejected tells an uploader that the malware scan rejected a file.
+	NotificationTypeMediaRejected = "media_rejected"
+	// NotificationTypeFollowRequestSummary is a single, continuously updated notification
+	// counting the pending follow requests of a user.
+	NotificationTypeFollowRequestSummary = "follow_request_summary"
 )
 
 func (app *Application) notifyUser(userID int, notifType string, payload map[string]interface{}) {
@@ -39,3 +50,62 @@ func (app *Application) notifyUser(userID int, notifType string, payload map[str
 		app.WSManager.PushNotification(notif)
 	}
 }
+
+// refreshFollowRequestSummary keeps one unread summary notification of the pending follow
+// requests of userID up to date, instead of creating a notification per request.
+// latestRequesterID is set when a new request arrived; it bumps the notification to the top.
+func (app *Application) refreshFollowRequestSummary(userID int, latestRequesterID int) {
+	if app.DB == nil {
+		return
+	}
+
+	count, err := app.DB.PendingFollowRequestsCount(userID)
+	if err != nil {
+		log.Printf("refreshFollowRequestSummary count error: %v", err)
+		return
+	}
+
+	if count == 0 {
+		if err := app.DB.MarkNotificationsReadByType(userID, NotificationTypeFollowRequestSummary); err != nil {
+			log.Printf("refreshFollowRequestSummary mark read error: %v", err)
+		}
+		return
+	}
+
+	existing, found, err := app.DB.LatestUnreadNotification(userID, NotificationTypeFollowRequestSummary)
+	if err != nil {
+		log.Printf("refreshFollowRequestSummary DB error: %v", err)
+		return
+	}
+
+	payload := map[string]interface{}{"pending_count": count}
+	if latestRequesterID > 0 {
+		payload["latest_requester_id"] = latestRequesterID
+	} else if found {
+		var previous map[string]interface{}
+		if err := json.Unmarshal(existing.Payload, &previous); err == nil && previous["latest_requester_id"] != nil {
+			payload["latest_requester_id"] = previous["latest_requester_id"]
+		}
+	}
+
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("refreshFollowRequestSummary marshal payload error: %v", err)
+		return
+	}
+
+	var notif *database.Notification
+	if found {
+		notif, err = app.DB.UpdateNotificationPayload(existing.ID, payloadBytes, latestRequesterID > 0)
+	} else {
+		notif, err = app.DB.CreateNotification(userID, NotificationTypeFollowRequestSummary, payloadBytes)
+	}
+	if err != nil {
+		log.Printf("refreshFollowRequestSummary DB error: %v", err)
+		return
+	}
+
+	if app.WSManager != nil {
+		app.WSManager.PushNotification(notif)
+	}
+}
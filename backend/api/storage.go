@@ -0,0 +1,131 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"brainbook-api/internal/database"
+	"brainbook-api/internal/validator"
+)
+
+// errQuotaExceeded is returned when a file does not fit in the storage quota of its uploader,
+// see mediaError.
+var errQuotaExceeded = errors.New("storage quota exceeded")
+
+// userQuotaLeft returns how many bytes userID may still upload. limited is false when the user
+// quota is disabled, and for database.SystemOwnerID.
+func (app *Application) userQuotaLeft(userID int) (left int64, limited bool, err error) {
+	if app.Config.Media.UserQuota <= 0 || userID == database.SystemOwnerID {
+		return 0, false, nil
+	}
+
+	usage, err := app.DB.UserStorageUsage(userID)
+	if err != nil {
+		return 0, false, err
+	}
+	return app.Config.Media.UserQuota - usage.Bytes, true, nil
+}
+
+// checkUserQuota returns errQuotaExceeded when storing size more bytes for userID would go
+// over the user quota. It only compares with the current usage: concurrent uploads all pass the
+// check unless each holds lockUserQuota(userID) from the check until its file is recorded.
+func (app *Application) checkUserQuota(userID int, size int64) error {
+	left, limited, err := app.userQuotaLeft(userID)
+	if err != nil {
+		return err
+	}
+	if limited && size > left {
+		return errQuotaExceeded
+	}
+	return nil
+}
+
+// lockUserQuota serializes the uploads of userID from the quota check until the file is
+// recorded. Uploads are read and processed before taking it, since a slow client would hold
+// it for as long as its upload takes. It returns the function that releases the lock, and
+// does not lock when userID has no quota.
+func (app *Application) lockUserQuota(userID int) (unlock func()) {
+	if app.Config.Media.UserQuota <= 0 || userID == database.SystemOwnerID {
+		return func() {}
+	}
+	return app.quotaLocks.lock(userID)
+}
+
+// userLocks is a mutex per user id. The zero value is ready to use.
+type userLocks struct {
+	mu    sync.Mutex
+	locks map[int]*userLock
+}
+
+type userLock struct {
+	sync.Mutex
+	// holders counts the goroutines holding or waiting for the lock, which is dropped from
+	// the map when the last one releases it.
+	holders int
+}
+
+func (l *userLocks) lock(userID int) (unlock func()) {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[int]*userLock)
+	}
+	ul := l.locks[userID]
+	if ul == nil {
+		ul = &userLock{}
+		l.locks[userID] = ul
+	}
+	ul.holders++
+	l.mu.Unlock()
+
+	ul.Lock()
+	return func() {
+		ul.Unlock()
+
+		l.mu.Lock()
+		ul.holders--
+		if ul.holders == 0 {
+			delete(l.locks, userID)
+		}
+		l.mu.Unlock()
+	}
+}
+
+// userQuotaFull is the validation error for uploads over the user quota.
+func (app *Application) userQuotaFull() string {
+	return fmt.Sprintf("Your storage quota of %dMB is full", app.Config.Media.UserQuota/1_000_000)
+}
+
+// checkGroupQuota reports on v under field when sharing an upload, an inline file or
+// attachments in groupID would take the group over its quota. Its arguments are those of
+// checkUpload and checkAttachments.
+func (app *Application) checkGroupQuota(v *validator.Validator, field string, groupID int, uploadID *int, inline []byte, attachmentIDs []int) error {
+	mediaIDs := attachmentIDs
+	if uploadID != nil {
+		mediaIDs = append([]int{*uploadID}, mediaIDs...)
+	}
+	if app.Config.Media.GroupQuota <= 0 || (len(inline) == 0 && len(mediaIDs) == 0) {
+		return nil
+	}
+
+	usage, err := app.DB.GroupStorageUsage(groupID)
+	if err != nil {
+		return err
+	}
+	added, err := app.DB.GroupStorageAdded(groupID, mediaIDs)
+	if err != nil {
+		return err
+	}
+
+	quota := app.Config.Media.GroupQuota
+	v.CheckField(usage.Bytes+added+int64(len(inline)) <= quota, field, fmt.Sprintf("The storage quota of this group, %dMB, is full", quota/1_000_000))
+	return nil
+}
+
+// quotaBytes returns a quota for responses: nil when disabled.
+func quotaBytes(quota int64) *int64 {
+	if quota <= 0 {
+		return nil
+	}
+	return &quota
+}
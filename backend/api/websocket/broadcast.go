@@ -1,7 +1,10 @@
 package websocket
 
 import (
+	"log"
 	"time"
+
+	"brainbook-api/internal/response"
 )
 
 // startPeriodicUserListBroadcast starts a goroutine that broadcasts user status changes every 5 seconds
@@ -54,3 +57,39 @@ func (m *WebsocketManager) broadcastUserList() {
 		go m.sendUserStatusUpdate(client, statusUpdate)
 	}
 }
+
+// BroadcastEvent sends an event to every connected client whose user passes canReceive.
+// canReceive is called once per user, outside the manager lock, so it may query the database.
+func (m *WebsocketManager) BroadcastEvent(eventType string, payload any, canReceive func(userID int) bool) {
+	data, err := response.EncodeJSON(payload)
+	if err != nil {
+		log.Printf("failed to encode %s payload: %v", eventType, err)
+		return
+	}
+	event := Event{Type: eventType, Payload: data}
+
+	m.RLock()
+	clients := make([]*Client, 0, len(m.clients))
+	for client := range m.clients {
+		clients = append(clients, client)
+	}
+	m.RUnlock()
+
+	allowed := make(map[int]bool)
+	for _, client := range clients {
+		ok, checked := allowed[client.userID]
+		if !checked {
+			ok = canReceive(client.userID)
+			allowed[client.userID] = ok
+		}
+		if !ok {
+			continue
+		}
+
+		select {
+		case client.egress <- event:
+		default:
+			log.Printf("client %d egress full, dropping %s event", client.userID, eventType)
+		}
+	}
+}
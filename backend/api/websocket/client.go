@@ -10,6 +10,10 @@ import (
 	"github.com/gorilla/websocket"
 )
 
+// maxMessageSize is the largest message read from a client, in bytes. It leaves room for a
+// chat message of the longest length in multi-byte characters, with its attachment ids.
+const maxMessageSize = 4096
+
 var (
 	// pongWait is how long the server will await a pong response from a client
 	pongWait = 10 * time.Second
@@ -58,7 +62,7 @@ func (c *Client) readMessages() {
 	}()
 
 	// Set Max Size of Messages in Bytes
-	c.connection.SetReadLimit(512)
+	c.connection.SetReadLimit(maxMessageSize)
 
 	// Configure Wait time for Pong response, use Current time + pongWait
 	// This has to be done here to set the first initial timer.
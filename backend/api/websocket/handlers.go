@@ -1,10 +1,13 @@
 package websocket
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 
+	db "brainbook-api/internal/database"
 	"brainbook-api/internal/response"
 	t "brainbook-api/internal/time"
 	"brainbook-api/internal/validator"
@@ -32,13 +35,18 @@ func SendMessageHandler(event Event, c *Client) error {
 	// Validate message content using validator package
 	var v validator.Validator
 	log.Printf("Validating message: length=%d, content='%s'", len(chatevent.Message), chatevent.Message)
-	v.CheckField(validator.NotBlank(chatevent.Message), "message", "Message cannot be empty")
+	v.CheckField(validator.NotBlank(chatevent.Message) || len(chatevent.AttachmentIDs) > 0, "message", "Message cannot be empty")
 	v.CheckField(validator.MaxRunes(chatevent.Message, 250), "message", "Message is too long (maximum 250 characters)")
 	log.Printf("Validation result: HasErrors=%v, FieldErrors=%+v", v.HasErrors(), v.FieldErrors)
 
 	// Validate receiver ID
 	v.CheckField(chatevent.ReceiverID > 0, "receiver_id", "Invalid receiver ID")
 
+	// Attachments must be uploads of the sender, checked like those of posts
+	if err := c.manager.checkAttachments(&v, user.ID, 0, chatevent.AttachmentIDs); err != nil {
+		return fmt.Errorf("failed to check attachments: %v", err)
+	}
+
 	if v.HasErrors() {
 		// Send validation errors as WebSocket error with context
 		for field, errorMsg := range v.FieldErrors {
@@ -101,19 +109,30 @@ func SendMessageHandler(event Event, c *Client) error {
 	}
 
 	// Save message to database
-	_, err = c.manager.DB.InsertMessage(conversationID, user.ID, chatevent.Message, currentDateTime)
+	messageID, err := c.manager.DB.InsertMessage(conversationID, user.ID, chatevent.Message, currentDateTime)
 	if err != nil {
 		return fmt.Errorf("failed to save message: %v", err)
 	}
 
+	if err := c.manager.DB.InsertAttachments(db.ContentMessage, messageID, chatevent.AttachmentIDs); err != nil {
+		return fmt.Errorf("failed to save attachments: %v", err)
+	}
+
 	_ = c.manager.DB.UpdateConversationLastMessageTime(conversationID, currentDateTime)
 
+	senderAttachments, receiverAttachments, err := c.manager.attachmentPreviews(db.ContentMessage, messageID, user.ID, chatevent.AttachmentIDs)
+	if err != nil {
+		return fmt.Errorf("failed to load attachments: %v", err)
+	}
+
 	// Prepare outgoing message
 	var broadMessage ReceiveMessageEvent
+	broadMessage.MessageID = messageID
 	broadMessage.Message = chatevent.Message
 	broadMessage.SenderID = user.ID
 	broadMessage.ReceiverID = chatevent.ReceiverID
 	broadMessage.SentAt = currentDateTime
+	broadMessage.Attachments = receiverAttachments
 
 	data, err := response.EncodeJSON(broadMessage)
 	if err != nil {
@@ -130,17 +149,30 @@ func SendMessageHandler(event Event, c *Client) error {
 		receiverClient.egress <- outgoingEvent
 	}
 
-	// Send confirmation back to sender
-	c.egress <- outgoingEvent
+	// Send confirmation back to sender, who also sees their attachments still being scanned
+	broadMessage.Attachments = senderAttachments
+	data, err = response.EncodeJSON(broadMessage)
+	if err != nil {
+		return fmt.Errorf("failed to encode broadcast message: %v", err)
+	}
+	c.egress <- Event{Type: EventReceiveMessage, Payload: data}
 
 	c.manager.CreateAndPushNotification(chatevent.ReceiverID, "direct_message", map[string]interface{}{
-		"sender_id":       user.ID,
-		"sender_name":     user.FullName(),
-		"conversation_id": conversationID,
-		"message":         chatevent.Message,
-		"sent_at":         currentDateTime,
+		"sender_id":        user.ID,
+		"sender_name":      user.FullName(),
+		"conversation_id":  conversationID,
+		"message":          chatevent.Message,
+		"attachment_count": len(chatevent.AttachmentIDs),
+		"sent_at":          currentDateTime,
 	})
 
+	// Only the receiver can read a direct message besides its sender
+	mentionPayload := map[string]interface{}{"conversation_id": conversationID}
+	canSee := func(userID int) (bool, error) { return userID == chatevent.ReceiverID, nil }
+	if err := c.manager.indexMentions(db.ContentMessage, messageID, chatevent.Message, user, mentionPayload, canSee); err != nil {
+		return fmt.Errorf("failed to index mentions: %v", err)
+	}
+
 	return nil
 }
 
@@ -157,7 +189,7 @@ func SendGroupMessageHandler(event Event, c *Client) error {
 	}
 
 	var v validator.Validator
-	v.CheckField(validator.NotBlank(payload.Message), "message", "Message cannot be empty")
+	v.CheckField(validator.NotBlank(payload.Message) || len(payload.AttachmentIDs) > 0, "message", "Message cannot be empty")
 	v.CheckField(payload.GroupID > 0, "group_id", "Invalid group ID")
 	if v.HasErrors() {
 		for field, msg := range v.FieldErrors {
@@ -166,31 +198,56 @@ func SendGroupMessageHandler(event Event, c *Client) error {
 		return nil
 	}
 
-	isMember, err := c.manager.DB.IsGroupMember(payload.GroupID, user.ID)
+	group, err := c.manager.DB.GroupByID(payload.GroupID)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.sendErrorEvent("GROUP_NOT_FOUND", "Group not found")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	canAccess, err := c.manager.DB.CanAccessGroup(user.ID, group)
 	if err != nil {
 		return err
 	}
-	if !isMember {
+	if !canAccess {
 		c.sendErrorEvent("GROUP_MESSAGE_FORBIDDEN", "You are not a member of this group")
 		return nil
 	}
 
+	// Attachments are checked like those of group posts, group quota included
+	if err := c.manager.checkAttachments(&v, user.ID, group.ID, payload.AttachmentIDs); err != nil {
+		return err
+	}
+	if v.HasErrors() {
+		for field, msg := range v.FieldErrors {
+			c.sendErrorEventWithContext("VALIDATION_ERROR", msg, map[string]interface{}{"field": field})
+		}
+		return nil
+	}
+
 	currentTime := t.CurrentTime()
-	_, err = c.manager.DB.InsertGroupMessage(payload.GroupID, user.ID, payload.Message, currentTime)
+	messageID, err := c.manager.DB.InsertGroupMessage(payload.GroupID, user.ID, payload.Message, currentTime)
 	if err != nil {
 		return err
 	}
 
-	group, err := c.manager.DB.GroupByID(payload.GroupID)
+	if err := c.manager.DB.InsertAttachments(db.ContentGroupMessage, messageID, payload.AttachmentIDs); err != nil {
+		return err
+	}
+	senderAttachments, memberAttachments, err := c.manager.attachmentPreviews(db.ContentGroupMessage, messageID, user.ID, payload.AttachmentIDs)
 	if err != nil {
 		return err
 	}
 
 	message := ReceiveGroupMessageEvent{
-		Message:  payload.Message,
-		SenderID: user.ID,
-		GroupID:  payload.GroupID,
-		SentAt:   currentTime,
+		MessageID:   messageID,
+		Message:     payload.Message,
+		SenderID:    user.ID,
+		GroupID:     payload.GroupID,
+		SentAt:      currentTime,
+		Attachments: memberAttachments,
 	}
 
 	data, err := response.EncodeJSON(message)
@@ -198,7 +255,21 @@ func SendGroupMessageHandler(event Event, c *Client) error {
 		return err
 	}
 
+	// The sender also sees their attachments still being scanned
+	message.Attachments = senderAttachments
+	senderData, err := response.EncodeJSON(message)
+	if err != nil {
+		return err
+	}
+
+	mentionPayload := map[string]interface{}{"group_id": group.ID}
+	canSee := func(userID int) (bool, error) { return c.manager.DB.CanAccessGroup(userID, group) }
+	if err := c.manager.indexMentions(db.ContentGroupMessage, messageID, payload.Message, user, mentionPayload, canSee); err != nil {
+		return err
+	}
+
 	eventOut := Event{Type: EventReceiveGroupMessage, Payload: data}
+	senderEventOut := Event{Type: EventReceiveGroupMessage, Payload: senderData}
 	members, err := c.manager.DB.GroupMembersByGroupID(payload.GroupID)
 	if err != nil {
 		return err
@@ -207,8 +278,12 @@ func SendGroupMessageHandler(event Event, c *Client) error {
 	for _, member := range members {
 		client := c.manager.getClientByUserID(member.ID)
 		if client != nil {
+			out := eventOut
+			if member.ID == user.ID {
+				out = senderEventOut
+			}
 			select {
-			case client.egress <- eventOut:
+			case client.egress <- out:
 			default:
 				log.Printf("client %d egress full, dropping group message", client.userID)
 			}
@@ -217,12 +292,13 @@ func SendGroupMessageHandler(event Event, c *Client) error {
 				continue
 			}
 			c.manager.CreateAndPushNotification(member.ID, "group_message", map[string]interface{}{
-				"group_id":    payload.GroupID,
-				"group_title": group.Title,
-				"sender_id":   user.ID,
-				"sender_name": user.FullName(),
-				"message":     payload.Message,
-				"sent_at":     currentTime,
+				"group_id":         payload.GroupID,
+				"group_title":      group.Title,
+				"sender_id":        user.ID,
+				"sender_name":      user.FullName(),
+				"message":          payload.Message,
+				"attachment_count": len(payload.AttachmentIDs),
+				"sent_at":          currentTime,
 			})
 		}
 	}
@@ -11,7 +11,9 @@ import (
 
 	db "brainbook-api/internal/database"
 	"brainbook-api/internal/env"
+	"brainbook-api/internal/mention"
 	"brainbook-api/internal/response"
+	"brainbook-api/internal/validator"
 
 	"github.com/gorilla/websocket"
 )
@@ -89,6 +91,10 @@ type WebsocketManager struct {
 	stopTicker     chan bool
 	// previousOnlineUsers for change detection
 	previousOnlineUsers map[int]UserStatusInfo
+	// CheckAttachments validates the files attached to a chat message by senderID, like those
+	// of posts, reporting problems on v. groupID is 0 for direct messages. When nil, messages
+	// cannot carry attachments.
+	CheckAttachments func(v *validator.Validator, senderID, groupID int, mediaIDs []int) error
 }
 
 // Initializes all the values inside manager.
@@ -228,3 +234,63 @@ func (m *WebsocketManager) CreateAndPushNotification(userID int, notifType strin
 
 	m.PushNotification(notif)
 }
+
+// indexMentions stores the @mentions of a chat message and sends a mention notification to each
+// mentioned user that canSee the message. The sender is never notified.
+func (m *WebsocketManager) indexMentions(targetType string, messageID int, content string, sender *db.User, payload map[string]interface{}, canSee func(userID int) (bool, error)) error {
+	mentioned, err := m.DB.IndexMentions(targetType, messageID, mention.Parse(content))
+	if err != nil {
+		return err
+	}
+
+	payload["target_type"] = targetType
+	payload["message_id"] = messageID
+	payload["user_id"] = sender.ID
+	payload["user_full_name"] = sender.FullName()
+
+	for _, userID := range mentioned {
+		if userID == sender.ID {
+			continue
+		}
+		ok, err := canSee(userID)
+		if err != nil {
+			return err
+		}
+		if ok {
+			m.CreateAndPushNotification(userID, mention.NotificationType, payload)
+		}
+	}
+
+	return nil
+}
+
+// checkAttachments validates the attachments of a chat message with CheckAttachments.
+func (m *WebsocketManager) checkAttachments(v *validator.Validator, senderID, groupID int, mediaIDs []int) error {
+	if len(mediaIDs) == 0 {
+		return nil
+	}
+	if m.CheckAttachments == nil {
+		v.AddFieldError("attachment_ids", "Messages cannot carry attachments")
+		return nil
+	}
+	return m.CheckAttachments(v, senderID, groupID, mediaIDs)
+}
+
+// attachmentPreviews returns the attachments of a chat message as its sender and as the other
+// participants see them: files are hidden from others until the malware scan finds them clean.
+// Both are nil for messages without attachments.
+func (m *WebsocketManager) attachmentPreviews(targetType string, messageID, senderID int, mediaIDs []int) (forSender, forOthers json.RawMessage, err error) {
+	if len(mediaIDs) == 0 {
+		return nil, nil, nil
+	}
+
+	sender, err := m.DB.MessageAttachments(targetType, messageID, senderID)
+	if err != nil {
+		return nil, nil, err
+	}
+	others, err := m.DB.MessageAttachments(targetType, messageID, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	return json.RawMessage(sender), json.RawMessage(others), nil
+}
@@ -39,6 +39,12 @@ const (
 	EventError = "error"
 	// EventNotification is for notification payloads
 	EventNotification = "notification"
+	// EventContentEdited is broadcast when a post or comment is edited
+	EventContentEdited = "content_edited"
+	// EventReactionUpdated is broadcast when a reaction is added or removed
+	EventReactionUpdated = "reaction_updated"
+	// EventPollUpdated is broadcast when a vote is cast, changed or retracted
+	EventPollUpdated = "poll_updated"
 )
 
 // User Status Constants
@@ -55,12 +61,15 @@ type SendMessageEvent struct {
 	Message      string `json:"message"`
 	ReceiverID   int    `json:"receiver_id"`   // Target user
 	SessionToken string `json:"session_token"` // Session token for validation
+	// AttachmentIDs are files uploaded beforehand through POST /protected/v1/media.
+	AttachmentIDs []int `json:"attachment_ids"`
 }
 
 type SendGroupMessageEvent struct {
-	Message      string `json:"message"`
-	GroupID      int    `json:"group_id"`
-	SessionToken string `json:"session_token"`
+	Message       string `json:"message"`
+	GroupID       int    `json:"group_id"`
+	SessionToken  string `json:"session_token"`
+	AttachmentIDs []int  `json:"attachment_ids"`
 }
 
 // SendTypingEvent is the payload sent in the send_typing event
@@ -72,17 +81,22 @@ type SendTypingEvent struct {
 
 // ReceiveMessageEvent is returned when responding to send_message
 type ReceiveMessageEvent struct {
+	MessageID  int    `json:"message_id"`
 	Message    string `json:"message"`
 	SenderID   int    `json:"sender_id"`   // Who sent the message
 	ReceiverID int    `json:"receiver_id"` // Who received the message
 	SentAt     string `json:"sent_at"`     // Server adds timestamp
+	// Attachments previews the attached files, as listed in the message history.
+	Attachments json.RawMessage `json:"attachments,omitempty"`
 }
 
 type ReceiveGroupMessageEvent struct {
-	Message  string `json:"message"`
-	SenderID int    `json:"sender_id"`
-	GroupID  int    `json:"group_id"`
-	SentAt   string `json:"sent_at"`
+	MessageID   int             `json:"message_id"`
+	Message     string          `json:"message"`
+	SenderID    int             `json:"sender_id"`
+	GroupID     int             `json:"group_id"`
+	SentAt      string          `json:"sent_at"`
+	Attachments json.RawMessage `json:"attachments,omitempty"`
 }
 
 // NewTypingEvent is returned when responding to send_typing
@@ -117,3 +131,38 @@ type NotificationEvent struct {
 	IsRead    bool            `json:"is_read"`
 	CreatedAt string          `json:"created_at"`
 }
+
+// ContentEditedEvent is the payload for content_edited broadcasts
+type ContentEditedEvent struct {
+	TargetType string `json:"target_type"` // post, comment, group_post or group_post_comment
+	TargetID   int    `json:"target_id"`
+	PostID     int    `json:"post_id"`
+	GroupID    int    `json:"group_id,omitempty"`
+	Content    string `json:"content"`
+	// ContentFormat and ContentHTML are set for posts; ContentHTML only for Markdown ones.
+	ContentFormat string  `json:"content_format,omitempty"`
+	ContentHTML   *string `json:"content_html,omitempty"`
+	FileChanged   bool    `json:"file_changed"`
+	EditedAt      string  `json:"edited_at"`
+}
+
+// ReactionUpdatedEvent is the payload for reaction_updated broadcasts
+type ReactionUpdatedEvent struct {
+	TargetType string         `json:"target_type"` // post, comment, group_post, group_post_comment, message or group_message
+	TargetID   int            `json:"target_id"`
+	PostID     int            `json:"post_id,omitempty"`
+	GroupID    int            `json:"group_id,omitempty"`
+	UserID     int            `json:"user_id"` // Who reacted
+	Kind       string         `json:"kind"`
+	Reacted    bool           `json:"reacted"` // false when the reaction was removed
+	Counts     map[string]int `json:"counts"`
+}
+
+// PollUpdatedEvent is the payload for poll_updated broadcasts
+type PollUpdatedEvent struct {
+	TargetType string `json:"target_type"` // post or group_post
+	TargetID   int    `json:"target_id"`
+	GroupID    int    `json:"group_id,omitempty"`
+	// Poll holds the results, without the choices of any voter.
+	Poll json.RawMessage `json:"poll"`
+}
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_group_post_tag_tag_id;
+DROP INDEX IF EXISTS idx_post_tag_tag_id;
+DROP TABLE IF EXISTS tag_follow;
+DROP TABLE IF EXISTS group_post_tag;
+DROP TABLE IF EXISTS post_tag;
+DROP TABLE IF EXISTS tag;
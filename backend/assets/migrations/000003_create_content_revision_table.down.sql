@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_content_revision_target;
+DROP TABLE IF EXISTS content_revision;
+
+ALTER TABLE group_post_comments DROP COLUMN edited_at;
+ALTER TABLE group_posts DROP COLUMN edited_at;
+ALTER TABLE post_comment DROP COLUMN edited_at;
+ALTER TABLE post DROP COLUMN edited_at;
@@ -0,0 +1,17 @@
+ALTER TABLE post ADD COLUMN edited_at DATETIME;
+ALTER TABLE post_comment ADD COLUMN edited_at DATETIME;
+ALTER TABLE group_posts ADD COLUMN edited_at DATETIME;
+ALTER TABLE group_post_comments ADD COLUMN edited_at DATETIME;
+
+-- Previous versions of edited posts and comments. Each row holds the content that an edit replaced.
+CREATE TABLE IF NOT EXISTS content_revision (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    target_type CHECK( target_type IN ('post','comment','group_post','group_post_comment') ) NOT NULL,
+    target_id INTEGER NOT NULL,
+    content TEXT,
+    file BLOB,
+    written_at DATETIME NOT NULL,
+    replaced_at DATETIME NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_content_revision_target ON content_revision(target_type, target_id);
@@ -0,0 +1,41 @@
+DROP INDEX IF EXISTS idx_group_post_comments_group_post_id;
+DROP INDEX IF EXISTS idx_post_comment_post_id;
+
+CREATE TABLE group_post_comments_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    group_post_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    content TEXT,
+    file BLOB,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    edited_at DATETIME,
+    FOREIGN KEY (group_post_id) REFERENCES group_posts(id),
+    FOREIGN KEY (user_id) REFERENCES user(id)
+);
+
+INSERT INTO group_post_comments_old (id, group_post_id, user_id, content, file, created_at, edited_at)
+SELECT id, group_post_id, user_id, content, file, created_at, edited_at FROM group_post_comments;
+
+DROP TABLE group_post_comments;
+ALTER TABLE group_post_comments_old RENAME TO group_post_comments;
+
+CREATE TABLE post_comment_old (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    post_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    content TEXT,
+    file BLOB,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    edited_at DATETIME,
+    FOREIGN KEY (post_id) REFERENCES post(id),
+    FOREIGN KEY (user_id) REFERENCES user(id)
+);
+
+INSERT INTO post_comment_old (id, post_id, user_id, content, file, created_at, edited_at)
+SELECT id, post_id, user_id, content, file, created_at, edited_at FROM post_comment;
+
+DROP TABLE post_comment;
+ALTER TABLE post_comment_old RENAME TO post_comment;
+
+ALTER TABLE group_posts DROP COLUMN deleted_at;
+ALTER TABLE post DROP COLUMN deleted_at;
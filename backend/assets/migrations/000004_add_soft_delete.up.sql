@@ -0,0 +1,44 @@
+ALTER TABLE post ADD COLUMN deleted_at DATETIME;
+ALTER TABLE group_posts ADD COLUMN deleted_at DATETIME;
+
+-- Comment tables are rebuilt so that purging a post cascades to its comments.
+CREATE TABLE post_comment_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    post_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    content TEXT,
+    file BLOB,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    edited_at DATETIME,
+    deleted_at DATETIME,
+    FOREIGN KEY (post_id) REFERENCES post(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES user(id)
+);
+
+INSERT INTO post_comment_new (id, post_id, user_id, content, file, created_at, edited_at)
+SELECT id, post_id, user_id, content, file, created_at, edited_at FROM post_comment;
+
+DROP TABLE post_comment;
+ALTER TABLE post_comment_new RENAME TO post_comment;
+
+CREATE TABLE group_post_comments_new (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    group_post_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    content TEXT,
+    file BLOB,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    edited_at DATETIME,
+    deleted_at DATETIME,
+    FOREIGN KEY (group_post_id) REFERENCES group_posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES user(id)
+);
+
+INSERT INTO group_post_comments_new (id, group_post_id, user_id, content, file, created_at, edited_at)
+SELECT id, group_post_id, user_id, content, file, created_at, edited_at FROM group_post_comments;
+
+DROP TABLE group_post_comments;
+ALTER TABLE group_post_comments_new RENAME TO group_post_comments;
+
+CREATE INDEX IF NOT EXISTS idx_post_comment_post_id ON post_comment(post_id);
+CREATE INDEX IF NOT EXISTS idx_group_post_comments_group_post_id ON group_post_comments(group_post_id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_reaction_target;
+DROP TABLE IF EXISTS reaction;
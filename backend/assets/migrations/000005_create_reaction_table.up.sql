@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS reaction (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    target_type CHECK( target_type IN ('post','comment','group_post','group_post_comment','message','group_message') ) NOT NULL,
+    target_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    kind TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (target_type, target_id, user_id, kind),
+    FOREIGN KEY (user_id) REFERENCES user(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_reaction_target ON reaction(target_type, target_id);
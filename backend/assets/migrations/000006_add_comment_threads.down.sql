@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_group_post_comments_parent_id;
+DROP INDEX IF EXISTS idx_post_comment_parent_id;
+
+ALTER TABLE group_post_comments DROP COLUMN depth;
+ALTER TABLE group_post_comments DROP COLUMN parent_id;
+ALTER TABLE post_comment DROP COLUMN depth;
+ALTER TABLE post_comment DROP COLUMN parent_id;
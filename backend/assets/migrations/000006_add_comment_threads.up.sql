@@ -0,0 +1,9 @@
+-- Replies point at their parent comment; depth is 0 for top-level comments.
+-- parent_id has no foreign key so the column can be dropped again by the down migration.
+ALTER TABLE post_comment ADD COLUMN parent_id INTEGER;
+ALTER TABLE post_comment ADD COLUMN depth INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE group_post_comments ADD COLUMN parent_id INTEGER;
+ALTER TABLE group_post_comments ADD COLUMN depth INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX IF NOT EXISTS idx_post_comment_parent_id ON post_comment(parent_id);
+CREATE INDEX IF NOT EXISTS idx_group_post_comments_parent_id ON group_post_comments(parent_id);
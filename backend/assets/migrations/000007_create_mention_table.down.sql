@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_mention_user_id;
+DROP INDEX IF EXISTS idx_mention_target;
+DROP TABLE IF EXISTS mention;
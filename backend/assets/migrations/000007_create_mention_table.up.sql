@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS mention (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    target_type CHECK( target_type IN ('post','comment','group_post','group_post_comment','message','group_message') ) NOT NULL,
+    target_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    handle TEXT NOT NULL,
+    -- Rune offsets of the '@handle' text in the content; span_end is exclusive.
+    span_start INTEGER NOT NULL,
+    span_end INTEGER NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES user(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_mention_target ON mention(target_type, target_id);
+CREATE INDEX IF NOT EXISTS idx_mention_user_id ON mention(user_id);
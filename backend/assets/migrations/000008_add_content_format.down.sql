@@ -0,0 +1,6 @@
+ALTER TABLE content_revision DROP COLUMN content_format;
+
+ALTER TABLE group_posts DROP COLUMN content_html;
+ALTER TABLE group_posts DROP COLUMN content_format;
+ALTER TABLE post DROP COLUMN content_html;
+ALTER TABLE post DROP COLUMN content_format;
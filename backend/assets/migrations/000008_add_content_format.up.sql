@@ -0,0 +1,9 @@
+-- Posts are plain text unless they opt into Markdown. content keeps the source for editing;
+-- content_html caches the sanitized rendering of Markdown posts and is NULL for plain ones.
+ALTER TABLE post ADD COLUMN content_format TEXT NOT NULL DEFAULT 'plain' CHECK( content_format IN ('plain','markdown') );
+ALTER TABLE post ADD COLUMN content_html TEXT;
+ALTER TABLE group_posts ADD COLUMN content_format TEXT NOT NULL DEFAULT 'plain' CHECK( content_format IN ('plain','markdown') );
+ALTER TABLE group_posts ADD COLUMN content_html TEXT;
+
+-- Revisions keep the format their source was written in.
+ALTER TABLE content_revision ADD COLUMN content_format TEXT NOT NULL DEFAULT 'plain';
@@ -0,0 +1,6 @@
+DROP TABLE IF EXISTS article_user_can_view;
+
+DROP INDEX IF EXISTS idx_article_publish_at;
+DROP INDEX IF EXISTS idx_article_published;
+DROP INDEX IF EXISTS idx_article_user;
+DROP TABLE IF EXISTS article;
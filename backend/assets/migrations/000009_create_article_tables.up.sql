@@ -0,0 +1,30 @@
+-- Long-form articles. body keeps the Markdown source; body_html caches its sanitized rendering.
+-- Articles stay drafts until published. Scheduled articles carry publish_at and are published
+-- by the in-process scheduler, which reads pending rows back from this table after a restart.
+CREATE TABLE IF NOT EXISTS article (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES user(id),
+    title TEXT NOT NULL,
+    summary TEXT NOT NULL DEFAULT '',
+    body TEXT NOT NULL,
+    body_html TEXT NOT NULL,
+    cover BLOB,
+    reading_minutes INTEGER NOT NULL DEFAULT 1,
+    visibility TEXT NOT NULL DEFAULT 'public' CHECK( visibility IN ('public','private','limited') ),
+    status TEXT NOT NULL DEFAULT 'draft' CHECK( status IN ('draft','scheduled','published') ),
+    publish_at DATETIME,
+    published_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_article_user ON article(user_id, status);
+CREATE INDEX IF NOT EXISTS idx_article_published ON article(status, published_at);
+CREATE INDEX IF NOT EXISTS idx_article_publish_at ON article(publish_at) WHERE status = 'scheduled';
+
+-- Allow list of articles with limited visibility, like post_user_can_view.
+CREATE TABLE IF NOT EXISTS article_user_can_view (
+    article_id INTEGER NOT NULL REFERENCES article(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES user(id) ON DELETE CASCADE,
+    PRIMARY KEY (article_id, user_id)
+);
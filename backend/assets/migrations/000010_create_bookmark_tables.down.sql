@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_bookmark_target;
+DROP INDEX IF EXISTS idx_bookmark_collection;
+DROP INDEX IF EXISTS idx_bookmark_user;
+DROP TABLE IF EXISTS bookmark;
+
+DROP TABLE IF EXISTS bookmark_collection;
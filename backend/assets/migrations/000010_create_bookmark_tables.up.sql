@@ -0,0 +1,24 @@
+-- Named collections are private to their owner. Names are unique per user, ignoring case.
+CREATE TABLE IF NOT EXISTS bookmark_collection (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES user(id),
+    name TEXT NOT NULL COLLATE NOCASE,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, name)
+);
+
+-- A user bookmarks a post or group post once, optionally filing it in one of their collections.
+CREATE TABLE IF NOT EXISTS bookmark (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL REFERENCES user(id),
+    target_type TEXT NOT NULL CHECK( target_type IN ('post','group_post') ),
+    target_id INTEGER NOT NULL,
+    collection_id INTEGER REFERENCES bookmark_collection(id),
+    note TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (user_id, target_type, target_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_bookmark_user ON bookmark(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_bookmark_collection ON bookmark(collection_id);
+CREATE INDEX IF NOT EXISTS idx_bookmark_target ON bookmark(target_type, target_id);
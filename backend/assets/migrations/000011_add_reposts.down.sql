@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_post_repost_of;
+ALTER TABLE post DROP COLUMN repost_of_id;
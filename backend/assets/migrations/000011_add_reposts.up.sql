@@ -0,0 +1,5 @@
+-- A post with repost_of_id references an original post: a plain repost when its content is
+-- empty, a quote post otherwise. No foreign key, so that the down migration can drop the column.
+ALTER TABLE post ADD COLUMN repost_of_id INTEGER;
+
+CREATE INDEX IF NOT EXISTS idx_post_repost_of ON post(repost_of_id, user_id) WHERE repost_of_id IS NOT NULL;
@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_poll_vote_user;
+DROP TABLE IF EXISTS poll_vote;
+
+DROP INDEX IF EXISTS idx_poll_option_poll;
+DROP TABLE IF EXISTS poll_option;
+
+DROP TABLE IF EXISTS poll;
@@ -0,0 +1,31 @@
+-- A poll is attached to at most one post or group post. closes_at is NULL for polls that stay open.
+CREATE TABLE IF NOT EXISTS poll (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    target_type TEXT NOT NULL CHECK( target_type IN ('post','group_post') ),
+    target_id INTEGER NOT NULL,
+    multiple_choice BOOLEAN NOT NULL DEFAULT 0,
+    anonymous BOOLEAN NOT NULL DEFAULT 0,
+    closes_at DATETIME,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (target_type, target_id)
+);
+
+CREATE TABLE IF NOT EXISTS poll_option (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    poll_id INTEGER NOT NULL REFERENCES poll(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    label TEXT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_poll_option_poll ON poll_option(poll_id, position);
+
+-- One row per chosen option; single-choice polls have at most one row per user.
+CREATE TABLE IF NOT EXISTS poll_vote (
+    poll_id INTEGER NOT NULL REFERENCES poll(id) ON DELETE CASCADE,
+    option_id INTEGER NOT NULL REFERENCES poll_option(id) ON DELETE CASCADE,
+    user_id INTEGER NOT NULL REFERENCES user(id),
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (option_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_poll_vote_user ON poll_vote(poll_id, user_id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_post_link_preview_url;
+DROP TABLE IF EXISTS post_link_preview;
@@ -0,0 +1,15 @@
+-- The preview of the first link in a post. Rows are written as pending when the post is saved
+-- and completed by a background fetch; failed rows remember that the link could not be previewed.
+CREATE TABLE IF NOT EXISTS post_link_preview (
+    post_id INTEGER PRIMARY KEY REFERENCES post(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending' CHECK( status IN ('pending','ready','failed') ),
+    title TEXT,
+    description TEXT,
+    image_url TEXT,
+    site_name TEXT,
+    fetched_at DATETIME
+);
+
+-- Previews are reused across posts linking the same URL while they are fresh.
+CREATE INDEX IF NOT EXISTS idx_post_link_preview_url ON post_link_preview(url, fetched_at);
@@ -0,0 +1,30 @@
+-- Only bytes still held in media.data can be moved back into rows: files already extracted
+-- to the media store are left there and their references are lost.
+ALTER TABLE user ADD COLUMN avatar BLOB;
+ALTER TABLE post ADD COLUMN file BLOB;
+ALTER TABLE post_comment ADD COLUMN file BLOB;
+ALTER TABLE group_posts ADD COLUMN file BLOB;
+ALTER TABLE group_post_comments ADD COLUMN file BLOB;
+ALTER TABLE content_revision ADD COLUMN file BLOB;
+ALTER TABLE article ADD COLUMN cover BLOB;
+
+UPDATE user SET avatar = (SELECT data FROM media WHERE id = user.avatar_id);
+UPDATE post SET file = (SELECT data FROM media WHERE id = post.media_id);
+UPDATE post_comment SET file = (SELECT data FROM media WHERE id = post_comment.media_id);
+UPDATE group_posts SET file = (SELECT data FROM media WHERE id = group_posts.media_id);
+UPDATE group_post_comments SET file = (SELECT data FROM media WHERE id = group_post_comments.media_id);
+UPDATE content_revision SET file = (SELECT data FROM media WHERE id = content_revision.media_id);
+UPDATE article SET cover = (SELECT data FROM media WHERE id = article.cover_id);
+
+ALTER TABLE user DROP COLUMN avatar_id;
+ALTER TABLE post DROP COLUMN media_id;
+ALTER TABLE post_comment DROP COLUMN media_id;
+ALTER TABLE group_posts DROP COLUMN media_id;
+ALTER TABLE group_post_comments DROP COLUMN media_id;
+ALTER TABLE content_revision DROP COLUMN media_id;
+ALTER TABLE article DROP COLUMN cover_id;
+
+DROP INDEX IF EXISTS idx_media_pending;
+DROP INDEX IF EXISTS idx_media_sha256;
+DROP INDEX IF EXISTS idx_media_owner;
+DROP TABLE IF EXISTS media;
@@ -0,0 +1,80 @@
+-- Uploaded files. The bytes live in the media store, content-addressed by their SHA-256, so
+-- identical uploads share one file. sha256 is NULL and data holds the bytes for the BLOBs moved
+-- out of rows below, until the server extracts them to the store on startup. legacy_table and
+-- legacy_id record the source row of those BLOBs while this migration runs.
+CREATE TABLE IF NOT EXISTS media (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    owner_id INTEGER NOT NULL REFERENCES user(id),
+    sha256 TEXT,
+    mime_type TEXT NOT NULL DEFAULT 'application/octet-stream',
+    size INTEGER NOT NULL,
+    data BLOB,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    legacy_table TEXT,
+    legacy_id INTEGER
+);
+
+ALTER TABLE user ADD COLUMN avatar_id INTEGER;
+ALTER TABLE post ADD COLUMN media_id INTEGER;
+ALTER TABLE post_comment ADD COLUMN media_id INTEGER;
+ALTER TABLE group_posts ADD COLUMN media_id INTEGER;
+ALTER TABLE group_post_comments ADD COLUMN media_id INTEGER;
+ALTER TABLE content_revision ADD COLUMN media_id INTEGER;
+ALTER TABLE article ADD COLUMN cover_id INTEGER;
+
+INSERT INTO media (owner_id, size, data, legacy_table, legacy_id)
+SELECT id, length(avatar), avatar, 'user', id FROM user WHERE length(avatar) > 0;
+
+INSERT INTO media (owner_id, size, data, created_at, legacy_table, legacy_id)
+SELECT user_id, length(file), file, created_at, 'post', id FROM post WHERE length(file) > 0;
+
+INSERT INTO media (owner_id, size, data, created_at, legacy_table, legacy_id)
+SELECT user_id, length(file), file, created_at, 'post_comment', id FROM post_comment WHERE length(file) > 0;
+
+INSERT INTO media (owner_id, size, data, created_at, legacy_table, legacy_id)
+SELECT user_id, length(file), file, created_at, 'group_posts', id FROM group_posts WHERE length(file) > 0;
+
+INSERT INTO media (owner_id, size, data, created_at, legacy_table, legacy_id)
+SELECT user_id, length(file), file, created_at, 'group_post_comments', id FROM group_post_comments WHERE length(file) > 0;
+
+-- Revisions belong to the author of the content they were replaced in.
+INSERT INTO media (owner_id, size, data, created_at, legacy_table, legacy_id)
+SELECT
+    CASE r.target_type
+        WHEN 'post' THEN (SELECT user_id FROM post WHERE id = r.target_id)
+        WHEN 'comment' THEN (SELECT user_id FROM post_comment WHERE id = r.target_id)
+        WHEN 'group_post' THEN (SELECT user_id FROM group_posts WHERE id = r.target_id)
+        WHEN 'group_post_comment' THEN (SELECT user_id FROM group_post_comments WHERE id = r.target_id)
+    END,
+    length(r.file), r.file, r.written_at, 'content_revision', r.id
+FROM content_revision r
+WHERE length(r.file) > 0;
+
+INSERT INTO media (owner_id, size, data, created_at, legacy_table, legacy_id)
+SELECT user_id, length(cover), cover, created_at, 'article', id FROM article WHERE length(cover) > 0;
+
+CREATE INDEX IF NOT EXISTS idx_media_legacy ON media(legacy_table, legacy_id);
+
+UPDATE user SET avatar_id = (SELECT m.id FROM media m WHERE m.legacy_table = 'user' AND m.legacy_id = user.id);
+UPDATE post SET media_id = (SELECT m.id FROM media m WHERE m.legacy_table = 'post' AND m.legacy_id = post.id);
+UPDATE post_comment SET media_id = (SELECT m.id FROM media m WHERE m.legacy_table = 'post_comment' AND m.legacy_id = post_comment.id);
+UPDATE group_posts SET media_id = (SELECT m.id FROM media m WHERE m.legacy_table = 'group_posts' AND m.legacy_id = group_posts.id);
+UPDATE group_post_comments SET media_id = (SELECT m.id FROM media m WHERE m.legacy_table = 'group_post_comments' AND m.legacy_id = group_post_comments.id);
+UPDATE content_revision SET media_id = (SELECT m.id FROM media m WHERE m.legacy_table = 'content_revision' AND m.legacy_id = content_revision.id);
+UPDATE article SET cover_id = (SELECT m.id FROM media m WHERE m.legacy_table = 'article' AND m.legacy_id = article.id);
+
+DROP INDEX idx_media_legacy;
+ALTER TABLE media DROP COLUMN legacy_table;
+ALTER TABLE media DROP COLUMN legacy_id;
+
+ALTER TABLE user DROP COLUMN avatar;
+ALTER TABLE post DROP COLUMN file;
+ALTER TABLE post_comment DROP COLUMN file;
+ALTER TABLE group_posts DROP COLUMN file;
+ALTER TABLE group_post_comments DROP COLUMN file;
+ALTER TABLE content_revision DROP COLUMN file;
+ALTER TABLE article DROP COLUMN cover;
+
+CREATE INDEX IF NOT EXISTS idx_media_owner ON media(owner_id);
+CREATE INDEX IF NOT EXISTS idx_media_sha256 ON media(sha256);
+CREATE INDEX IF NOT EXISTS idx_media_pending ON media(id) WHERE data IS NOT NULL;
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS media_variant;
+
+ALTER TABLE media DROP COLUMN height;
+ALTER TABLE media DROP COLUMN width;
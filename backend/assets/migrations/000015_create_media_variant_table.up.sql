@@ -0,0 +1,16 @@
+-- Pixel dimensions of image media, NULL for files stored before images were processed.
+ALTER TABLE media ADD COLUMN width INTEGER;
+ALTER TABLE media ADD COLUMN height INTEGER;
+
+-- Resized copies of image media, served by name through the variant query parameter. Images
+-- smaller than a variant have no row for it and are served as they are.
+CREATE TABLE IF NOT EXISTS media_variant (
+    media_id INTEGER NOT NULL REFERENCES media(id),
+    name TEXT NOT NULL,
+    sha256 TEXT NOT NULL,
+    mime_type TEXT NOT NULL,
+    size INTEGER NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    PRIMARY KEY (media_id, name)
+);
@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_content_attachment_media;
+DROP TABLE IF EXISTS content_attachment;
+
+ALTER TABLE media DROP COLUMN file_name;
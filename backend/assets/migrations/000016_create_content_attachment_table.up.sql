@@ -0,0 +1,13 @@
+-- Name of uploaded files other than images, offered when they are downloaded.
+ALTER TABLE media ADD COLUMN file_name TEXT;
+
+-- Files attached to posts and group posts, in the order they were given.
+CREATE TABLE IF NOT EXISTS content_attachment (
+    target_type TEXT NOT NULL CHECK( target_type IN ('post','group_post') ),
+    target_id INTEGER NOT NULL,
+    media_id INTEGER NOT NULL REFERENCES media(id),
+    position INTEGER NOT NULL,
+    PRIMARY KEY (target_type, target_id, position)
+);
+
+CREATE INDEX IF NOT EXISTS idx_content_attachment_media ON content_attachment(media_id);
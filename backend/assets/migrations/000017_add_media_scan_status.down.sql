@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_media_scan_pending;
+
+ALTER TABLE media DROP COLUMN scanned_at;
+ALTER TABLE media DROP COLUMN scan_result;
+ALTER TABLE media DROP COLUMN scan_status;
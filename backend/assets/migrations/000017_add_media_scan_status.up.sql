@@ -0,0 +1,8 @@
+-- Malware scan of uploaded files. New uploads start pending and are quarantined, hidden from
+-- other users, until the scanner finds them clean; rejected files carry the signature found in
+-- scan_result, error marks files the scanner could not handle. Existing files count as clean.
+ALTER TABLE media ADD COLUMN scan_status TEXT NOT NULL DEFAULT 'clean' CHECK( scan_status IN ('pending','clean','rejected','error') );
+ALTER TABLE media ADD COLUMN scan_result TEXT;
+ALTER TABLE media ADD COLUMN scanned_at DATETIME;
+
+CREATE INDEX IF NOT EXISTS idx_media_scan_pending ON media(id) WHERE scan_status = 'pending';
@@ -0,0 +1,16 @@
+CREATE TABLE content_attachment_old (
+    target_type TEXT NOT NULL CHECK( target_type IN ('post','group_post') ),
+    target_id INTEGER NOT NULL,
+    media_id INTEGER NOT NULL REFERENCES media(id),
+    position INTEGER NOT NULL,
+    PRIMARY KEY (target_type, target_id, position)
+);
+
+INSERT INTO content_attachment_old (target_type, target_id, media_id, position)
+SELECT target_type, target_id, media_id, position FROM content_attachment
+WHERE target_type IN ('post','group_post');
+
+DROP TABLE content_attachment;
+ALTER TABLE content_attachment_old RENAME TO content_attachment;
+
+CREATE INDEX IF NOT EXISTS idx_content_attachment_media ON content_attachment(media_id);
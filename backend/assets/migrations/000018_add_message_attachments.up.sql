@@ -0,0 +1,16 @@
+-- content_attachment is rebuilt so that chat messages, direct and group, can carry files too.
+CREATE TABLE content_attachment_new (
+    target_type TEXT NOT NULL CHECK( target_type IN ('post','group_post','message','group_message') ),
+    target_id INTEGER NOT NULL,
+    media_id INTEGER NOT NULL REFERENCES media(id),
+    position INTEGER NOT NULL,
+    PRIMARY KEY (target_type, target_id, position)
+);
+
+INSERT INTO content_attachment_new (target_type, target_id, media_id, position)
+SELECT target_type, target_id, media_id, position FROM content_attachment;
+
+DROP TABLE content_attachment;
+ALTER TABLE content_attachment_new RENAME TO content_attachment;
+
+CREATE INDEX IF NOT EXISTS idx_content_attachment_media ON content_attachment(media_id);
@@ -0,0 +1,2 @@
+ALTER TABLE user DROP COLUMN require_alt_text;
+ALTER TABLE media DROP COLUMN alt_text;
@@ -0,0 +1,4 @@
+-- Alt text describing media for screen readers, written by the uploader, and the user setting
+-- that requires it before an image can be posted.
+ALTER TABLE media ADD COLUMN alt_text TEXT;
+ALTER TABLE user ADD COLUMN require_alt_text BOOLEAN NOT NULL DEFAULT 0;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_user_handle;
+
+ALTER TABLE user DROP COLUMN handle;
@@ -0,0 +1,5 @@
+-- Case-folded nickname that mentions are matched against. It is filled by the application,
+-- which folds non-ASCII letters that SQLite's lower() leaves untouched.
+ALTER TABLE user ADD COLUMN handle TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_user_handle ON user(handle);
@@ -0,0 +1 @@
+ALTER TABLE post_link_preview DROP COLUMN image_id;
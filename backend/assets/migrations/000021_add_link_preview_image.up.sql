@@ -0,0 +1,3 @@
+-- The preview image is downloaded and stored as media, so that clients do not load it from the
+-- linked site. image_url keeps the address it was downloaded from.
+ALTER TABLE post_link_preview ADD COLUMN image_id INTEGER REFERENCES media(id);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_user_handle;
+
+CREATE INDEX IF NOT EXISTS idx_user_handle ON user(handle);
@@ -0,0 +1,10 @@
+-- Handles identify users in mentions, so they become unique. A handle shared by several users
+-- is kept by the earliest account; the others are cleared and given a handle with a number
+-- appended by the application on startup.
+UPDATE user SET handle = NULL
+WHERE handle IS NOT NULL
+  AND EXISTS (SELECT 1 FROM user u WHERE u.handle = user.handle AND u.id < user.id);
+
+DROP INDEX IF EXISTS idx_user_handle;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_user_handle ON user(handle);
@@ -0,0 +1,3 @@
+-- The media collector looks variants up by store key, to tell files still in use from files
+-- it can delete.
+CREATE INDEX IF NOT EXISTS idx_media_variant_sha256 ON media_variant(sha256);
@@ -0,0 +1,8 @@
+UPDATE media SET owner_id = (
+    SELECT p.user_id FROM post_link_preview lp JOIN post p ON p.id = lp.post_id
+    WHERE lp.image_id = media.id
+    ORDER BY p.id
+    LIMIT 1
+)
+WHERE owner_id = -1
+  AND id IN (SELECT image_id FROM post_link_preview WHERE image_id IS NOT NULL);
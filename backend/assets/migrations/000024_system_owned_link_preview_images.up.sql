@@ -0,0 +1,4 @@
+-- Link preview images come from third-party pages: they belong to no user and count against
+-- no quota. -1 is the system owner, see database.SystemOwnerID.
+UPDATE media SET owner_id = -1
+WHERE id IN (SELECT image_id FROM post_link_preview WHERE image_id IS NOT NULL);
@@ -0,0 +1,14 @@
+package database
+
+import "time"
+
+// Cursor marks a position in a list ordered by (created_at, id).
+type Cursor struct {
+	CreatedAt time.Time
+	ID        int
+}
+
+// Timestamp formats the cursor time the same way rows are stored (see internal/time).
+func (c Cursor) Timestamp() string {
+	return c.CreatedAt.UTC().Format("2006-01-02 15:04:05")
+}
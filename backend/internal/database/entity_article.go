@@ -0,0 +1,327 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"brainbook-api/internal/policy"
+)
+
+type Article struct {
+	ID      int    `db:"id" json:"id"`
+	Title   string `db:"title" json:"title"`
+	Summary string `db:"summary" json:"summary"`
+	// Body is the Markdown source and BodyHTML its sanitized rendering. Lists leave both empty.
+	Body           string  `db:"body" json:"body,omitempty"`
+	BodyHTML       string  `db:"body_html" json:"body_html,omitempty"`
+	CoverID        *int    `db:"cover_id" json:"cover_id"`
+	CoverAltText   *string `db:"cover_alt_text" json:"cover_alt_text"`
+	ReadingMinutes int     `db:"reading_minutes" json:"reading_minutes"`
+	Visibility     string  `db:"visibility" json:"visibility"`
+	// Status is draft, scheduled or published; PublishAt is set while scheduled.
+	Status      string     `db:"status" json:"status"`
+	PublishAt   *time.Time `db:"publish_at" json:"publish_at"`
+	PublishedAt *time.Time `db:"published_at" json:"published_at"`
+	CreatedAt   time.Time  `db:"created_at" json:"created_at"`
+	UpdatedAt   time.Time  `db:"updated_at" json:"updated_at"`
+
+	UserSummary
+}
+
+// ArticleFields are the author-editable fields of an article.
+type ArticleFields struct {
+	Title          string
+	Summary        string
+	Body           string
+	BodyHTML       string
+	CoverID        *int
+	ReadingMinutes int
+	Visibility     string
+}
+
+// articleListColumnsSQL selects an article with its author, leaving out the body.
+var articleListColumnsSQL = `
+			a.id, a.title, a.summary, a.cover_id, ` + altTextColumnSQL("a.cover_id", "cover_alt_text") + `,
+			a.reading_minutes, a.visibility, a.status, a.publish_at, a.published_at, a.created_at, a.updated_at,
+			u.id AS user_id, u.f_name, u.l_name, u.avatar_id, ` + altTextColumnSQL("u.avatar_id", "avatar_alt_text")
+
+// InsertArticle adds a draft article written by userID.
+func (db *DB) InsertArticle(userID int, fields ArticleFields, currentDateTime string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO article (user_id, title, summary, body, body_html, cover_id, reading_minutes, visibility, status, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $10)`
+
+	result, err := db.ExecContext(ctx, query, userID, fields.Title, fields.Summary, fields.Body, fields.BodyHTML,
+		fields.CoverID, fields.ReadingMinutes, fields.Visibility, policy.ArticleDraft, currentDateTime)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// UpdateArticle replaces the editable fields of an article. Its status is left unchanged.
+func (db *DB) UpdateArticle(articleID int, fields ArticleFields, currentDateTime string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE article
+		SET title = $1, summary = $2, body = $3, body_html = $4, cover_id = $5, reading_minutes = $6, visibility = $7, updated_at = $8
+		WHERE id = $9`
+
+	_, err := db.ExecContext(ctx, query, fields.Title, fields.Summary, fields.Body, fields.BodyHTML,
+		fields.CoverID, fields.ReadingMinutes, fields.Visibility, currentDateTime, articleID)
+	return err
+}
+
+// SetArticleViewers replaces the allow list of a limited-visibility article.
+// An empty userIDs clears it.
+func (db *DB) SetArticleViewers(articleID int, userIDs []int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM article_user_can_view WHERE article_id = $1`, articleID); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT OR IGNORE INTO article_user_can_view (article_id, user_id)
+		VALUES ($1, $2)`
+
+	for _, uid := range userIDs {
+		if _, err := tx.ExecContext(ctx, query, articleID, uid); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ArticleViewers returns the allow list of an article.
+func (db *DB) ArticleViewers(articleID int) ([]int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	var userIDs []int
+	query := `SELECT user_id FROM article_user_can_view WHERE article_id = $1 ORDER BY user_id`
+	if err := db.SelectContext(ctx, &userIDs, query, articleID); err != nil {
+		return nil, err
+	}
+
+	return userIDs, nil
+}
+
+// PublishArticle publishes an article now. Republishing keeps the original publication time.
+func (db *DB) PublishArticle(articleID int, currentDateTime string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE article
+		SET status = $1, publish_at = NULL, published_at = COALESCE(published_at, $2), updated_at = $2
+		WHERE id = $3`
+
+	_, err := db.ExecContext(ctx, query, policy.ArticlePublished, currentDateTime, articleID)
+	return err
+}
+
+// ScheduleArticle sets an unpublished article to be published at publishAt.
+func (db *DB) ScheduleArticle(articleID int, publishAt, currentDateTime string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE article
+		SET status = $1, publish_at = $2, updated_at = $3
+		WHERE id = $4 AND status != $5`
+
+	_, err := db.ExecContext(ctx, query, policy.ArticleScheduled, publishAt, currentDateTime, articleID, policy.ArticlePublished)
+	return err
+}
+
+// UnscheduleArticle turns a scheduled article back into a draft.
+func (db *DB) UnscheduleArticle(articleID int, currentDateTime string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE article
+		SET status = $1, publish_at = NULL, updated_at = $2
+		WHERE id = $3 AND status = $4`
+
+	_, err := db.ExecContext(ctx, query, policy.ArticleDraft, currentDateTime, articleID, policy.ArticleScheduled)
+	return err
+}
+
+// PublishDueArticles publishes the scheduled articles whose publish_at is not after currentDateTime.
+// Their publication time is the scheduled one, not the time the scheduler caught up with them.
+func (db *DB) PublishDueArticles(currentDateTime string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE article
+		SET status = $1, published_at = publish_at, publish_at = NULL, updated_at = $2
+		WHERE status = $3 AND datetime(publish_at) <= datetime($2)`
+
+	result, err := db.ExecContext(ctx, query, policy.ArticlePublished, currentDateTime, policy.ArticleScheduled)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// NextScheduledArticle returns the earliest pending publish_at. The bool result is false when
+// no article is scheduled.
+func (db *DB) NextScheduledArticle() (time.Time, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	var publishAt time.Time
+	query := `SELECT publish_at FROM article WHERE status = $1 ORDER BY datetime(publish_at) LIMIT 1`
+	err := db.QueryRowContext(ctx, query, policy.ArticleScheduled).Scan(&publishAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+
+	return publishAt, true, nil
+}
+
+// ArticleByID returns an article with its body. The bool result reports whether it exists.
+func (db *DB) ArticleByID(articleID int) (*Article, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		SELECT` + articleListColumnsSQL + `, a.body, a.body_html
+		FROM article a
+		JOIN user u ON a.user_id = u.id
+		WHERE a.id = $1`
+
+	var article Article
+	err := db.GetContext(ctx, &article, query, articleID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &article, true, nil
+}
+
+// CanUserViewArticle returns true if viewerID can view the given article.
+func (db *DB) CanUserViewArticle(viewerID int, article *Article) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	resource := policy.Resource{
+		Kind:        policy.KindArticle,
+		OwnerID:     article.UserSummary.ID,
+		Visibility:  article.Visibility,
+		Unpublished: article.Status != policy.ArticlePublished,
+	}
+
+	rel, err := db.Relation(viewerID, resource.OwnerID)
+	if err != nil {
+		return false, err
+	}
+
+	if article.Visibility == policy.VisibilityLimited {
+		var count int
+		query := `SELECT COUNT(*) FROM article_user_can_view WHERE article_id = $1 AND user_id = $2`
+		if err := db.GetContext(ctx, &count, query, article.ID, viewerID); err != nil {
+			return false, err
+		}
+		rel.AllowListed = count > 0
+	}
+
+	return policy.Allowed(policy.Viewer{ID: viewerID}, policy.ViewArticle, resource, rel), nil
+}
+
+// ArticlesVisibleTo returns the published articles viewerID can view, newest first.
+// A positive authorID restricts them to that author.
+func (db *DB) ArticlesVisibleTo(viewerID, authorID, limit, offset int) ([]Article, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		SELECT` + articleListColumnsSQL + `
+		FROM article a
+		JOIN user u ON a.user_id = u.id
+		WHERE ` + policy.ArticleVisibleSQL("a", "$1") + `
+			AND a.status = $2
+			AND ($3 <= 0 OR a.user_id = $3)
+		ORDER BY datetime(a.published_at) DESC, a.id DESC
+		LIMIT $4 OFFSET $5`
+
+	var articles []Article
+	if err := db.SelectContext(ctx, &articles, query, viewerID, policy.ArticlePublished, authorID, limit, offset); err != nil {
+		return nil, err
+	}
+
+	return articles, nil
+}
+
+// ArticleDrafts returns the draft and scheduled articles of userID, most recently updated first.
+func (db *DB) ArticleDrafts(userID, limit, offset int) ([]Article, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		SELECT` + articleListColumnsSQL + `
+		FROM article a
+		JOIN user u ON a.user_id = u.id
+		WHERE a.user_id = $1 AND a.status != $2
+		ORDER BY datetime(a.updated_at) DESC, a.id DESC
+		LIMIT $3 OFFSET $4`
+
+	var articles []Article
+	if err := db.SelectContext(ctx, &articles, query, userID, policy.ArticlePublished, limit, offset); err != nil {
+		return nil, err
+	}
+
+	return articles, nil
+}
+
+// DeleteArticle permanently removes an article and its allow list.
+func (db *DB) DeleteArticle(articleID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM article_user_can_view WHERE article_id = $1`, articleID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM article WHERE id = $1`, articleID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
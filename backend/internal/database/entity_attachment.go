@@ -0,0 +1,72 @@
+package database
+
+import (
+	"context"
+	"fmt"
+)
+
+// MaxAttachments is how many files a post, group post or chat message may carry.
+const MaxAttachments = 10
+
+// attachmentColumnsSQL returns the attachments select column of the post, group post or chat
+// message whose id is idExpr: a JSON array of the attached media in order, each with its id,
+// type, size, file name, image dimensions, alt text and scan status. Files not yet found clean
+// by the malware scan are left out, except for their owner, the user bound to viewerParam.
+// targetType must be a trusted constant.
+func attachmentColumnsSQL(targetType, idExpr, viewerParam string) string {
+	return fmt.Sprintf(`(
+			SELECT json_group_array(json_object(
+				'media_id', m.id,
+				'mime_type', m.mime_type,
+				'size', m.size,
+				'file_name', m.file_name,
+				'width', m.width,
+				'height', m.height,
+				'alt_text', m.alt_text,
+				'scan_status', m.scan_status
+			))
+			FROM (
+				SELECT m.*
+				FROM content_attachment ca
+				JOIN media m ON m.id = ca.media_id
+				WHERE ca.target_type = '%[1]s' AND ca.target_id = %[2]s
+					AND (m.scan_status = 'clean' OR (m.owner_id = %[3]s AND m.scan_status = 'pending'))
+				ORDER BY ca.position
+			) m
+		) AS attachments`, targetType, idExpr, viewerParam)
+}
+
+// MessageAttachments returns the attachments of a chat message as viewerID sees them, in the
+// format of attachmentColumnsSQL.
+func (db *DB) MessageAttachments(targetType string, messageID, viewerID int) (JSONPayload, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	var attachments JSONPayload
+	query := `SELECT ` + attachmentColumnsSQL(targetType, "$1", "$2")
+	err := db.GetContext(ctx, &attachments, query, messageID, viewerID)
+	return attachments, err
+}
+
+// InsertAttachments attaches media to a post, group post or chat message, in order.
+func (db *DB) InsertAttachments(targetType string, targetID int, mediaIDs []int) error {
+	if len(mediaIDs) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO content_attachment (target_type, target_id, media_id, position)
+		VALUES ($1, $2, $3, $4)
+	`
+
+	for position, mediaID := range mediaIDs {
+		if _, err := db.ExecContext(ctx, query, targetType, targetID, mediaID, position); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
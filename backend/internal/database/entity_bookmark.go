@@ -0,0 +1,254 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"brainbook-api/internal/policy"
+)
+
+// Bookmark is a post or group post saved by a user, with the bookmarked content and its author.
+type Bookmark struct {
+	ID           int       `db:"id" json:"id"`
+	TargetType   string    `db:"target_type" json:"target_type"`
+	TargetID     int       `db:"target_id" json:"target_id"`
+	CollectionID *int      `db:"collection_id" json:"collection_id"`
+	Note         string    `db:"note" json:"note"`
+	CreatedAt    time.Time `db:"created_at" json:"created_at"`
+	// GroupID is set for group posts; Visibility for posts.
+	GroupID       *int    `db:"group_id" json:"group_id,omitempty"`
+	Visibility    *string `db:"visibility" json:"visibility,omitempty"`
+	Content       string  `db:"content" json:"content"`
+	ContentFormat string  `db:"content_format" json:"content_format"`
+	ContentHTML   *string `db:"content_html" json:"content_html,omitempty"`
+	MediaID       *int    `db:"media_id" json:"media_id"`
+	MediaAltText  *string `db:"media_alt_text" json:"media_alt_text"`
+	// PostedAt is the creation time of the bookmarked content, in RFC 3339.
+	PostedAt string `db:"posted_at" json:"posted_at"`
+
+	UserSummary
+}
+
+type BookmarkCollection struct {
+	ID            int       `db:"id" json:"id"`
+	Name          string    `db:"name" json:"name"`
+	BookmarkCount int       `db:"bookmark_count" json:"bookmark_count"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+}
+
+// SaveBookmark bookmarks a post or group post for userID, or updates the collection and note
+// of an existing bookmark. It returns the bookmark ID.
+func (db *DB) SaveBookmark(userID int, targetType string, targetID int, collectionID *int, note, currentDateTime string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO bookmark (user_id, target_type, target_id, collection_id, note, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id, target_type, target_id) DO UPDATE SET collection_id = excluded.collection_id, note = excluded.note`
+
+	if _, err := db.ExecContext(ctx, query, userID, targetType, targetID, collectionID, note, currentDateTime); err != nil {
+		return 0, err
+	}
+
+	var id int
+	query = `SELECT id FROM bookmark WHERE user_id = $1 AND target_type = $2 AND target_id = $3`
+	if err := db.GetContext(ctx, &id, query, userID, targetType, targetID); err != nil {
+		return 0, err
+	}
+
+	return id, nil
+}
+
+// DeleteBookmarkOf removes the bookmark userID has on a post or group post, if any.
+func (db *DB) DeleteBookmarkOf(userID int, targetType string, targetID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `DELETE FROM bookmark WHERE user_id = $1 AND target_type = $2 AND target_id = $3`
+	_, err := db.ExecContext(ctx, query, userID, targetType, targetID)
+	return err
+}
+
+// UpdateBookmark sets the collection and note of a bookmark. A nil collectionID unfiles it.
+func (db *DB) UpdateBookmark(bookmarkID int, collectionID *int, note string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `UPDATE bookmark SET collection_id = $1, note = $2 WHERE id = $3`
+	_, err := db.ExecContext(ctx, query, collectionID, note, bookmarkID)
+	return err
+}
+
+// BookmarkByID returns a bookmark of userID without its content. The bool result reports whether it exists.
+func (db *DB) BookmarkByID(userID, bookmarkID int) (*Bookmark, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		SELECT id, target_type, target_id, collection_id, note, created_at
+		FROM bookmark
+		WHERE id = $1 AND user_id = $2`
+
+	var bookmark Bookmark
+	err := db.GetContext(ctx, &bookmark, query, bookmarkID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &bookmark, true, nil
+}
+
+// DeleteBookmark removes a bookmark.
+func (db *DB) DeleteBookmark(bookmarkID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `DELETE FROM bookmark WHERE id = $1`, bookmarkID)
+	return err
+}
+
+// BookmarksForUser returns the bookmarks of userID, most recent first. A positive collectionID
+// restricts them to that collection.
+//
+// Visibility is checked again at read time with the rules of CanUserViewPost and group
+// membership, so content deleted or made private since it was bookmarked is left out.
+func (db *DB) BookmarksForUser(userID, collectionID, limit, offset int) ([]Bookmark, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		SELECT
+			b.id, b.target_type, b.target_id, b.collection_id, b.note, b.created_at,
+			gp.group_id, p.visibility,
+			COALESCE(p.content, gp.content) AS content,
+			COALESCE(p.content_format, gp.content_format) AS content_format,
+			COALESCE(p.content_html, gp.content_html) AS content_html,
+			COALESCE(p.media_id, gp.media_id) AS media_id,
+			` + altTextColumnSQL("COALESCE(p.media_id, gp.media_id)", "media_alt_text") + `,
+			strftime('%Y-%m-%dT%H:%M:%SZ', COALESCE(p.created_at, gp.created_at)) AS posted_at,
+			u.id AS user_id, u.f_name, u.l_name, u.avatar_id, ` + altTextColumnSQL("u.avatar_id", "avatar_alt_text") + `
+		FROM bookmark b
+		LEFT JOIN post p ON b.target_type = '` + ContentPost + `' AND p.id = b.target_id
+		LEFT JOIN group_posts gp ON b.target_type = '` + ContentGroupPost + `' AND gp.id = b.target_id
+		JOIN user u ON u.id = COALESCE(p.user_id, gp.user_id)
+		WHERE b.user_id = $1
+			AND (
+				(p.id IS NOT NULL AND ` + policy.PostVisibleSQL("p", "$1") + `)
+				OR (gp.id IS NOT NULL AND gp.deleted_at IS NULL AND ` + policy.GroupMemberSQL("gp.group_id", "$1") + `)
+			)
+			AND ($2 <= 0 OR b.collection_id = $2)
+		ORDER BY datetime(b.created_at) DESC, b.id DESC
+		LIMIT $3 OFFSET $4`
+
+	var bookmarks []Bookmark
+	if err := db.SelectContext(ctx, &bookmarks, query, userID, collectionID, limit, offset); err != nil {
+		return nil, err
+	}
+
+	return bookmarks, nil
+}
+
+// InsertBookmarkCollection adds a collection for userID.
+func (db *DB) InsertBookmarkCollection(userID int, name, currentDateTime string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `INSERT INTO bookmark_collection (user_id, name, created_at) VALUES ($1, $2, $3)`
+	result, err := db.ExecContext(ctx, query, userID, name, currentDateTime)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// BookmarkCollectionNameTaken reports whether userID has another collection named name, ignoring case.
+// exceptID is the collection being renamed, 0 otherwise.
+func (db *DB) BookmarkCollectionNameTaken(userID int, name string, exceptID int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	var count int
+	query := `SELECT COUNT(*) FROM bookmark_collection WHERE user_id = $1 AND name = $2 AND id != $3`
+	if err := db.GetContext(ctx, &count, query, userID, name, exceptID); err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// BookmarkCollectionExists reports whether userID owns the collection.
+func (db *DB) BookmarkCollectionExists(userID, collectionID int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	var count int
+	query := `SELECT COUNT(*) FROM bookmark_collection WHERE id = $1 AND user_id = $2`
+	if err := db.GetContext(ctx, &count, query, collectionID, userID); err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// BookmarkCollections returns the collections of userID by name, with the number of bookmarks filed in each.
+func (db *DB) BookmarkCollections(userID int) ([]BookmarkCollection, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		SELECT c.id, c.name, c.created_at, COUNT(b.id) AS bookmark_count
+		FROM bookmark_collection c
+		LEFT JOIN bookmark b ON b.collection_id = c.id
+		WHERE c.user_id = $1
+		GROUP BY c.id, c.name, c.created_at
+		ORDER BY c.name`
+
+	var collections []BookmarkCollection
+	if err := db.SelectContext(ctx, &collections, query, userID); err != nil {
+		return nil, err
+	}
+
+	return collections, nil
+}
+
+// RenameBookmarkCollection changes the name of a collection.
+func (db *DB) RenameBookmarkCollection(collectionID int, name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	_, err := db.ExecContext(ctx, `UPDATE bookmark_collection SET name = $1 WHERE id = $2`, name, collectionID)
+	return err
+}
+
+// DeleteBookmarkCollection removes a collection. Its bookmarks are kept, unfiled.
+func (db *DB) DeleteBookmarkCollection(collectionID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `UPDATE bookmark SET collection_id = NULL WHERE collection_id = $1`, collectionID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM bookmark_collection WHERE id = $1`, collectionID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -6,23 +6,36 @@ import (
 )
 
 type Comment struct {
-	ID        int       `db:"id" json:"id"`
-	Content   string    `db:"content" json:"content"`
-	File      []byte    `db:"file" json:"file"`
-	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	ID           int        `db:"id" json:"id"`
+	Content      string     `db:"content" json:"content"`
+	MediaID      *int       `db:"media_id" json:"media_id"`
+	MediaAltText *string    `db:"media_alt_text" json:"media_alt_text"`
+	CreatedAt    time.Time  `db:"created_at" json:"created_at"`
+	EditedAt     *time.Time `db:"edited_at" json:"edited_at"`
+	// DeletedAt is set on tombstones: deleted comments kept in the thread without their content.
+	DeletedAt *time.Time `db:"deleted_at" json:"deleted_at,omitempty"`
+	// ParentID is the comment replied to; nil for top-level comments.
+	ParentID   *int `db:"parent_id" json:"parent_id"`
+	Depth      int  `db:"depth" json:"depth"`
+	ReplyCount int  `db:"reply_count" json:"reply_count"`
+	// Mentions lists the @handle spans resolved to users.
+	Mentions JSONPayload `db:"mentions" json:"mentions"`
 
 	UserSummary
+	ReactionSummary
 }
 
-func (db DB) InsertComment(postID int, userID int, content string, file []byte, createdAt string) (int, error) {
+// InsertComment adds a comment to a post. parentID is nil for top-level comments;
+// replies are stored one level deeper than their parent.
+func (db DB) InsertComment(postID int, userID int, content string, mediaID *int, createdAt string, parentID *int) (int, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
 	defer cancel()
 
 	query := `
-    INSERT INTO post_comment (post_id, user_id, content, file, created_at)
-    VALUES ($1, $2, $3, $4, $5)`
+    INSERT INTO post_comment (post_id, user_id, content, media_id, created_at, parent_id, depth)
+    VALUES ($1, $2, $3, $4, $5, $6, COALESCE((SELECT depth + 1 FROM post_comment WHERE id = $6), 0))`
 
-	result, err := db.ExecContext(ctx, query, postID, userID, content, file, createdAt)
+	result, err := db.ExecContext(ctx, query, postID, userID, content, mediaID, createdAt, parentID)
 	if err != nil {
 		return 0, err
 	}
@@ -35,31 +48,70 @@ func (db DB) InsertComment(postID int, userID int, content string, file []byte,
 	return int(id), err
 }
 
-func (db *DB) CommentsForPost(postID int) ([]Comment, error) {
+// commentColumnsSQL is the select list of comment rows, viewer bound to $1.
+// Deleted comments are returned as tombstones without their content.
+var commentColumnsSQL = `
+	c.id,
+	u.id AS user_id,
+	u.f_name,
+	u.l_name,
+	u.avatar_id, ` + altTextColumnSQL("u.avatar_id", "avatar_alt_text") + `,
+	CASE WHEN c.deleted_at IS NULL THEN c.content ELSE '' END AS content,
+	CASE WHEN c.deleted_at IS NULL THEN c.media_id END AS media_id,
+	` + altTextColumnSQL("CASE WHEN c.deleted_at IS NULL THEN c.media_id END", "media_alt_text") + `,
+	c.created_at,
+	c.edited_at,
+	c.deleted_at,
+	c.parent_id,
+	c.depth,
+	(SELECT COUNT(*) FROM post_comment r WHERE r.parent_id = c.id AND r.deleted_at IS NULL) AS reply_count,
+	` + reactionColumnsSQL(ContentComment, "c.id", "$1") + `,
+	` + mentionColumnsSQL(ContentComment, "c.id")
+
+// CommentsForPost returns the top-level comments of a post, oldest first.
+// Replies are listed with CommentReplies.
+func (db *DB) CommentsForPost(viewerID, postID int) ([]Comment, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
 	defer cancel()
 
 	var comments []Comment
 
-	// SUGGESTION: Listing selected fields like this is worth considering (better readability).
 	query := `
-	SELECT c.id,
-		u.id AS user_id,
-		u.f_name,
-		u.l_name,
-		u.avatar,
-		c.content,
-		c.file,
-		c.created_at
+	SELECT ` + commentColumnsSQL + `
 	FROM post_comment c
 	JOIN user u ON c.user_id = u.id
-	WHERE c.post_id = $1
+	WHERE c.post_id = $2 AND c.parent_id IS NULL
 	ORDER BY c.created_at ASC`
 
-	err := db.SelectContext(ctx, &comments, query, postID)
+	err := db.SelectContext(ctx, &comments, query, viewerID, postID)
 	if err != nil {
 		return nil, err
 	}
 
 	return comments, nil
 }
+
+// CommentReplies returns a page of the direct replies to a comment of postID, oldest first.
+func (db *DB) CommentReplies(viewerID, postID, parentID, limit, offset int) ([]Comment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	if limit <= 0 {
+		limit = 20
+	}
+
+	query := `
+	SELECT ` + commentColumnsSQL + `
+	FROM post_comment c
+	JOIN user u ON c.user_id = u.id
+	WHERE c.post_id = $2 AND c.parent_id = $3
+	ORDER BY c.created_at ASC, c.id ASC
+	LIMIT $4 OFFSET $5`
+
+	var comments []Comment
+	if err := db.SelectContext(ctx, &comments, query, viewerID, postID, parentID, limit, offset); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
@@ -0,0 +1,234 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"brainbook-api/internal/mention"
+)
+
+// Kinds of user content (posts and comments), as stored in content_revision.target_type.
+const (
+	ContentPost             = "post"
+	ContentComment          = "comment"
+	ContentGroupPost        = "group_post"
+	ContentGroupPostComment = "group_post_comment"
+)
+
+// Types of the notifications that reference content in their payload.
+const (
+	NotificationCommentReply = "comment_reply"
+	NotificationRepost       = "repost"
+)
+
+// Formats of post content. Comments are always plain text.
+const (
+	FormatPlain    = "plain"
+	FormatMarkdown = "markdown"
+)
+
+// contentTargets maps each kind of content to its table, the column linking it to its parent,
+// the notification payload key that references it and whether it has a content format.
+var contentTargets = map[string]struct {
+	table, parentColumn, notificationKey string
+	formatted                            bool
+}{
+	ContentPost:             {"post", "user_id", "post_id", true},
+	ContentComment:          {"post_comment", "post_id", "comment_id", false},
+	ContentGroupPost:        {"group_posts", "group_id", "group_post_id", true},
+	ContentGroupPostComment: {"group_post_comments", "group_post_id", "group_post_comment_id", false},
+}
+
+// HasContentFormat reports whether a kind of content can opt into a format other than plain text.
+func HasContentFormat(targetType string) bool {
+	return contentTargets[targetType].formatted
+}
+
+// formatColumnSQL selects the content format of a content table row.
+func formatColumnSQL(targetType string) string {
+	if HasContentFormat(targetType) {
+		return "content_format"
+	}
+	return "'" + FormatPlain + "'"
+}
+
+// EditableContent identifies the author and parent of a post or comment.
+type EditableContent struct {
+	AuthorID int
+	// ParentID is the post of a comment, the group of a group post or the group post of a group post comment.
+	// It equals AuthorID for posts.
+	ParentID int
+	// ContentFormat is the format of the current content, see FormatPlain and FormatMarkdown.
+	ContentFormat string
+}
+
+// EditableContentByID returns the author and parent of a post or comment.
+// The bool result reports whether the row exists and is not deleted.
+func (db *DB) EditableContentByID(targetType string, targetID int) (*EditableContent, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	target, ok := contentTargets[targetType]
+	if !ok {
+		return nil, false, fmt.Errorf("unknown content type: %s", targetType)
+	}
+
+	query := fmt.Sprintf(`SELECT user_id, %s, %s FROM %s WHERE id = $1 AND deleted_at IS NULL`, target.parentColumn, formatColumnSQL(targetType), target.table)
+
+	var content EditableContent
+	err := db.QueryRowContext(ctx, query, targetID).Scan(&content.AuthorID, &content.ParentID, &content.ContentFormat)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &content, true, nil
+}
+
+// CommentDepth returns the thread depth of a comment or group post comment, 0 for top-level comments.
+func (db *DB) CommentDepth(targetType string, commentID int) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	if targetType != ContentComment && targetType != ContentGroupPostComment {
+		return 0, fmt.Errorf("not a comment type: %s", targetType)
+	}
+
+	var depth int
+	query := fmt.Sprintf(`SELECT depth FROM %s WHERE id = $1`, contentTargets[targetType].table)
+	if err := db.GetContext(ctx, &depth, query, commentID); err != nil {
+		return 0, err
+	}
+
+	return depth, nil
+}
+
+// SoftDeleteContent marks a post or comment as deleted and removes the notifications referencing it.
+// The row itself is kept until PurgeDeletedContent runs past the retention window.
+//
+// Notifications about content (comment replies, mentions and reposts) carry its id in the
+// payload under post_id, comment_id, group_post_id or group_post_comment_id; notifications
+// about a comment also carry its post.
+// Repost notifications carry the original under post_id and the repost under repost_id.
+func (db *DB) SoftDeleteContent(targetType string, targetID int, deletedAt string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	target, ok := contentTargets[targetType]
+	if !ok {
+		return fmt.Errorf("unknown content type: %s", targetType)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(`UPDATE %s SET deleted_at = $1 WHERE id = $2 AND deleted_at IS NULL`, target.table)
+	if _, err := tx.ExecContext(ctx, query, deletedAt, targetID); err != nil {
+		return err
+	}
+
+	// Only these types are about content; the payloads of others are not matched even when they
+	// use the same keys.
+	query = `
+		DELETE FROM notifications
+		WHERE type IN ($1, $2, $3) AND json_valid(payload) AND json_extract(payload, $4) = $5
+	`
+	if _, err := tx.ExecContext(ctx, query, NotificationCommentReply, mention.NotificationType, NotificationRepost, "$."+target.notificationKey, targetID); err != nil {
+		return err
+	}
+	if targetType == ContentPost {
+		query = `DELETE FROM notifications WHERE type = $1 AND json_valid(payload) AND json_extract(payload, '$.repost_id') = $2`
+		if _, err := tx.ExecContext(ctx, query, NotificationRepost, targetID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Comments purged with the statements below: those of purged posts, and deleted comments once
+// they have no replies left. A tombstone with replies stays until its replies are purged, so a
+// thread of deleted comments is purged from the leaves up over successive runs.
+const (
+	purgedCommentsSQL = `
+		SELECT id FROM post_comment
+		WHERE (datetime(deleted_at) < datetime($1) AND NOT EXISTS (SELECT 1 FROM post_comment r WHERE r.parent_id = post_comment.id))
+		   OR post_id IN (SELECT id FROM post WHERE datetime(deleted_at) < datetime($1))`
+	purgedGroupPostCommentsSQL = `
+		SELECT id FROM group_post_comments
+		WHERE (datetime(deleted_at) < datetime($1) AND NOT EXISTS (SELECT 1 FROM group_post_comments r WHERE r.parent_id = group_post_comments.id))
+		   OR group_post_id IN (SELECT id FROM group_posts WHERE datetime(deleted_at) < datetime($1))`
+)
+
+// purgeStatements hard-delete content soft-deleted before $1 along with everything attached to it.
+// Dependent rows are deleted explicitly since foreign key enforcement is not enabled on the connection.
+var purgeStatements = []string{
+	// Posts: allow lists, tag index, comments, revisions, reactions, mentions, bookmarks, polls, link previews and attachments
+	`DELETE FROM post_user_can_view WHERE post_id IN (SELECT id FROM post WHERE datetime(deleted_at) < datetime($1))`,
+	`DELETE FROM post_tag WHERE post_id IN (SELECT id FROM post WHERE datetime(deleted_at) < datetime($1))`,
+	`DELETE FROM content_revision WHERE target_type = 'comment' AND target_id IN (` + purgedCommentsSQL + `)`,
+	`DELETE FROM reaction WHERE target_type = 'comment' AND target_id IN (` + purgedCommentsSQL + `)`,
+	`DELETE FROM mention WHERE target_type = 'comment' AND target_id IN (` + purgedCommentsSQL + `)`,
+	`DELETE FROM post_comment WHERE id IN (` + purgedCommentsSQL + `)`,
+	`DELETE FROM content_revision WHERE target_type = 'post' AND target_id IN (SELECT id FROM post WHERE datetime(deleted_at) < datetime($1))`,
+	`DELETE FROM reaction WHERE target_type = 'post' AND target_id IN (SELECT id FROM post WHERE datetime(deleted_at) < datetime($1))`,
+	`DELETE FROM mention WHERE target_type = 'post' AND target_id IN (SELECT id FROM post WHERE datetime(deleted_at) < datetime($1))`,
+	`DELETE FROM bookmark WHERE target_type = 'post' AND target_id IN (SELECT id FROM post WHERE datetime(deleted_at) < datetime($1))`,
+	`DELETE FROM poll_vote WHERE poll_id IN (SELECT id FROM poll WHERE target_type = 'post' AND target_id IN (SELECT id FROM post WHERE datetime(deleted_at) < datetime($1)))`,
+	`DELETE FROM poll_option WHERE poll_id IN (SELECT id FROM poll WHERE target_type = 'post' AND target_id IN (SELECT id FROM post WHERE datetime(deleted_at) < datetime($1)))`,
+	`DELETE FROM poll WHERE target_type = 'post' AND target_id IN (SELECT id FROM post WHERE datetime(deleted_at) < datetime($1))`,
+	`DELETE FROM post_link_preview WHERE post_id IN (SELECT id FROM post WHERE datetime(deleted_at) < datetime($1))`,
+	`DELETE FROM content_attachment WHERE target_type = 'post' AND target_id IN (SELECT id FROM post WHERE datetime(deleted_at) < datetime($1))`,
+	`DELETE FROM post WHERE datetime(deleted_at) < datetime($1)`,
+
+	// Group posts: tag index, comments, revisions, reactions, mentions, bookmarks, polls and attachments
+	`DELETE FROM group_post_tag WHERE group_post_id IN (SELECT id FROM group_posts WHERE datetime(deleted_at) < datetime($1))`,
+	`DELETE FROM content_revision WHERE target_type = 'group_post_comment' AND target_id IN (` + purgedGroupPostCommentsSQL + `)`,
+	`DELETE FROM reaction WHERE target_type = 'group_post_comment' AND target_id IN (` + purgedGroupPostCommentsSQL + `)`,
+	`DELETE FROM mention WHERE target_type = 'group_post_comment' AND target_id IN (` + purgedGroupPostCommentsSQL + `)`,
+	`DELETE FROM group_post_comments WHERE id IN (` + purgedGroupPostCommentsSQL + `)`,
+	`DELETE FROM content_revision WHERE target_type = 'group_post' AND target_id IN (SELECT id FROM group_posts WHERE datetime(deleted_at) < datetime($1))`,
+	`DELETE FROM reaction WHERE target_type = 'group_post' AND target_id IN (SELECT id FROM group_posts WHERE datetime(deleted_at) < datetime($1))`,
+	`DELETE FROM mention WHERE target_type = 'group_post' AND target_id IN (SELECT id FROM group_posts WHERE datetime(deleted_at) < datetime($1))`,
+	`DELETE FROM bookmark WHERE target_type = 'group_post' AND target_id IN (SELECT id FROM group_posts WHERE datetime(deleted_at) < datetime($1))`,
+	`DELETE FROM poll_vote WHERE poll_id IN (SELECT id FROM poll WHERE target_type = 'group_post' AND target_id IN (SELECT id FROM group_posts WHERE datetime(deleted_at) < datetime($1)))`,
+	`DELETE FROM poll_option WHERE poll_id IN (SELECT id FROM poll WHERE target_type = 'group_post' AND target_id IN (SELECT id FROM group_posts WHERE datetime(deleted_at) < datetime($1)))`,
+	`DELETE FROM poll WHERE target_type = 'group_post' AND target_id IN (SELECT id FROM group_posts WHERE datetime(deleted_at) < datetime($1))`,
+	`DELETE FROM content_attachment WHERE target_type = 'group_post' AND target_id IN (SELECT id FROM group_posts WHERE datetime(deleted_at) < datetime($1))`,
+	`DELETE FROM group_posts WHERE datetime(deleted_at) < datetime($1)`,
+}
+
+// PurgeDeletedContent permanently removes posts and comments soft-deleted before the given time
+// and returns the number of removed rows, attached rows included.
+func (db *DB) PurgeDeletedContent(before string) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var purged int64
+	for _, query := range purgeStatements {
+		result, err := tx.ExecContext(ctx, query, before)
+		if err != nil {
+			return 0, err
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		purged += affected
+	}
+
+	return purged, tx.Commit()
+}
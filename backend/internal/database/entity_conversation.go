@@ -16,10 +16,17 @@ type Conversation struct {
 }
 
 type Message struct {
+	ID             int       `db:"id" json:"id"`
 	ConversationID int       `db:"conversation_id" json:"conversation_id,omitempty"`
 	SenderID       int       `db:"sender_id" json:"sender_id"`
 	Content        string    `db:"content" json:"content"`
 	CreatedAt      time.Time `db:"created_at" json:"created_at"`
+	// Mentions lists the @handle spans resolved to users.
+	Mentions JSONPayload `db:"mentions" json:"mentions"`
+	// Attachments lists the attached files, see attachmentColumnsSQL.
+	Attachments JSONPayload `db:"attachments" json:"attachments"`
+
+	ReactionSummary
 }
 
 func (db *DB) ConversationByUserIDs(user1ID, user2ID int) (*Conversation, bool, error) {
@@ -91,20 +98,23 @@ func (db *DB) UpdateConversationLastMessageTime(conversationID int, lastMessageT
 	return err
 }
 
-func (db *DB) PaginatedConversationMessages(conversationID, offset, limit int) ([]Message, error) {
+func (db *DB) PaginatedConversationMessages(viewerID, conversationID, offset, limit int) ([]Message, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
 	defer cancel()
 
 	var messages []Message
 
 	query := `
-    SELECT m.conversation_id, m.sender_id, m.content, m.created_at
+    SELECT m.id, m.conversation_id, m.sender_id, m.content, m.created_at,
+        ` + reactionColumnsSQL(ContentMessage, "m.id", "$1") + `,
+        ` + mentionColumnsSQL(ContentMessage, "m.id") + `,
+        ` + attachmentColumnsSQL(ContentMessage, "m.id", "$1") + `
     FROM conversation_message m
-	WHERE m.conversation_id = $1
+	WHERE m.conversation_id = $2
     ORDER BY m.created_at DESC
-    LIMIT $2 OFFSET $3`
+    LIMIT $3 OFFSET $4`
 
-	err := db.SelectContext(ctx, &messages, query, conversationID, limit, offset)
+	err := db.SelectContext(ctx, &messages, query, viewerID, conversationID, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -112,7 +112,7 @@ func (db *DB) GetEventParticipants(eventID int) ([]GroupMember, error) {
     u.id       AS user_id,
     u.f_name,
     u.l_name,
-    u.avatar,
+    u.avatar_id, ` + altTextColumnSQL("u.avatar_id", "avatar_alt_text") + `,
     gm.role,
     gm.joined_at
 FROM event_has_user AS ehu
@@ -24,7 +24,7 @@ func (db *DB) GroupJoinRequestByID(requestID int) (*GroupJoinRequest, bool, erro
 
 	query := `
 		SELECT gjr.id AS request_id, gjr.group_id, gjr.status, gjr.created_at,
-		       u.id AS user_id, u.f_name, u.l_name, u.avatar,
+		       u.id AS user_id, u.f_name, u.l_name, u.avatar_id, ` + altTextColumnSQL("u.avatar_id", "avatar_alt_text") + `,
 		       gjr.requester_id, gjr.target_id
 		FROM group_join_requests gjr
 		JOIN user u ON u.id = gjr.requester_id
@@ -93,7 +93,7 @@ func (db *DB) PendingJoinRequestsByGroupID(groupID int) ([]GroupJoinRequest, err
 		u.id as user_id,
 		u.f_name,
 		u.l_name,
-		u.avatar,
+		u.avatar_id, ` + altTextColumnSQL("u.avatar_id", "avatar_alt_text") + `,
 		gjr.status,
 		gjr.created_at	
 	FROM group_join_requests AS gjr
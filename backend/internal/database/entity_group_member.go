@@ -1,6 +1,10 @@
 package database
 
-import "context"
+import (
+	"context"
+
+	"brainbook-api/internal/policy"
+)
 
 type GroupMember struct {
 	Role     string `db:"role" json:"role"`
@@ -65,7 +69,7 @@ func (db *DB) GroupMembersByGroupID(groupID int) ([]GroupMember, error) {
 			u.id as user_id,
 			u.f_name,
 			u.l_name,
-			u.avatar,
+			u.avatar_id, ` + altTextColumnSQL("u.avatar_id", "avatar_alt_text") + `,
 			COALESCE(gm.role, 'owner') as role,
 			COALESCE(gm.joined_at, g.created_at) as joined_at
 		FROM groups AS g
@@ -79,7 +83,7 @@ func (db *DB) GroupMembersByGroupID(groupID int) ([]GroupMember, error) {
 			u.id as user_id,
 			u.f_name,
 			u.l_name,
-			u.avatar,
+			u.avatar_id, ` + altTextColumnSQL("u.avatar_id", "avatar_alt_text") + `,
 			gm.role,
 			gm.joined_at
 		FROM group_members AS gm
@@ -97,3 +101,15 @@ func (db *DB) GroupMembersByGroupID(groupID int) ([]GroupMember, error) {
 
 	return members, nil
 }
+
+// CanAccessGroup reports whether userID may view the content of the group (posts, events, chat).
+func (db *DB) CanAccessGroup(userID int, group *Group) (bool, error) {
+	isMember, err := db.IsGroupMember(group.ID, userID)
+	if err != nil {
+		return false, err
+	}
+
+	resource := policy.Resource{Kind: policy.KindGroup, OwnerID: group.OwnerID}
+	rel := policy.Relation{GroupMember: isMember}
+	return policy.Allowed(policy.Viewer{ID: userID}, policy.ViewGroupContent, resource, rel), nil
+}
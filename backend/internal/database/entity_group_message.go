@@ -10,8 +10,13 @@ type GroupMessage struct {
 	GroupID   int       `db:"group_id" json:"group_id"`
 	Content   string    `db:"content" json:"content"`
 	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	// Mentions lists the @handle spans resolved to users.
+	Mentions JSONPayload `db:"mentions" json:"mentions"`
+	// Attachments lists the attached files, see attachmentColumnsSQL.
+	Attachments JSONPayload `db:"attachments" json:"attachments"`
 
 	UserSummary
+	ReactionSummary
 }
 
 func (db *DB) InsertGroupMessage(groupID, senderID int, content, createdAt string) (int, error) {
@@ -36,7 +41,7 @@ func (db *DB) InsertGroupMessage(groupID, senderID int, content, createdAt strin
 	return int(id), nil
 }
 
-func (db *DB) GroupMessages(groupID, limit, offset int) ([]GroupMessage, error) {
+func (db *DB) GroupMessages(viewerID, groupID, limit, offset int) ([]GroupMessage, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
 	defer cancel()
 
@@ -50,18 +55,21 @@ func (db *DB) GroupMessages(groupID, limit, offset int) ([]GroupMessage, error)
                u.id as user_id,
                u.f_name,
                u.l_name,
-               u.avatar,
+               u.avatar_id, ` + altTextColumnSQL("u.avatar_id", "avatar_alt_text") + `,
                gm.content,
-               gm.created_at
+               gm.created_at,
+               ` + reactionColumnsSQL(ContentGroupMessage, "gm.id", "$1") + `,
+               ` + mentionColumnsSQL(ContentGroupMessage, "gm.id") + `,
+               ` + attachmentColumnsSQL(ContentGroupMessage, "gm.id", "$1") + `
         FROM group_messages gm
         JOIN user u ON gm.sender_id = u.id
-        WHERE gm.group_id = $1
+        WHERE gm.group_id = $2
         ORDER BY gm.created_at DESC
-        LIMIT $2 OFFSET $3
+        LIMIT $3 OFFSET $4
     `
 
 	var messages []GroupMessage
-	if err := db.SelectContext(ctx, &messages, query, groupID, limit, offset); err != nil {
+	if err := db.SelectContext(ctx, &messages, query, viewerID, groupID, limit, offset); err != nil {
 		return nil, err
 	}
 
@@ -6,27 +6,40 @@ import (
 )
 
 type GroupPost struct {
-	ID           int       `db:"id" json:"id"`
-	GroupID      int       `db:"group_id" json:"group_id"`
-	Content      string    `db:"content" json:"content"`
-	File         []byte    `db:"file" json:"file,omitempty"`
-	CreatedAt    time.Time `db:"created_at" json:"created_at"`
-	CommentCount int       `db:"comment_count" json:"comment_count"`
-	Comments     []Comment `json:"comments"`
+	ID           int        `db:"id" json:"id"`
+	GroupID      int        `db:"group_id" json:"group_id"`
+	Content      string     `db:"content" json:"content"`
+	MediaID      *int       `db:"media_id" json:"media_id"`
+	MediaAltText *string    `db:"media_alt_text" json:"media_alt_text"`
+	CreatedAt    time.Time  `db:"created_at" json:"created_at"`
+	EditedAt     *time.Time `db:"edited_at" json:"edited_at"`
+	// ContentFormat is plain or markdown; ContentHTML is the sanitized rendering of Markdown posts.
+	ContentFormat string    `db:"content_format" json:"content_format"`
+	ContentHTML   *string   `db:"content_html" json:"content_html,omitempty"`
+	CommentCount  int       `db:"comment_count" json:"comment_count"`
+	Comments      []Comment `json:"comments"`
+	// Mentions lists the @handle spans resolved to users.
+	Mentions JSONPayload `db:"mentions" json:"mentions"`
+	// Poll is the attached poll with its results, omitted when there is none.
+	Poll JSONPayload `db:"poll" json:"poll,omitempty"`
+	// Attachments lists the attached files in order.
+	Attachments JSONPayload `db:"attachments" json:"attachments"`
 
 	UserSummary
+	ReactionSummary
 }
 
-func (db *DB) InsertGroupPost(content string, file []byte, currentDateTime string, userID int, groupID int) (int, error) {
+// InsertGroupPost adds a group post. contentHTML is the rendering of content in format, nil for plain text.
+func (db *DB) InsertGroupPost(content, format string, contentHTML *string, mediaID *int, currentDateTime string, userID int, groupID int) (int, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
 	defer cancel()
 
 	query := `
-		INSERT INTO group_posts (user_id, group_id, content, file, created_at)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO group_posts (user_id, group_id, content, content_format, content_html, media_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
 	`
 
-	result, err := db.ExecContext(ctx, query, userID, groupID, content, file, currentDateTime)
+	result, err := db.ExecContext(ctx, query, userID, groupID, content, format, contentHTML, mediaID, currentDateTime)
 	if err != nil {
 		return 0, err
 	}
@@ -39,7 +52,7 @@ func (db *DB) InsertGroupPost(content string, file []byte, currentDateTime strin
 	return int(postID), nil
 }
 
-func (db *DB) GetGroupPosts(groupID int) ([]GroupPost, error) {
+func (db *DB) GetGroupPosts(viewerID, groupID int) ([]GroupPost, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
 	defer cancel()
 
@@ -50,21 +63,26 @@ func (db *DB) GetGroupPosts(groupID int) ([]GroupPost, error) {
 		u.id as user_id,
 		u.f_name,
 		u.l_name,
-		u.avatar,
+		u.avatar_id, ` + altTextColumnSQL("u.avatar_id", "avatar_alt_text") + `,
 		p.content,
-		p.file,
+		p.media_id, ` + altTextColumnSQL("p.media_id", "media_alt_text") + `,
 		p.created_at,
-		COALESCE(COUNT(gpc.id), 0) as comment_count
+		p.edited_at, p.content_format, p.content_html,
+		COALESCE(COUNT(gpc.id), 0) as comment_count,
+		` + reactionColumnsSQL(ContentGroupPost, "p.id", "$1") + `,
+		` + mentionColumnsSQL(ContentGroupPost, "p.id") + `,
+		` + pollColumnsSQL(ContentGroupPost, "p.id", "$1") + `,
+		` + attachmentColumnsSQL(ContentGroupPost, "p.id", "$1") + `
 	FROM group_posts p
 	JOIN user u ON p.user_id = u.id
-	LEFT JOIN group_post_comments gpc ON gpc.group_post_id = p.id
-	WHERE p.group_id = $1
-	GROUP BY p.id, p.group_id, u.id, u.f_name, u.l_name, u.avatar, p.content, p.file, p.created_at
+	LEFT JOIN group_post_comments gpc ON gpc.group_post_id = p.id AND gpc.deleted_at IS NULL
+	WHERE p.group_id = $2 AND p.deleted_at IS NULL
+	GROUP BY p.id, p.group_id, u.id, u.f_name, u.l_name, u.avatar_id, p.content, p.media_id, p.created_at, p.edited_at, p.content_format, p.content_html
 	ORDER BY p.created_at DESC
 	`
 
 	var posts []GroupPost
-	err := db.SelectContext(ctx, &posts, query, groupID)
+	err := db.SelectContext(ctx, &posts, query, viewerID, groupID)
 	if err != nil {
 		return nil, err
 	}
@@ -83,17 +101,18 @@ func (db *DB) GetGroupPostByID(groupPostID int) (*GroupPost, error) {
 			u.id as user_id,
 			u.f_name,
 			u.l_name,
-			u.avatar,
+			u.avatar_id, ` + altTextColumnSQL("u.avatar_id", "avatar_alt_text") + `,
 			gp.content,
-			gp.file,
+			gp.media_id, ` + altTextColumnSQL("gp.media_id", "media_alt_text") + `,
 			gp.created_at,
+			gp.edited_at, gp.content_format, gp.content_html,
 			COALESCE(COUNT(gpc.id), 0) AS comment_count
 		FROM group_posts AS gp
 		JOIN user AS u ON gp.user_id = u.id
-		LEFT JOIN group_post_comments AS gpc ON gpc.group_post_id = gp.id
-		WHERE gp.id = $1
+		LEFT JOIN group_post_comments AS gpc ON gpc.group_post_id = gp.id AND gpc.deleted_at IS NULL
+		WHERE gp.id = $1 AND gp.deleted_at IS NULL
 		GROUP BY 
-			gp.id, gp.group_id, u.id, u.f_name, u.l_name, u.avatar, gp.content, gp.file, gp.created_at
+			gp.id, gp.group_id, u.id, u.f_name, u.l_name, u.avatar_id, gp.content, gp.media_id, gp.created_at, gp.edited_at, gp.content_format, gp.content_html
 	`
 
 	var groupPost GroupPost
@@ -6,24 +6,36 @@ import (
 )
 
 type GroupPostComment struct {
-	ID        int       `db:"id" json:"id"`
-	File      []byte    `db:"file" json:"file,omitempty"`
-	Content   string    `db:"content" json:"content"`
-	CreatedAt time.Time `db:"created_at" json:"created_at"`
+	ID           int        `db:"id" json:"id"`
+	MediaID      *int       `db:"media_id" json:"media_id"`
+	MediaAltText *string    `db:"media_alt_text" json:"media_alt_text"`
+	Content      string     `db:"content" json:"content"`
+	CreatedAt    time.Time  `db:"created_at" json:"created_at"`
+	EditedAt     *time.Time `db:"edited_at" json:"edited_at"`
+	// DeletedAt is set on tombstones: deleted comments kept in the thread without their content.
+	DeletedAt *time.Time `db:"deleted_at" json:"deleted_at,omitempty"`
+	// ParentID is the comment replied to; nil for top-level comments.
+	ParentID   *int `db:"parent_id" json:"parent_id"`
+	Depth      int  `db:"depth" json:"depth"`
+	ReplyCount int  `db:"reply_count" json:"reply_count"`
+	// Mentions lists the @handle spans resolved to users.
+	Mentions JSONPayload `db:"mentions" json:"mentions"`
 
 	UserSummary
+	ReactionSummary
 }
 
-func (db *DB) InsertGroupPostComment(content string, file []byte, currentDateTime string, groupPostID int, userID int) (int, error) {
+// InsertGroupPostComment adds a comment to a group post. parentID is nil for top-level comments.
+func (db *DB) InsertGroupPostComment(content string, mediaID *int, currentDateTime string, groupPostID int, userID int, parentID *int) (int, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
 	defer cancel()
 
 	query := `
-		INSERT INTO group_post_comments (group_post_id, user_id, content, file, created_at)
-		VALUES ($1, $2, $3, $4, $5)
+		INSERT INTO group_post_comments (group_post_id, user_id, content, media_id, created_at, parent_id, depth)
+		VALUES ($1, $2, $3, $4, $5, $6, COALESCE((SELECT depth + 1 FROM group_post_comments WHERE id = $6), 0))
 	`
 
-	result, err := db.ExecContext(ctx, query, groupPostID, userID, content, file, currentDateTime)
+	result, err := db.ExecContext(ctx, query, groupPostID, userID, content, mediaID, currentDateTime, parentID)
 	if err != nil {
 		return 0, err
 	}
@@ -36,31 +48,69 @@ func (db *DB) InsertGroupPostComment(content string, file []byte, currentDateTim
 	return int(commentID), nil
 }
 
-func (db *DB) GetCommentsForGroupPost(groupPostID int) ([]GroupPostComment, error) {
+// groupPostCommentColumnsSQL is the select list of group post comment rows, viewer bound to $1.
+var groupPostCommentColumnsSQL = `
+	c.id,
+	u.id as user_id,
+	u.f_name,
+	u.l_name,
+	u.avatar_id, ` + altTextColumnSQL("u.avatar_id", "avatar_alt_text") + `,
+	CASE WHEN c.deleted_at IS NULL THEN c.content ELSE '' END AS content,
+	CASE WHEN c.deleted_at IS NULL THEN c.media_id END AS media_id,
+	` + altTextColumnSQL("CASE WHEN c.deleted_at IS NULL THEN c.media_id END", "media_alt_text") + `,
+	c.created_at,
+	c.edited_at,
+	c.deleted_at,
+	c.parent_id,
+	c.depth,
+	(SELECT COUNT(*) FROM group_post_comments r WHERE r.parent_id = c.id AND r.deleted_at IS NULL) AS reply_count,
+	` + reactionColumnsSQL(ContentGroupPostComment, "c.id", "$1") + `,
+	` + mentionColumnsSQL(ContentGroupPostComment, "c.id")
+
+// GetCommentsForGroupPost returns the top-level comments of a group post, oldest first.
+func (db *DB) GetCommentsForGroupPost(viewerID, groupPostID int) ([]GroupPostComment, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
 	defer cancel()
 
 	query := `
-		SELECT 
-			c.id,
-			u.id as user_id,
-			u.f_name,
-			u.l_name,
-			u.avatar,
-			c.content,
-			c.file,
-			c.created_at
+		SELECT ` + groupPostCommentColumnsSQL + `
 		FROM group_post_comments AS c
 		JOIN user AS u ON c.user_id = u.id
-		WHERE c.group_post_id = $1
+		WHERE c.group_post_id = $2 AND c.parent_id IS NULL
 		ORDER BY c.created_at ASC
 	`
 
 	var comments []GroupPostComment
-	err := db.SelectContext(ctx, &comments, query, groupPostID)
+	err := db.SelectContext(ctx, &comments, query, viewerID, groupPostID)
 	if err != nil {
 		return nil, err
 	}
 
 	return comments, nil
 }
+
+// GroupPostCommentReplies returns a page of the direct replies to a comment of groupPostID, oldest first.
+func (db *DB) GroupPostCommentReplies(viewerID, groupPostID, parentID, limit, offset int) ([]GroupPostComment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	if limit <= 0 {
+		limit = 20
+	}
+
+	query := `
+		SELECT ` + groupPostCommentColumnsSQL + `
+		FROM group_post_comments AS c
+		JOIN user AS u ON c.user_id = u.id
+		WHERE c.group_post_id = $2 AND c.parent_id = $3
+		ORDER BY c.created_at ASC, c.id ASC
+		LIMIT $4 OFFSET $5
+	`
+
+	var comments []GroupPostComment
+	if err := db.SelectContext(ctx, &comments, query, viewerID, groupPostID, parentID, limit, offset); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
@@ -0,0 +1,119 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// Statuses of a post link preview.
+const (
+	LinkPreviewPending = "pending"
+	LinkPreviewReady   = "ready"
+	LinkPreviewFailed  = "failed"
+)
+
+// LinkPreview is the fetched preview of a link. Failed previews only carry their URL.
+type LinkPreview struct {
+	URL         string `db:"url"`
+	Status      string `db:"status"`
+	Title       string `db:"title"`
+	Description string `db:"description"`
+	// ImageURL is where the image stored as ImageID was downloaded from.
+	ImageURL string `db:"image_url"`
+	ImageID  *int   `db:"image_id"`
+	SiteName string `db:"site_name"`
+}
+
+// linkPreviewColumnSQL returns the link_preview select column of the post aliased as postAlias:
+// a JSON object with the url, title, description, image_id, image_alt_text and site_name of
+// its ready preview, or NULL.
+func linkPreviewColumnSQL(postAlias string) string {
+	return fmt.Sprintf(`(
+			SELECT json_object(
+				'url', lp.url,
+				'title', COALESCE(lp.title, ''),
+				'description', COALESCE(lp.description, ''),
+				'image_id', lp.image_id,
+				'image_alt_text', (SELECT alt_text FROM media WHERE id = lp.image_id),
+				'site_name', COALESCE(lp.site_name, '')
+			)
+			FROM post_link_preview lp
+			WHERE lp.post_id = %[1]s.id AND lp.status = 'ready'
+		) AS link_preview`, postAlias)
+}
+
+// SetPostLink records the link previewed for a post; an empty link removes the preview. A new
+// link starts as pending. It reports whether the link changed and so needs fetching.
+func (db *DB) SetPostLink(postID int, link string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	if link == "" {
+		_, err := db.ExecContext(ctx, `DELETE FROM post_link_preview WHERE post_id = $1`, postID)
+		return false, err
+	}
+
+	query := `
+		INSERT INTO post_link_preview (post_id, url, status)
+		VALUES ($1, $2, 'pending')
+		ON CONFLICT (post_id) DO UPDATE SET
+			url = excluded.url, status = 'pending',
+			title = NULL, description = NULL, image_url = NULL, image_id = NULL, site_name = NULL, fetched_at = NULL
+		WHERE post_link_preview.url != excluded.url`
+
+	result, err := db.ExecContext(ctx, query, postID, link)
+	if err != nil {
+		return false, err
+	}
+
+	changed, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return changed > 0, nil
+}
+
+// CachedLinkPreview returns the most recent ready or failed preview of link fetched at or after
+// freshSince. The bool result is false when there is none.
+func (db *DB) CachedLinkPreview(link, freshSince string) (*LinkPreview, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		SELECT url, status, COALESCE(title, '') AS title, COALESCE(description, '') AS description,
+			COALESCE(image_url, '') AS image_url, image_id, COALESCE(site_name, '') AS site_name
+		FROM post_link_preview
+		WHERE url = $1 AND status != 'pending' AND datetime(fetched_at) >= datetime($2)
+		ORDER BY fetched_at DESC
+		LIMIT 1`
+
+	var preview LinkPreview
+	err := db.GetContext(ctx, &preview, query, link, freshSince)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &preview, true, nil
+}
+
+// CompletePostLinkPreview stores the outcome of fetching the preview of a post link. It is a no-op
+// when the post has been edited to link elsewhere in the meantime.
+func (db *DB) CompletePostLinkPreview(postID int, preview LinkPreview, fetchedAt string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE post_link_preview
+		SET status = $1, title = $2, description = $3, image_url = $4, image_id = $5, site_name = $6, fetched_at = $7
+		WHERE post_id = $8 AND url = $9`
+
+	_, err := db.ExecContext(ctx, query, preview.Status, preview.Title, preview.Description, preview.ImageURL, preview.ImageID,
+		preview.SiteName, fetchedAt, postID, preview.URL)
+	return err
+}
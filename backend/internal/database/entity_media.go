@@ -0,0 +1,420 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"brainbook-api/internal/policy"
+)
+
+// Malware scan statuses of media. Pending files are quarantined until found clean; rejected
+// and error files stay hidden.
+const (
+	MediaScanPending  = "pending"
+	MediaScanClean    = "clean"
+	MediaScanRejected = "rejected"
+	MediaScanError    = "error"
+)
+
+// SystemOwnerID owns the media the server stores by itself, such as link preview images. No
+// user or guest has this id, so such media are nobody's uploads and count against no quota.
+const SystemOwnerID = -1
+
+// Media is an uploaded file. Its bytes are in the media store under SHA256.
+type Media struct {
+	ID       int    `db:"id" json:"id"`
+	OwnerID  int    `db:"owner_id" json:"owner_id"`
+	SHA256   string `db:"sha256" json:"-"`
+	MimeType string `db:"mime_type" json:"mime_type"`
+	Size     int64  `db:"size" json:"size"`
+	Width    *int   `db:"width" json:"width"`
+	Height   *int   `db:"height" json:"height"`
+	// FileName is the name files other than images were uploaded with.
+	FileName *string `db:"file_name" json:"file_name"`
+	// AltText describes the file for screen readers, nil until the uploader writes it.
+	AltText *string `db:"alt_text" json:"alt_text"`
+	// ScanStatus is one of the MediaScan statuses; ScanResult names what a rejected file contains.
+	ScanStatus string    `db:"scan_status" json:"scan_status"`
+	ScanResult *string   `db:"scan_result" json:"scan_result,omitempty"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+}
+
+// MediaVariant is a resized copy of image media, stored under SHA256.
+type MediaVariant struct {
+	MediaID  int    `db:"media_id" json:"media_id"`
+	Name     string `db:"name" json:"name"`
+	SHA256   string `db:"sha256" json:"-"`
+	MimeType string `db:"mime_type" json:"mime_type"`
+	Size     int64  `db:"size" json:"size"`
+	Width    int    `db:"width" json:"width"`
+	Height   int    `db:"height" json:"height"`
+}
+
+// LegacyMedia is a media row whose bytes are still in the database, waiting to be moved to the store.
+type LegacyMedia struct {
+	ID   int    `db:"id"`
+	Data []byte `db:"data"`
+}
+
+// InsertMedia records a file stored under sha256 for ownerID, pending its malware scan.
+// width and height are nil for files other than images, fileName is nil for images.
+func (db *DB) InsertMedia(ownerID int, sha256, mimeType string, size int64, width, height *int, fileName *string, currentDateTime string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO media (owner_id, sha256, mime_type, size, width, height, file_name, scan_status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, 'pending', $8)`
+
+	result, err := db.ExecContext(ctx, query, ownerID, sha256, mimeType, size, width, height, fileName, currentDateTime)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// MediaByID returns a media row. The bool result is false when it does not exist or has not
+// been moved to the store yet.
+func (db *DB) MediaByID(id int) (*Media, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		SELECT id, owner_id, sha256, mime_type, size, width, height, file_name, alt_text, scan_status, scan_result, created_at
+		FROM media
+		WHERE id = $1 AND sha256 IS NOT NULL`
+
+	var media Media
+	err := db.GetContext(ctx, &media, query, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &media, true, nil
+}
+
+// UpdateMediaAltText replaces the alt text of a media file; nil removes it.
+func (db *DB) UpdateMediaAltText(id int, altText *string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `UPDATE media SET alt_text = $1 WHERE id = $2`
+
+	_, err := db.ExecContext(ctx, query, altText, id)
+	return err
+}
+
+// altTextColumnSQL returns a select column named name holding the alt text of the media whose
+// id is idExpr, NULL when there is no media or it has no alt text.
+func altTextColumnSQL(idExpr, name string) string {
+	return `(SELECT alt_text FROM media WHERE id = ` + idExpr + `) AS ` + name
+}
+
+// InsertMediaVariant records a resized copy of media stored under variant.SHA256.
+func (db *DB) InsertMediaVariant(variant MediaVariant) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		INSERT OR REPLACE INTO media_variant (media_id, name, sha256, mime_type, size, width, height)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	_, err := db.ExecContext(ctx, query, variant.MediaID, variant.Name, variant.SHA256, variant.MimeType, variant.Size, variant.Width, variant.Height)
+	return err
+}
+
+// MediaVariantByName returns the variant of media called name. The bool result is false when
+// the media has no such variant, as for images already smaller than it.
+func (db *DB) MediaVariantByName(mediaID int, name string) (*MediaVariant, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		SELECT media_id, name, sha256, mime_type, size, width, height
+		FROM media_variant
+		WHERE media_id = $1 AND name = $2`
+
+	var variant MediaVariant
+	err := db.GetContext(ctx, &variant, query, mediaID, name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &variant, true, nil
+}
+
+// CanUserViewMedia reports whether viewerID may download a media file: their own uploads until
+// rejected by the malware scan, and once found clean, avatars and files attached to content the
+// viewer can see, attachments and link preview images included, and files sent in chats the
+// viewer takes part in.
+// Revisions follow their content.
+func (db *DB) CanUserViewMedia(viewerID, mediaID int) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		SELECT CASE
+			WHEN EXISTS (SELECT 1 FROM media m WHERE m.owner_id = $1 AND m.id = $2 AND m.scan_status IN ('pending', 'clean')) THEN 1
+			WHEN NOT EXISTS (SELECT 1 FROM media m WHERE m.id = $2 AND m.scan_status = 'clean') THEN 0
+			ELSE EXISTS (SELECT 1 FROM user u WHERE u.avatar_id = $2)
+			OR EXISTS (
+				SELECT 1 FROM post p
+				WHERE p.media_id = $2 AND ` + policy.PostVisibleSQL("p", "$1") + `
+			)
+			OR EXISTS (
+				SELECT 1 FROM post_comment c JOIN post p ON p.id = c.post_id
+				WHERE c.media_id = $2 AND c.deleted_at IS NULL AND ` + policy.PostVisibleSQL("p", "$1") + `
+			)
+			OR EXISTS (
+				SELECT 1 FROM group_posts gp
+				WHERE gp.media_id = $2 AND gp.deleted_at IS NULL AND ` + policy.GroupMemberSQL("gp.group_id", "$1") + `
+			)
+			OR EXISTS (
+				SELECT 1 FROM group_post_comments gc JOIN group_posts gp ON gp.id = gc.group_post_id
+				WHERE gc.media_id = $2 AND gc.deleted_at IS NULL AND gp.deleted_at IS NULL
+					AND ` + policy.GroupMemberSQL("gp.group_id", "$1") + `
+			)
+			OR EXISTS (
+				SELECT 1 FROM content_attachment ca JOIN post p ON ca.target_type = 'post' AND p.id = ca.target_id
+				WHERE ca.media_id = $2 AND ` + policy.PostVisibleSQL("p", "$1") + `
+			)
+			OR EXISTS (
+				SELECT 1 FROM content_attachment ca JOIN group_posts gp ON ca.target_type = 'group_post' AND gp.id = ca.target_id
+				WHERE ca.media_id = $2 AND gp.deleted_at IS NULL AND ` + policy.GroupMemberSQL("gp.group_id", "$1") + `
+			)
+			OR EXISTS (
+				SELECT 1 FROM content_attachment ca
+				JOIN conversation_message cm ON ca.target_type = 'message' AND cm.id = ca.target_id
+				JOIN conversation cv ON cv.id = cm.conversation_id
+				WHERE ca.media_id = $2 AND (cv.user1_id = $1 OR cv.user2_id = $1)
+			)
+			OR EXISTS (
+				SELECT 1 FROM content_attachment ca JOIN group_messages gmsg ON ca.target_type = 'group_message' AND gmsg.id = ca.target_id
+				WHERE ca.media_id = $2 AND ` + policy.GroupMemberSQL("gmsg.group_id", "$1") + `
+			)
+			OR EXISTS (
+				SELECT 1 FROM post_link_preview lp JOIN post p ON p.id = lp.post_id
+				WHERE lp.image_id = $2 AND ` + policy.PostVisibleSQL("p", "$1") + `
+			)
+			OR EXISTS (
+				SELECT 1 FROM article a
+				WHERE a.cover_id = $2 AND ` + policy.ArticleVisibleSQL("a", "$1") + `
+			)
+			OR EXISTS (
+				SELECT 1 FROM content_revision r
+				LEFT JOIN post_comment rc ON r.target_type = 'comment' AND rc.id = r.target_id
+				JOIN post p ON p.id = CASE r.target_type WHEN 'post' THEN r.target_id WHEN 'comment' THEN rc.post_id END
+				WHERE r.media_id = $2 AND r.target_type IN ('post', 'comment') AND ` + policy.PostVisibleSQL("p", "$1") + `
+			)
+			OR EXISTS (
+				SELECT 1 FROM content_revision r
+				LEFT JOIN group_post_comments rc ON r.target_type = 'group_post_comment' AND rc.id = r.target_id
+				JOIN group_posts gp ON gp.id = CASE r.target_type WHEN 'group_post' THEN r.target_id WHEN 'group_post_comment' THEN rc.group_post_id END
+				WHERE r.media_id = $2 AND gp.deleted_at IS NULL AND ` + policy.GroupMemberSQL("gp.group_id", "$1") + `
+			)
+		END`
+
+	var canView bool
+	if err := db.QueryRowContext(ctx, query, viewerID, mediaID).Scan(&canView); err != nil {
+		return false, err
+	}
+
+	return canView, nil
+}
+
+// LegacyMediaBatch returns up to limit media rows whose bytes are still held in the database.
+func (db *DB) LegacyMediaBatch(limit int) ([]LegacyMedia, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	var rows []LegacyMedia
+	query := `SELECT id, data FROM media WHERE data IS NOT NULL ORDER BY id LIMIT $1`
+	if err := db.SelectContext(ctx, &rows, query, limit); err != nil {
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+// CompleteLegacyMedia records that the bytes of a media row now live in the store under sha256.
+// size replaces the original size, since images lose their metadata on the way.
+func (db *DB) CompleteLegacyMedia(id int, sha256, mimeType string, size int64, width, height *int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE media SET sha256 = $1, mime_type = $2, size = $3, width = $4, height = $5, data = NULL
+		WHERE id = $6`
+	_, err := db.ExecContext(ctx, query, sha256, mimeType, size, width, height, id)
+	return err
+}
+
+// PendingScanMedia returns up to limit media awaiting their malware scan, oldest first.
+func (db *DB) PendingScanMedia(limit int) ([]Media, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		SELECT id, owner_id, sha256, mime_type, size, width, height, file_name, scan_status, scan_result, created_at
+		FROM media
+		WHERE scan_status = 'pending'
+		ORDER BY id
+		LIMIT $1`
+
+	var files []Media
+	if err := db.SelectContext(ctx, &files, query, limit); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// CompleteMediaScan records the outcome of the malware scan of a media file. result is the
+// signature found in rejected files or the reason a file could not be scanned.
+func (db *DB) CompleteMediaScan(id int, status string, result *string, scannedAt string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		UPDATE media SET scan_status = $1, scan_result = $2, scanned_at = $3
+		WHERE id = $4 AND scan_status = 'pending'`
+	_, err := db.ExecContext(ctx, query, status, result, scannedAt, id)
+	return err
+}
+
+// mediaReferencedSQL returns a condition true when the media whose id is idExpr is used by a
+// profile, an article, content, a revision, an attachment or a link preview. Tables that reference media must
+// be listed here, or the garbage collector deletes their files.
+func mediaReferencedSQL(idExpr string) string {
+	return fmt.Sprintf(`(
+			EXISTS (SELECT 1 FROM user u WHERE u.avatar_id = %[1]s)
+			OR EXISTS (SELECT 1 FROM article a WHERE a.cover_id = %[1]s)
+			OR EXISTS (SELECT 1 FROM post p WHERE p.media_id = %[1]s)
+			OR EXISTS (SELECT 1 FROM post_comment c WHERE c.media_id = %[1]s)
+			OR EXISTS (SELECT 1 FROM group_posts gp WHERE gp.media_id = %[1]s)
+			OR EXISTS (SELECT 1 FROM group_post_comments gc WHERE gc.media_id = %[1]s)
+			OR EXISTS (SELECT 1 FROM content_revision r WHERE r.media_id = %[1]s)
+			OR EXISTS (SELECT 1 FROM content_attachment ca WHERE ca.media_id = %[1]s)
+			OR EXISTS (SELECT 1 FROM post_link_preview lp WHERE lp.image_id = %[1]s)
+		)`, idExpr)
+}
+
+// OrphanedMedia returns up to limit media created before createdBefore that nothing references,
+// such as uploads never attached and files of purged content.
+func (db *DB) OrphanedMedia(createdBefore string, limit int) ([]Media, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		SELECT id, owner_id, sha256, mime_type, size, width, height, file_name, scan_status, scan_result, created_at
+		FROM media m
+		WHERE m.data IS NULL AND datetime(m.created_at) < datetime($1)
+			AND NOT ` + mediaReferencedSQL("m.id") + `
+		ORDER BY m.id
+		LIMIT $2`
+
+	var files []Media
+	if err := db.SelectContext(ctx, &files, query, createdBefore, limit); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// DeleteOrphanedMedia deletes a media row and its variants unless something references the
+// media by now. It returns whether the row was deleted, and the store keys of its file and
+// variants that no other media shares, which the caller deletes from the store.
+func (db *DB) DeleteOrphanedMedia(id int) ([]string, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	var keys []string
+	rows, err := tx.QueryContext(ctx, `
+		SELECT sha256 FROM media WHERE id = $1 AND sha256 IS NOT NULL
+		UNION
+		SELECT sha256 FROM media_variant WHERE media_id = $1`, id)
+	if err != nil {
+		return nil, false, err
+	}
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return nil, false, err
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, false, err
+	}
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM media WHERE id = $1 AND NOT `+mediaReferencedSQL("$1"), id)
+	if err != nil {
+		return nil, false, err
+	}
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return nil, false, err
+	}
+	if deleted == 0 {
+		return nil, false, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM media_variant WHERE media_id = $1`, id); err != nil {
+		return nil, false, err
+	}
+
+	// The store is content-addressed: identical files uploaded twice share a key.
+	var unshared []string
+	for _, key := range keys {
+		var shared bool
+		err := tx.QueryRowContext(ctx, `SELECT `+mediaKeyReferencedSQL, key).Scan(&shared)
+		if err != nil {
+			return nil, false, err
+		}
+		if !shared {
+			unshared = append(unshared, key)
+		}
+	}
+
+	return unshared, true, tx.Commit()
+}
+
+// mediaKeyReferencedSQL is a condition true when a media row or variant has its file stored
+// under the key bound to $1.
+const mediaKeyReferencedSQL = `EXISTS (SELECT 1 FROM media WHERE sha256 = $1)
+	OR EXISTS (SELECT 1 FROM media_variant WHERE sha256 = $1)`
+
+// MediaKeyReferenced reports whether a media row or variant has its file stored under key.
+func (db *DB) MediaKeyReferenced(key string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	var referenced bool
+	if err := db.QueryRowContext(ctx, `SELECT `+mediaKeyReferencedSQL, key).Scan(&referenced); err != nil {
+		return false, err
+	}
+	return referenced, nil
+}
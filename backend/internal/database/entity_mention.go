@@ -0,0 +1,171 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"brainbook-api/internal/mention"
+)
+
+// mentionColumnsSQL returns the mentions select column of the row whose id is idExpr: a JSON
+// array of {user_id, handle, start, end} spans ordered by position. targetType must be a trusted constant.
+func mentionColumnsSQL(targetType, idExpr string) string {
+	return fmt.Sprintf(`(
+			SELECT json_group_array(json_object('user_id', m.user_id, 'handle', m.handle, 'start', m.span_start, 'end', m.span_end))
+			FROM (
+				SELECT user_id, handle, span_start, span_end
+				FROM mention
+				WHERE target_type = '%[1]s' AND target_id = %[2]s
+				ORDER BY span_start
+			) m
+		) AS mentions`, targetType, idExpr)
+}
+
+// userIDByHandle resolves a handle folded with mention.Handle to its user. Handles are unique.
+func (db *DB) userIDByHandle(ctx context.Context, handle string) (int, bool, error) {
+	var id int
+	query := `SELECT id FROM user WHERE handle = $1`
+	err := db.GetContext(ctx, &id, query, handle)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	return id, true, nil
+}
+
+// setHandle gives userID the handle of nickname: the nickname folded with mention.Handle, or
+// with the smallest suffix from 2 that no other user has when that is taken. Users without a
+// nickname have no handle.
+func setHandle(ctx context.Context, tx *sql.Tx, userID int, nickname string) error {
+	// Clearing the handle first takes the write lock of the transaction, so that no other
+	// writer can take the handle between the check and the update.
+	if _, err := tx.ExecContext(ctx, `UPDATE user SET handle = NULL WHERE id = $1`, userID); err != nil {
+		return err
+	}
+	if nickname == "" {
+		return nil
+	}
+
+	base := mention.Handle(nickname)
+	handle := base
+	for n := 2; ; n++ {
+		var taken bool
+		query := `SELECT EXISTS (SELECT 1 FROM user WHERE handle = $1 AND id != $2)`
+		if err := tx.QueryRowContext(ctx, query, handle, userID).Scan(&taken); err != nil {
+			return err
+		}
+		if !taken {
+			break
+		}
+		handle = mention.Suffixed(base, n)
+	}
+
+	_, err := tx.ExecContext(ctx, `UPDATE user SET handle = $1 WHERE id = $2`, handle, userID)
+	return err
+}
+
+// BackfillHandles sets the handle of users whose nickname was saved before the handle column
+// existed, or whose handle was cleared because another user had it. It is a no-op once every
+// nickname has a handle.
+func (db *DB) BackfillHandles() error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	var users []struct {
+		ID       int    `db:"id"`
+		Nickname string `db:"nickname"`
+	}
+	query := `SELECT id, nickname FROM user WHERE nickname IS NOT NULL AND nickname != '' AND handle IS NULL ORDER BY id`
+	if err := db.SelectContext(ctx, &users, query); err != nil {
+		return err
+	}
+	if len(users) == 0 {
+		return nil
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, user := range users {
+		if err := setHandle(ctx, tx, user.ID, user.Nickname); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// IndexMentions replaces the mentions stored for a post, comment or message with the given spans.
+// Spans whose handle does not resolve to a user are dropped. It returns the users mentioned now
+// but not before, in order of first mention, so that edits only notify newly mentioned users.
+func (db *DB) IndexMentions(targetType string, targetID int, spans []mention.Span) ([]int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	var previous []int
+	query := `SELECT DISTINCT user_id FROM mention WHERE target_type = $1 AND target_id = $2`
+	if err := db.SelectContext(ctx, &previous, query, targetType, targetID); err != nil {
+		return nil, err
+	}
+
+	resolved := make(map[string]int)
+	unknown := make(map[string]bool)
+	for _, span := range spans {
+		if _, ok := resolved[span.Handle]; ok || unknown[span.Handle] {
+			continue
+		}
+		userID, ok, err := db.userIDByHandle(ctx, span.Handle)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			resolved[span.Handle] = userID
+		} else {
+			unknown[span.Handle] = true
+		}
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mention WHERE target_type = $1 AND target_id = $2`, targetType, targetID); err != nil {
+		return nil, err
+	}
+
+	query = `
+		INSERT INTO mention (target_type, target_id, user_id, handle, span_start, span_end)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+
+	seen := make(map[int]bool, len(previous))
+	for _, userID := range previous {
+		seen[userID] = true
+	}
+
+	var added []int
+	for _, span := range spans {
+		userID, ok := resolved[span.Handle]
+		if !ok {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, query, targetType, targetID, userID, span.Handle, span.Start, span.End); err != nil {
+			return nil, err
+		}
+		if !seen[userID] {
+			seen[userID] = true
+			added = append(added, userID)
+		}
+	}
+
+	return added, tx.Commit()
+}
@@ -150,3 +150,55 @@ func (db *DB) MarkNotificationRead(notificationID, userID int) error {
 	_, err := db.ExecContext(ctx, query, notificationID, userID)
 	return err
 }
+
+// LatestUnreadNotification returns the most recent unread notification of the given type.
+func (db *DB) LatestUnreadNotification(userID int, notifType string) (*Notification, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		SELECT id, user_id, type, payload, is_read, created_at
+		FROM notifications
+		WHERE user_id = $1 AND type = $2 AND is_read = 0
+		ORDER BY created_at DESC, id DESC
+		LIMIT 1
+	`
+
+	var rows []notificationRecord
+	if err := db.SelectContext(ctx, &rows, query, userID, notifType); err != nil {
+		return nil, false, err
+	}
+	if len(rows) == 0 {
+		return nil, false, nil
+	}
+
+	notif := rows[0].toNotification()
+	return &notif, true, nil
+}
+
+// UpdateNotificationPayload replaces the payload of a notification. When touch is true the
+// timestamp is bumped as well so aggregated notifications resurface at the top of the list.
+func (db *DB) UpdateNotificationPayload(notificationID int, payload []byte, touch bool) (*Notification, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `UPDATE notifications SET payload = $1 WHERE id = $2`
+	if touch {
+		query = `UPDATE notifications SET payload = $1, created_at = CURRENT_TIMESTAMP WHERE id = $2`
+	}
+	if _, err := db.ExecContext(ctx, query, payload, notificationID); err != nil {
+		return nil, err
+	}
+
+	return db.NotificationByID(notificationID)
+}
+
+// MarkNotificationsReadByType marks every unread notification of the given type as read.
+func (db *DB) MarkNotificationsReadByType(userID int, notifType string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `UPDATE notifications SET is_read = 1 WHERE user_id = $1 AND type = $2 AND is_read = 0`
+	_, err := db.ExecContext(ctx, query, userID, notifType)
+	return err
+}
@@ -0,0 +1,198 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Poll holds the settings of the poll attached to a post or group post.
+type Poll struct {
+	ID             int        `db:"id"`
+	MultipleChoice bool       `db:"multiple_choice"`
+	Anonymous      bool       `db:"anonymous"`
+	ClosesAt       *time.Time `db:"closes_at"`
+}
+
+// Closed reports whether the poll no longer accepts votes.
+func (p *Poll) Closed() bool {
+	return p.ClosesAt != nil && !time.Now().Before(*p.ClosesAt)
+}
+
+// NewPoll describes a poll to attach to a new post. ClosesAt is in the database format, nil for open-ended polls.
+type NewPoll struct {
+	Options        []string
+	MultipleChoice bool
+	Anonymous      bool
+	ClosesAt       *string
+}
+
+// PollVoter is a user who chose an option of a poll whose voters are visible.
+type PollVoter struct {
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+
+	UserSummary
+}
+
+// pollColumnsSQL returns the poll select column of the post or group post whose id is idExpr,
+// for the viewer bound to viewerParam: a JSON object with the poll settings, the vote count of
+// each option and the options chosen by the viewer, or NULL without a poll. targetType must be
+// a trusted constant. The viewer placeholder comes first in the returned SQL.
+func pollColumnsSQL(targetType, idExpr, viewerParam string) string {
+	return fmt.Sprintf(`(
+			SELECT json_object(
+				'my_votes', json((SELECT json_group_array(pv.option_id) FROM poll_vote pv WHERE pv.poll_id = pl.id AND pv.user_id = %[3]s)),
+				'id', pl.id,
+				'multiple_choice', json(CASE WHEN pl.multiple_choice THEN 'true' ELSE 'false' END),
+				'anonymous', json(CASE WHEN pl.anonymous THEN 'true' ELSE 'false' END),
+				'closes_at', strftime('%%Y-%%m-%%dT%%H:%%M:%%SZ', pl.closes_at),
+				'closed', json(CASE WHEN datetime(pl.closes_at) <= datetime('now') THEN 'true' ELSE 'false' END),
+				'voters', (SELECT COUNT(DISTINCT pv.user_id) FROM poll_vote pv WHERE pv.poll_id = pl.id),
+				'options', json((
+					SELECT json_group_array(json_object('id', o.id, 'label', o.label, 'votes', o.votes))
+					FROM (
+						SELECT po.id, po.label, (SELECT COUNT(*) FROM poll_vote pv WHERE pv.option_id = po.id) AS votes
+						FROM poll_option po
+						WHERE po.poll_id = pl.id
+						ORDER BY po.position
+					) o
+				))
+			)
+			FROM poll pl
+			WHERE pl.target_type = '%[1]s' AND pl.target_id = %[2]s
+		) AS poll`, targetType, idExpr, viewerParam)
+}
+
+// InsertPoll attaches a poll to a post or group post.
+func (db *DB) InsertPoll(targetType string, targetID int, poll NewPoll, currentDateTime string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO poll (target_type, target_id, multiple_choice, anonymous, closes_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+
+	result, err := tx.ExecContext(ctx, query, targetType, targetID, poll.MultipleChoice, poll.Anonymous, poll.ClosesAt, currentDateTime)
+	if err != nil {
+		return 0, err
+	}
+
+	pollID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	query = `INSERT INTO poll_option (poll_id, position, label) VALUES ($1, $2, $3)`
+	for i, label := range poll.Options {
+		if _, err := tx.ExecContext(ctx, query, pollID, i, label); err != nil {
+			return 0, err
+		}
+	}
+
+	return int(pollID), tx.Commit()
+}
+
+// PollFor returns the poll attached to a post or group post. The bool result reports whether there is one.
+func (db *DB) PollFor(targetType string, targetID int) (*Poll, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		SELECT id, multiple_choice, anonymous, closes_at
+		FROM poll
+		WHERE target_type = $1 AND target_id = $2`
+
+	var poll Poll
+	err := db.GetContext(ctx, &poll, query, targetType, targetID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &poll, true, nil
+}
+
+// PollOptionIDs returns the option IDs of a poll.
+func (db *DB) PollOptionIDs(pollID int) ([]int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	var ids []int
+	query := `SELECT id FROM poll_option WHERE poll_id = $1 ORDER BY position`
+	if err := db.SelectContext(ctx, &ids, query, pollID); err != nil {
+		return nil, err
+	}
+
+	return ids, nil
+}
+
+// SetPollVotes replaces the votes of userID in a poll with the given options. No options retracts the vote.
+func (db *DB) SetPollVotes(pollID, userID int, optionIDs []int, currentDateTime string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM poll_vote WHERE poll_id = $1 AND user_id = $2`, pollID, userID); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO poll_vote (poll_id, option_id, user_id, created_at) VALUES ($1, $2, $3, $4)`
+	for _, optionID := range optionIDs {
+		if _, err := tx.ExecContext(ctx, query, pollID, optionID, userID, currentDateTime); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// PollResults returns the poll column of a post or group post (see pollColumnsSQL) for viewerID.
+// A zero viewerID leaves my_votes empty, for broadcasting.
+func (db *DB) PollResults(targetType string, targetID, viewerID int) (JSONPayload, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	var results JSONPayload
+	query := `SELECT ` + pollColumnsSQL(targetType, "$2", "$1")
+	if err := db.QueryRowContext(ctx, query, viewerID, targetID).Scan(&results); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// PollVoters lists who chose an option, most recent first.
+func (db *DB) PollVoters(optionID, limit, offset int) ([]PollVoter, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		SELECT pv.created_at, u.id AS user_id, u.f_name, u.l_name, u.avatar_id, ` + altTextColumnSQL("u.avatar_id", "avatar_alt_text") + `
+		FROM poll_vote pv
+		JOIN user u ON u.id = pv.user_id
+		WHERE pv.option_id = $1
+		ORDER BY pv.created_at DESC, pv.user_id DESC
+		LIMIT $2 OFFSET $3`
+
+	var voters []PollVoter
+	if err := db.SelectContext(ctx, &voters, query, optionID, limit, offset); err != nil {
+		return nil, err
+	}
+
+	return voters, nil
+}
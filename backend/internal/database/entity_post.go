@@ -2,30 +2,58 @@ package database
 
 import (
 	"context"
+	"fmt"
 	"time"
+
+	"brainbook-api/internal/policy"
 )
 
 type Post struct {
-	ID           int       `db:"id" json:"id"`
-	Content      string    `db:"content" json:"content"`
-	File         []byte    `db:"file" json:"file"`
-	CreatedAt    time.Time `db:"created_at" json:"created_at"`
-	CommentCount int       `db:"comment_count" json:"comment_count"`
-	Comments     []Comment `json:"comments"`
-	Visibility   string    `db:"visibility" json:"visibility"`
+	ID           int        `db:"id" json:"id"`
+	Content      string     `db:"content" json:"content"`
+	MediaID      *int       `db:"media_id" json:"media_id"`
+	MediaAltText *string    `db:"media_alt_text" json:"media_alt_text"`
+	CreatedAt    time.Time  `db:"created_at" json:"created_at"`
+	EditedAt     *time.Time `db:"edited_at" json:"edited_at"`
+	// ContentFormat is plain or markdown; ContentHTML is the sanitized rendering of Markdown posts.
+	ContentFormat string    `db:"content_format" json:"content_format"`
+	ContentHTML   *string   `db:"content_html" json:"content_html,omitempty"`
+	CommentCount  int       `db:"comment_count" json:"comment_count"`
+	Comments      []Comment `json:"comments"`
+	Visibility    string    `db:"visibility" json:"visibility"`
+	// FollowedTags lists the viewer's followed tags that this post is indexed under.
+	FollowedTags JSONPayload `db:"followed_tags" json:"followed_tags,omitempty"`
+	// Mentions lists the @handle spans resolved to users.
+	Mentions JSONPayload `db:"mentions" json:"mentions"`
+	// RepostOfID references the original of a repost (empty content) or quote post. RepostOf is
+	// that original, nil when the viewer cannot see it.
+	RepostOfID  *int  `db:"repost_of_id" json:"repost_of_id"`
+	RepostOf    *Post `json:"repost_of,omitempty"`
+	RepostCount int   `db:"repost_count" json:"repost_count"`
+	QuoteCount  int   `db:"quote_count" json:"quote_count"`
+	// Reposted reports whether the viewer reposted this post.
+	Reposted bool `db:"reposted" json:"reposted"`
+	// Poll is the attached poll with its results, omitted when there is none.
+	Poll JSONPayload `db:"poll" json:"poll,omitempty"`
+	// LinkPreview is the preview of the first link in the content, omitted until it has been fetched.
+	LinkPreview JSONPayload `db:"link_preview" json:"link_preview,omitempty"`
+	// Attachments lists the attached files in order.
+	Attachments JSONPayload `db:"attachments" json:"attachments"`
 
 	UserSummary
+	ReactionSummary
 }
 
-func (db *DB) InsertPost(userID int, content string, file []byte, visibility string, currentDateTime string) (int, error) {
+// InsertPost adds a post. contentHTML is the rendering of content in format, nil for plain text.
+func (db *DB) InsertPost(userID int, content, format string, contentHTML *string, mediaID *int, visibility string, currentDateTime string) (int, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
 	defer cancel()
 
 	query := `
-	    INSERT INTO post (user_id, content, file, visibility, created_at) 
-	    VALUES ($1, $2, $3, $4, $5)`
+	    INSERT INTO post (user_id, content, content_format, content_html, media_id, visibility, created_at)
+	    VALUES ($1, $2, $3, $4, $5, $6, $7)`
 
-	result, err := db.ExecContext(ctx, query, userID, content, file, visibility, currentDateTime)
+	result, err := db.ExecContext(ctx, query, userID, content, format, contentHTML, mediaID, visibility, currentDateTime)
 	if err != nil {
 		return 0, err
 	}
@@ -68,36 +96,29 @@ func (db *DB) CanUserViewPost(viewerID, postID int) (bool, error) {
 
 	var ownerID int
 	var visibility string
-	if err := db.QueryRowContext(ctx, "SELECT user_id, visibility FROM post WHERE id = $1", postID).Scan(&ownerID, &visibility); err != nil {
+	var deleted bool
+	query := "SELECT user_id, visibility, deleted_at IS NOT NULL FROM post WHERE id = $1"
+	if err := db.QueryRowContext(ctx, query, postID).Scan(&ownerID, &visibility, &deleted); err != nil {
 		return false, err
 	}
 
-	// Owner always sees their own post.
-	if viewerID == ownerID {
-		return true, nil
-	}
+	resource := policy.Resource{Kind: policy.KindPost, OwnerID: ownerID, Visibility: visibility, Deleted: deleted}
 
-	// Public posts visible to all.
-	if visibility == "public" {
-		return true, nil
-	}
-
-	// Followers-only posts require accepted follow.
-	if visibility == "private" {
-		return db.IsFollowing(viewerID, ownerID)
+	rel, err := db.Relation(viewerID, ownerID)
+	if err != nil {
+		return false, err
 	}
 
-	// Limited posts require explicit allow list.
-	if visibility == "limited" {
+	if visibility == policy.VisibilityLimited {
 		var count int
 		query := `SELECT COUNT(*) FROM post_user_can_view WHERE post_id = $1 AND user_id = $2`
 		if err := db.GetContext(ctx, &count, query, postID, viewerID); err != nil {
 			return false, err
 		}
-		return count > 0, nil
+		rel.AllowListed = count > 0
 	}
 
-	return false, nil
+	return policy.Allowed(policy.Viewer{ID: viewerID}, policy.ViewPost, resource, rel), nil
 }
 
 // return posts by a target user that the context user can view
@@ -109,45 +130,21 @@ func (db *DB) PostsVisibleFromUser(viewerID, targetUserID int) ([]Post, error) {
 
 	query := `
 		SELECT 
-			p.id, u.f_name, u.l_name, u.avatar,
-			p.content, p.file, p.created_at, p.visibility,
-			COALESCE(COUNT(c.id), 0) AS comment_count
+			p.id, u.id AS user_id, u.f_name, u.l_name, u.avatar_id, ` + altTextColumnSQL("u.avatar_id", "avatar_alt_text") + `,
+			p.content, p.media_id, ` + altTextColumnSQL("p.media_id", "media_alt_text") + `, p.created_at, p.edited_at, p.content_format, p.content_html, p.visibility,
+			COALESCE(COUNT(c.id), 0) AS comment_count,
+			` + reactionColumnsSQL(ContentPost, "p.id", "$1") + `,
+			` + mentionColumnsSQL(ContentPost, "p.id") + `,
+			` + repostColumnsSQL("p", "$1") + `,
+			` + pollColumnsSQL(ContentPost, "p.id", "$1") + `,
+			` + attachmentColumnsSQL(ContentPost, "p.id", "$1") + `,
+			` + linkPreviewColumnSQL("p") + `
 		FROM post p
 		JOIN user u ON p.user_id = u.id
-		LEFT JOIN post_comment c ON p.id = c.post_id
-		WHERE 
-			p.user_id = $2
-			AND (
-				-- Viewer is the same as the target → can see everything
-				$1 = $2
-
-				-- Or public posts
-				OR p.visibility = 'public'
-
-				-- Or private posts (if viewer follows target)
-				OR (
-					p.visibility = 'private'
-					AND EXISTS (
-						SELECT 1
-						FROM follow_request f
-						WHERE f.requester_id = $1
-						  AND f.target_id = $2
-						  AND f.status = 'accepted'
-					)
-				)
-
-				-- Or limited posts (if viewer is explicitly allowed)
-				OR (
-					p.visibility = 'limited'
-					AND EXISTS (
-						SELECT 1
-						FROM post_user_can_view pcv
-						WHERE pcv.post_id = p.id
-						  AND pcv.user_id = $1
-					)
-				)
-			)
-		GROUP BY p.id, u.f_name, u.l_name, u.avatar, p.content, p.file, p.created_at, p.visibility
+		LEFT JOIN post_comment c ON p.id = c.post_id AND c.deleted_at IS NULL
+		WHERE ` + policy.PostVisibleSQL("p", "$1") + `
+			AND p.user_id = $2
+		GROUP BY p.id, u.id, u.f_name, u.l_name, u.avatar_id, p.content, p.media_id, p.created_at, p.edited_at, p.content_format, p.content_html, p.visibility, p.repost_of_id
 		ORDER BY p.created_at DESC;
 	`
 
@@ -155,6 +152,9 @@ func (db *DB) PostsVisibleFromUser(viewerID, targetUserID int) ([]Post, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := db.attachReposts(ctx, viewerID, posts); err != nil {
+		return nil, err
+	}
 	return posts, nil
 }
 
@@ -166,13 +166,13 @@ func (db *DB) PrivatePostsByUserID(userID int) ([]Post, error) {
 	var posts []Post
 
 	query := `
-		SELECT p.id, u.f_name, u.l_name, u.avatar, p.content, p.file, p.created_at, p.visibility,
+		SELECT p.id, u.f_name, u.l_name, u.avatar_id, ` + altTextColumnSQL("u.avatar_id", "avatar_alt_text") + `, p.content, p.media_id, ` + altTextColumnSQL("p.media_id", "media_alt_text") + `, p.created_at, p.edited_at, p.content_format, p.content_html, p.visibility,
 		COALESCE(COUNT(c.id), 0) as comment_count
 		FROM post p
 		JOIN user u ON p.user_id = u.id
-		LEFT JOIN post_comment c ON p.id = c.post_id
-		WHERE p.user_id = $1 AND p.visibility = 'private'
-		GROUP BY p.id, u.f_name, u.l_name, u.avatar, p.content, p.file, p.created_at, p.visibility
+		LEFT JOIN post_comment c ON p.id = c.post_id AND c.deleted_at IS NULL
+		WHERE p.user_id = $1 AND p.visibility = 'private' AND p.deleted_at IS NULL
+		GROUP BY p.id, u.f_name, u.l_name, u.avatar_id, p.content, p.media_id, p.created_at, p.edited_at, p.content_format, p.content_html, p.visibility
 		ORDER BY p.created_at DESC`
 
 	err := db.SelectContext(ctx, &posts, query, userID)
@@ -183,70 +183,153 @@ func (db *DB) PrivatePostsByUserID(userID int) ([]Post, error) {
 	return posts, nil
 }
 
-// Retrieves all private & public posts a user can view
-func (db *DB) AllPostsByUserID(userID int) ([]Post, error) {
+// FeedMode selects which posts the home feed includes and how they are ordered.
+type FeedMode string
+
+const (
+	// FeedChronological lists every visible post, newest first.
+	FeedChronological FeedMode = "chronological"
+	// FeedFollowing lists visible posts by the viewer, the users they follow, or under tags they follow.
+	FeedFollowing FeedMode = "following"
+	// FeedRanked orders visible posts by engagement decayed by age.
+	FeedRanked FeedMode = "ranked"
+)
+
+// Engagement weights of the ranked feed. The score of a post is
+// (1 + comments*feedCommentWeight + reactions*feedReactionWeight) / (hours since posting + 2)^2.
+const (
+	feedCommentWeight  = 3
+	feedReactionWeight = 1
+	feedAgeOffsetHrs   = 2
+)
 
+// FeedOptions controls the home feed page.
+type FeedOptions struct {
+	Mode FeedMode
+	// After returns posts older than the cursor (next page). Chronological and following modes only.
+	After *Cursor
+	// Since returns posts newer than the cursor, oldest first, for polling. Chronological and following modes only.
+	Since *Cursor
+	// Offset skips ranked rows; the ranked order is not stable enough for keyset pagination.
+	Offset int
+	Limit  int
+}
+
+// FeedPosts returns a page of the home feed of viewerID.
+// Posts indexed under tags the user follows carry the matching tag names in FollowedTags;
+// tag follows never widen the audience of a post beyond its visibility.
+func (db *DB) FeedPosts(viewerID int, opts FeedOptions) ([]Post, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
 	defer cancel()
 
-	var posts []Post
+	if opts.Limit <= 0 {
+		opts.Limit = 25
+	}
 
+	// SQLite binds $N placeholders in order of first appearance, so they are numbered as they are appended.
+	args := []any{viewerID}
 	query := ` SELECT 
 	    p.id,
+	    u.id AS user_id,
 	    u.f_name,
 	    u.l_name,
-	    u.avatar,
+	    u.avatar_id, ` + altTextColumnSQL("u.avatar_id", "avatar_alt_text") + `,
 	    p.content,
-	    p.file,
-		    p.created_at,
-		    p.visibility,
-	    COALESCE(COUNT(c.id), 0) AS comment_count
+	    p.media_id, ` + altTextColumnSQL("p.media_id", "media_alt_text") + `,
+	    p.created_at,
+	    p.edited_at, p.content_format, p.content_html,
+	    p.visibility,
+	    COALESCE(COUNT(c.id), 0) AS comment_count,
+	    NULLIF((
+			SELECT json_group_array(t.name)
+			FROM post_tag pt
+			JOIN tag t ON t.id = pt.tag_id
+			JOIN tag_follow tf ON tf.tag_id = pt.tag_id AND tf.user_id = $1
+			WHERE pt.post_id = p.id
+	    ), '[]') AS followed_tags,
+	    ` + reactionColumnsSQL(ContentPost, "p.id", "$1") + `,
+	    ` + mentionColumnsSQL(ContentPost, "p.id") + `,
+	    ` + repostColumnsSQL("p", "$1") + `,
+	    ` + pollColumnsSQL(ContentPost, "p.id", "$1") + `,
+	    ` + attachmentColumnsSQL(ContentPost, "p.id", "$1") + `,
+	    ` + linkPreviewColumnSQL("p") + `
 	FROM post p
 	JOIN user u 
 	    ON p.user_id = u.id
 	LEFT JOIN post_comment c 
-	    ON p.id = c.post_id
-	WHERE 
-			(
-				p.visibility = 'public'
-
-				OR (
-					p.visibility = 'private'
-					AND EXISTS (
-						SELECT 1 
-						FROM follow_request f
-						WHERE f.requester_id = $1       -- current user
-						AND f.target_id = p.user_id   -- poster
-						AND f.status = 'accepted'
-					)
-				)
-
-				OR (
-					p.visibility = 'limited'
-					AND EXISTS (
-						SELECT 1
-						FROM post_user_can_view pcv
-						WHERE pcv.post_id = p.id
-						AND pcv.user_id = $1
-					)
-				)
-
-				OR p.user_id = $1
+	    ON p.id = c.post_id AND c.deleted_at IS NULL
+	WHERE ` + policy.PostVisibleSQL("p", "$1")
+
+	if opts.Mode == FeedFollowing {
+		query += `
+	    AND (
+			p.user_id = $1
+			OR EXISTS (
+				SELECT 1 FROM follow_request f
+				WHERE f.requester_id = $1 AND f.target_id = p.user_id AND f.status = 'accepted'
 			)
+			OR EXISTS (
+				SELECT 1 FROM post_tag pt
+				JOIN tag_follow tf ON tf.tag_id = pt.tag_id AND tf.user_id = $1
+				WHERE pt.post_id = p.id
+			)
+	    )`
+	}
+
+	if opts.Mode != FeedRanked {
+		if opts.After != nil {
+			args = append(args, opts.After.Timestamp(), opts.After.ID)
+			query += fmt.Sprintf(`
+	    AND (datetime(p.created_at), p.id) < (datetime($%d), $%d)`, len(args)-1, len(args))
+		}
+		if opts.Since != nil {
+			args = append(args, opts.Since.Timestamp(), opts.Since.ID)
+			query += fmt.Sprintf(`
+	    AND (datetime(p.created_at), p.id) > (datetime($%d), $%d)`, len(args)-1, len(args))
+		}
+	}
+
+	query += `
 	GROUP BY 
-	    p.id, u.f_name, u.l_name, u.avatar, p.content, p.file, p.created_at, p.visibility
+	    p.id, u.id, u.f_name, u.l_name, u.avatar_id, p.content, p.media_id, p.created_at, p.edited_at, p.content_format, p.content_html, p.visibility, p.repost_of_id`
+
+	switch {
+	case opts.Mode == FeedRanked:
+		query += fmt.Sprintf(`
+	ORDER BY 
+	    (1.0 + COUNT(c.id) * %[1]d
+	        + (SELECT COUNT(*) FROM reaction r WHERE r.target_type = '%[4]s' AND r.target_id = p.id) * %[2]d)
+	        / (((julianday('now') - julianday(p.created_at)) * 24 + %[3]d) * ((julianday('now') - julianday(p.created_at)) * 24 + %[3]d)) DESC,
+	    p.id DESC`, feedCommentWeight, feedReactionWeight, feedAgeOffsetHrs, ContentPost)
+	case opts.Since != nil:
+		query += `
 	ORDER BY 
-	    p.created_at DESC`
+	    datetime(p.created_at) ASC, p.id ASC`
+	default:
+		query += `
+	ORDER BY 
+	    datetime(p.created_at) DESC, p.id DESC`
+	}
 
-	err := db.SelectContext(ctx, &posts, query, userID)
-	if err != nil {
+	args = append(args, opts.Limit)
+	query += fmt.Sprintf(` LIMIT $%d`, len(args))
+	if opts.Mode == FeedRanked {
+		args = append(args, opts.Offset)
+		query += fmt.Sprintf(` OFFSET $%d`, len(args))
+	}
+
+	var posts []Post
+	if err := db.SelectContext(ctx, &posts, query, args...); err != nil {
+		return nil, err
+	}
+	if err := db.attachReposts(ctx, viewerID, posts); err != nil {
 		return nil, err
 	}
 
 	return posts, nil
 }
 
-// Retrieves all limited posts visible to the given user
+// Retrieves the limited posts other users shared with the given user
 func (db *DB) LimitedPostsByUserID(userID int) ([]Post, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
 	defer cancel()
@@ -258,24 +341,24 @@ func (db *DB) LimitedPostsByUserID(userID int) ([]Post, error) {
 			p.id,
 			u.f_name,
 			u.l_name,
-			u.avatar,
+			u.avatar_id, ` + altTextColumnSQL("u.avatar_id", "avatar_alt_text") + `,
 			p.content,
-			p.file,
+			p.media_id, ` + altTextColumnSQL("p.media_id", "media_alt_text") + `,
 			p.created_at,
+			p.edited_at, p.content_format, p.content_html,
 			p.visibility,
 			COALESCE(COUNT(c.id), 0) AS comment_count
 		FROM post p
 		JOIN user u 
 			ON p.user_id = u.id
 		LEFT JOIN post_comment c 
-			ON p.id = c.post_id
-		JOIN post_user_can_view pcv 
-			ON pcv.post_id = p.id
+			ON p.id = c.post_id AND c.deleted_at IS NULL
 		WHERE 
-			p.visibility = 'limited'
-			AND pcv.user_id = $1  
+			` + policy.PostVisibleSQL("p", "$1") + `
+			AND p.visibility = 'limited'
+			AND p.user_id != $1
 		GROUP BY 
-			p.id, u.f_name, u.l_name, u.avatar, p.content, p.file, p.created_at, p.visibility
+			p.id, u.f_name, u.l_name, u.avatar_id, p.content, p.media_id, p.created_at, p.edited_at, p.content_format, p.content_html, p.visibility
 		ORDER BY 
 			p.created_at DESC
 	`
@@ -0,0 +1,177 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Chat messages can be reacted to as well as posts and comments (see the Content* kinds).
+const (
+	ContentMessage      = "message"
+	ContentGroupMessage = "group_message"
+)
+
+// ReactionSummary is embedded in reactable rows selected with reactionColumnsSQL.
+type ReactionSummary struct {
+	// Reactions maps each reaction kind to its count.
+	Reactions JSONPayload `db:"reactions" json:"reactions"`
+	// MyReactions lists the kinds the viewer reacted with.
+	MyReactions JSONPayload `db:"my_reactions" json:"my_reactions"`
+}
+
+// Reactor is a user who reacted to a post, comment or message.
+type Reactor struct {
+	Kind      string    `db:"kind" json:"kind"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+
+	UserSummary
+}
+
+// reactionColumnsSQL returns the reactions and my_reactions select columns of the row whose
+// id is idExpr, for the viewer bound to viewerParam. targetType must be a trusted constant.
+func reactionColumnsSQL(targetType, idExpr, viewerParam string) string {
+	return fmt.Sprintf(`(
+			SELECT json_group_object(kind, n)
+			FROM (
+				SELECT r.kind, COUNT(*) AS n
+				FROM reaction r
+				WHERE r.target_type = '%[1]s' AND r.target_id = %[2]s
+				GROUP BY r.kind
+			)
+		) AS reactions,
+		(
+			SELECT json_group_array(r.kind)
+			FROM reaction r
+			WHERE r.target_type = '%[1]s' AND r.target_id = %[2]s AND r.user_id = %[3]s
+		) AS my_reactions`, targetType, idExpr, viewerParam)
+}
+
+// ToggleReaction adds the reaction of userID, or removes it when it already exists.
+// It reports whether the reaction is now present.
+func (db *DB) ToggleReaction(targetType string, targetID, userID int, kind, createdAt string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `DELETE FROM reaction WHERE target_type = $1 AND target_id = $2 AND user_id = $3 AND kind = $4`
+	result, err := db.ExecContext(ctx, query, targetType, targetID, userID, kind)
+	if err != nil {
+		return false, err
+	}
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if removed > 0 {
+		return false, nil
+	}
+
+	query = `
+		INSERT OR IGNORE INTO reaction (target_type, target_id, user_id, kind, created_at)
+		VALUES ($1, $2, $3, $4, $5)`
+	if _, err := db.ExecContext(ctx, query, targetType, targetID, userID, kind, createdAt); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// ReactionCounts returns the number of reactions per kind on a post, comment or message.
+func (db *DB) ReactionCounts(targetType string, targetID int) (map[string]int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		SELECT kind, COUNT(*)
+		FROM reaction
+		WHERE target_type = $1 AND target_id = $2
+		GROUP BY kind`
+
+	rows, err := db.QueryContext(ctx, query, targetType, targetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]int)
+	for rows.Next() {
+		var kind string
+		var count int
+		if err := rows.Scan(&kind, &count); err != nil {
+			return nil, err
+		}
+		counts[kind] = count
+	}
+
+	return counts, rows.Err()
+}
+
+// Reactors lists who reacted to a post, comment or message, newest first.
+// An empty kind lists every kind.
+func (db *DB) Reactors(targetType string, targetID int, kind string, limit, offset int) ([]Reactor, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	if limit <= 0 {
+		limit = 50
+	}
+
+	query := `
+		SELECT r.kind, r.created_at, u.id AS user_id, u.f_name, u.l_name, u.avatar_id, ` + altTextColumnSQL("u.avatar_id", "avatar_alt_text") + `
+		FROM reaction r
+		JOIN user u ON u.id = r.user_id
+		WHERE r.target_type = $1 AND r.target_id = $2 AND ($3 = '' OR r.kind = $3)
+		ORDER BY r.created_at DESC, r.id DESC
+		LIMIT $4 OFFSET $5`
+
+	var reactors []Reactor
+	if err := db.SelectContext(ctx, &reactors, query, targetType, targetID, kind, limit, offset); err != nil {
+		return nil, err
+	}
+
+	return reactors, nil
+}
+
+// MessageParticipants returns the two users of the conversation a direct message belongs to.
+// The bool result reports whether the message exists.
+func (db *DB) MessageParticipants(messageID int) (int, int, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		SELECT c.user1_id, c.user2_id
+		FROM conversation_message m
+		JOIN conversation c ON c.id = m.conversation_id
+		WHERE m.id = $1`
+
+	var user1ID, user2ID int
+	err := db.QueryRowContext(ctx, query, messageID).Scan(&user1ID, &user2ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, 0, false, nil
+	}
+	if err != nil {
+		return 0, 0, false, err
+	}
+
+	return user1ID, user2ID, true, nil
+}
+
+// GroupMessageGroupID returns the group a group chat message belongs to.
+// The bool result reports whether the message exists.
+func (db *DB) GroupMessageGroupID(messageID int) (int, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	var groupID int
+	err := db.QueryRowContext(ctx, `SELECT group_id FROM group_messages WHERE id = $1`, messageID).Scan(&groupID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	return groupID, true, nil
+}
@@ -0,0 +1,154 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"brainbook-api/internal/policy"
+)
+
+// repostColumnsSQL returns the repost_of_id, repost_count, quote_count and reposted select columns
+// of the post aliased as postAlias, for the viewer bound to viewerParam. Plain reposts have empty
+// content; quote posts do not.
+func repostColumnsSQL(postAlias, viewerParam string) string {
+	return fmt.Sprintf(`%[1]s.repost_of_id,
+		(
+			SELECT COUNT(*) FROM post rp
+			WHERE rp.repost_of_id = %[1]s.id AND rp.deleted_at IS NULL AND COALESCE(rp.content, '') = ''
+		) AS repost_count,
+		(
+			SELECT COUNT(*) FROM post rp
+			WHERE rp.repost_of_id = %[1]s.id AND rp.deleted_at IS NULL AND COALESCE(rp.content, '') != ''
+		) AS quote_count,
+		EXISTS (
+			SELECT 1 FROM post rp
+			WHERE rp.repost_of_id = %[1]s.id AND rp.deleted_at IS NULL AND COALESCE(rp.content, '') = '' AND rp.user_id = %[2]s
+		) AS reposted`, postAlias, viewerParam)
+}
+
+// InsertRepost adds a repost of originalID, or a quote post when content is not empty.
+// contentHTML is the rendering of content in format, nil for plain text.
+func (db *DB) InsertRepost(userID, originalID int, content, format string, contentHTML *string, visibility, currentDateTime string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		INSERT INTO post (user_id, repost_of_id, content, content_format, content_html, visibility, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	result, err := db.ExecContext(ctx, query, userID, originalID, content, format, contentHTML, visibility, currentDateTime)
+	if err != nil {
+		return 0, err
+	}
+
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return int(id), nil
+}
+
+// RepostOf returns the original referenced by a post, 0 when it is not a repost or quote post,
+// and whether it is a plain repost.
+func (db *DB) RepostOf(postID int) (int, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	var originalID sql.NullInt64
+	var plain bool
+	query := `SELECT repost_of_id, COALESCE(content, '') = '' FROM post WHERE id = $1`
+	if err := db.QueryRowContext(ctx, query, postID).Scan(&originalID, &plain); err != nil {
+		return 0, false, err
+	}
+	if !originalID.Valid {
+		return 0, false, nil
+	}
+
+	return int(originalID.Int64), plain, nil
+}
+
+// PostVisibility returns the visibility of a post.
+func (db *DB) PostVisibility(postID int) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	var visibility string
+	err := db.GetContext(ctx, &visibility, `SELECT visibility FROM post WHERE id = $1`, postID)
+	return visibility, err
+}
+
+// UserRepost returns the plain repost userID made of originalID. The bool result is false when there is none.
+func (db *DB) UserRepost(userID, originalID int) (int, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	var id int
+	query := `
+		SELECT id FROM post
+		WHERE repost_of_id = $1 AND user_id = $2 AND deleted_at IS NULL AND COALESCE(content, '') = ''
+		LIMIT 1`
+	err := db.GetContext(ctx, &id, query, originalID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+
+	return id, true, nil
+}
+
+// attachReposts sets RepostOf on the reposts and quote posts among posts to their original,
+// when viewerID can see it. Originals that were deleted or are hidden from the viewer stay nil.
+func (db *DB) attachReposts(ctx context.Context, viewerID int, posts []Post) error {
+	args := []any{viewerID}
+	var placeholders []string
+	for _, post := range posts {
+		if post.RepostOfID != nil {
+			args = append(args, *post.RepostOfID)
+			placeholders = append(placeholders, fmt.Sprintf("$%d", len(args)))
+		}
+	}
+	if len(placeholders) == 0 {
+		return nil
+	}
+
+	query := `
+		SELECT
+			p.id, u.id AS user_id, u.f_name, u.l_name, u.avatar_id, ` + altTextColumnSQL("u.avatar_id", "avatar_alt_text") + `,
+			p.content, p.media_id, ` + altTextColumnSQL("p.media_id", "media_alt_text") + `, p.created_at, p.edited_at, p.content_format, p.content_html, p.visibility,
+			COALESCE(COUNT(c.id), 0) AS comment_count,
+			` + reactionColumnsSQL(ContentPost, "p.id", "$1") + `,
+			` + mentionColumnsSQL(ContentPost, "p.id") + `,
+			` + repostColumnsSQL("p", "$1") + `,
+			` + pollColumnsSQL(ContentPost, "p.id", "$1") + `,
+			` + attachmentColumnsSQL(ContentPost, "p.id", "$1") + `,
+			` + linkPreviewColumnSQL("p") + `
+		FROM post p
+		JOIN user u ON p.user_id = u.id
+		LEFT JOIN post_comment c ON p.id = c.post_id AND c.deleted_at IS NULL
+		WHERE ` + policy.PostVisibleSQL("p", "$1") + `
+			AND p.id IN (` + strings.Join(placeholders, ", ") + `)
+		GROUP BY p.id, u.id, u.f_name, u.l_name, u.avatar_id, p.content, p.media_id, p.created_at, p.edited_at, p.content_format, p.content_html, p.visibility, p.repost_of_id`
+
+	var originals []Post
+	if err := db.SelectContext(ctx, &originals, query, args...); err != nil {
+		return err
+	}
+
+	byID := make(map[int]*Post, len(originals))
+	for i := range originals {
+		byID[originals[i].ID] = &originals[i]
+	}
+	for i := range posts {
+		if posts[i].RepostOfID != nil {
+			posts[i].RepostOf = byID[*posts[i].RepostOfID]
+		}
+	}
+
+	return nil
+}
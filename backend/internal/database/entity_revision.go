@@ -0,0 +1,90 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Revision is a previous version of a post or comment.
+type Revision struct {
+	ID            int    `db:"id" json:"id"`
+	Content       string `db:"content" json:"content"`
+	ContentFormat string `db:"content_format" json:"content_format"`
+	MediaID       *int   `db:"media_id" json:"media_id"`
+	// MediaAltText is the alt text of the media.
+	MediaAltText *string `db:"media_alt_text" json:"media_alt_text"`
+	// WrittenAt is when this version was created or last edited.
+	WrittenAt time.Time `db:"written_at" json:"written_at"`
+	// ReplacedAt is when an edit superseded this version.
+	ReplacedAt time.Time `db:"replaced_at" json:"replaced_at"`
+}
+
+// EditContent stores the current version of a post or comment as a revision, then replaces its
+// content and file and sets edited_at. A nil mediaID keeps the current file unless removeFile is set.
+// format and contentHTML are only stored for content with a format (see HasContentFormat).
+func (db *DB) EditContent(targetType string, targetID int, content, format string, contentHTML *string, mediaID *int, removeFile bool, editedAt string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	target, ok := contentTargets[targetType]
+	if !ok {
+		return fmt.Errorf("unknown content type: %s", targetType)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	revisionQuery := fmt.Sprintf(`
+		INSERT INTO content_revision (target_type, target_id, content, content_format, media_id, written_at, replaced_at)
+		SELECT $1, id, content, %s, media_id, COALESCE(edited_at, created_at), $2
+		FROM %s
+		WHERE id = $3`, formatColumnSQL(targetType), target.table)
+
+	if _, err := tx.ExecContext(ctx, revisionQuery, targetType, editedAt, targetID); err != nil {
+		return err
+	}
+
+	updateQuery := fmt.Sprintf(`
+		UPDATE %s
+		SET content = $1,
+			media_id = CASE WHEN $2 THEN NULL ELSE COALESCE($3, media_id) END,
+			edited_at = $4
+		WHERE id = $5`, target.table)
+
+	if _, err := tx.ExecContext(ctx, updateQuery, content, removeFile, mediaID, editedAt, targetID); err != nil {
+		return err
+	}
+
+	if target.formatted {
+		formatQuery := fmt.Sprintf(`UPDATE %s SET content_format = $1, content_html = $2 WHERE id = $3`, target.table)
+		if _, err := tx.ExecContext(ctx, formatQuery, format, contentHTML, targetID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// RevisionsFor returns the previous versions of a post or comment, newest first.
+func (db *DB) RevisionsFor(targetType string, targetID int) ([]Revision, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		SELECT r.id, r.content, r.content_format, r.media_id, ` + altTextColumnSQL("r.media_id", "media_alt_text") + `,
+			r.written_at, r.replaced_at
+		FROM content_revision r
+		WHERE r.target_type = $1 AND r.target_id = $2
+		ORDER BY r.id DESC`
+
+	var revisions []Revision
+	if err := db.SelectContext(ctx, &revisions, query, targetType, targetID); err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
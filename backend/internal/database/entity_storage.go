@@ -0,0 +1,178 @@
+package database
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// mediaBytesSQL is the disk space taken by the media row m: the file and its resized variants.
+const mediaBytesSQL = `m.size + COALESCE((SELECT SUM(v.size) FROM media_variant v WHERE v.media_id = m.id), 0)`
+
+// groupMediaSQL lists the (group_id, media_id) pairs of the files shared in groups: on group
+// posts, their comments and their attachments, and in group chat. Deleted content no longer
+// counts.
+const groupMediaSQL = `
+	SELECT gp.group_id, gp.media_id FROM group_posts gp
+	WHERE gp.media_id IS NOT NULL AND gp.deleted_at IS NULL
+	UNION
+	SELECT gp.group_id, gc.media_id FROM group_post_comments gc JOIN group_posts gp ON gp.id = gc.group_post_id
+	WHERE gc.media_id IS NOT NULL AND gc.deleted_at IS NULL AND gp.deleted_at IS NULL
+	UNION
+	SELECT gp.group_id, ca.media_id FROM content_attachment ca JOIN group_posts gp ON ca.target_type = 'group_post' AND gp.id = ca.target_id
+	WHERE gp.deleted_at IS NULL
+	UNION
+	SELECT gmsg.group_id, ca.media_id FROM content_attachment ca JOIN group_messages gmsg ON ca.target_type = 'group_message' AND gmsg.id = ca.target_id`
+
+// StorageUsage is the disk space taken by a set of media, resized variants included.
+type StorageUsage struct {
+	Bytes int64 `db:"bytes" json:"used_bytes"`
+	Files int   `db:"files" json:"file_count"`
+}
+
+// UserStorage is the storage usage of one user.
+type UserStorage struct {
+	UserSummary
+	Nickname *string `db:"nickname" json:"nickname"`
+	StorageUsage
+}
+
+// GroupStorage is the storage usage of one group.
+type GroupStorage struct {
+	GroupID int    `db:"group_id" json:"group_id"`
+	Title   string `db:"title" json:"title"`
+	StorageUsage
+}
+
+// UserStorageUsage returns the space taken by the uploads of userID.
+func (db *DB) UserStorageUsage(userID int) (StorageUsage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		SELECT COUNT(*) AS files, COALESCE(SUM(` + mediaBytesSQL + `), 0) AS bytes
+		FROM media m
+		WHERE m.owner_id = $1`
+
+	var usage StorageUsage
+	err := db.GetContext(ctx, &usage, query, userID)
+	return usage, err
+}
+
+// LargestUserMedia returns up to limit of the uploads of userID, largest first.
+func (db *DB) LargestUserMedia(userID, limit int) ([]Media, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		SELECT id, owner_id, sha256, mime_type, size, width, height, file_name, alt_text, scan_status, scan_result, created_at
+		FROM media m
+		WHERE m.owner_id = $1
+		ORDER BY ` + mediaBytesSQL + ` DESC, m.id DESC
+		LIMIT $2`
+
+	files := []Media{}
+	if err := db.SelectContext(ctx, &files, query, userID, limit); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// GroupStorageUsage returns the space taken by the files shared in groupID. Files shared several
+// times count once.
+func (db *DB) GroupStorageUsage(groupID int) (StorageUsage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		SELECT COUNT(*) AS files, COALESCE(SUM(` + mediaBytesSQL + `), 0) AS bytes
+		FROM media m
+		WHERE m.id IN (SELECT gm.media_id FROM (` + groupMediaSQL + `) gm WHERE gm.group_id = $1)`
+
+	var usage StorageUsage
+	err := db.GetContext(ctx, &usage, query, groupID)
+	return usage, err
+}
+
+// GroupStorageAdded returns the space that sharing mediaIDs in groupID adds to its usage,
+// leaving out files the group already holds.
+func (db *DB) GroupStorageAdded(groupID int, mediaIDs []int) (int64, error) {
+	if len(mediaIDs) == 0 {
+		return 0, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	idList, err := json.Marshal(mediaIDs)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+		SELECT COALESCE(SUM(` + mediaBytesSQL + `), 0)
+		FROM media m
+		WHERE m.id IN (SELECT value FROM json_each($1))
+		  AND m.id NOT IN (SELECT gm.media_id FROM (` + groupMediaSQL + `) gm WHERE gm.group_id = $2)`
+
+	var added int64
+	err = db.GetContext(ctx, &added, query, string(idList), groupID)
+	return added, err
+}
+
+// TotalStorageUsage returns the space taken by all media.
+func (db *DB) TotalStorageUsage() (StorageUsage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `SELECT COUNT(*) AS files, COALESCE(SUM(` + mediaBytesSQL + `), 0) AS bytes FROM media m`
+
+	var usage StorageUsage
+	err := db.GetContext(ctx, &usage, query)
+	return usage, err
+}
+
+// TopUserStorage returns up to limit users taking the most space, largest first.
+func (db *DB) TopUserStorage(limit int) ([]UserStorage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		SELECT u.id AS user_id, u.f_name, u.l_name, u.avatar_id, ` + altTextColumnSQL("u.avatar_id", "avatar_alt_text") + `, u.nickname,
+			COUNT(*) AS files, SUM(` + mediaBytesSQL + `) AS bytes
+		FROM media m
+		JOIN user u ON u.id = m.owner_id
+		GROUP BY u.id
+		ORDER BY bytes DESC, u.id
+		LIMIT $1`
+
+	users := []UserStorage{}
+	if err := db.SelectContext(ctx, &users, query, limit); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// TopGroupStorage returns up to limit groups holding the most shared files, largest first.
+func (db *DB) TopGroupStorage(limit int) ([]GroupStorage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		SELECT g.id AS group_id, COALESCE(g.title, '') AS title,
+			COUNT(*) AS files, SUM(` + mediaBytesSQL + `) AS bytes
+		FROM (` + groupMediaSQL + `) gm
+		JOIN groups g ON g.id = gm.group_id
+		JOIN media m ON m.id = gm.media_id
+		GROUP BY g.id
+		ORDER BY bytes DESC, g.id
+		LIMIT $1`
+
+	groups := []GroupStorage{}
+	if err := db.SelectContext(ctx, &groups, query, limit); err != nil {
+		return nil, err
+	}
+
+	return groups, nil
+}
@@ -0,0 +1,258 @@
+package database
+
+import (
+	"context"
+	"time"
+
+	"brainbook-api/internal/policy"
+)
+
+type Tag struct {
+	ID            int       `db:"id" json:"id"`
+	Name          string    `db:"name" json:"name"`
+	PostCount     int       `db:"post_count" json:"post_count"`
+	FollowerCount int       `db:"follower_count" json:"follower_count"`
+	CreatedAt     time.Time `db:"created_at" json:"created_at"`
+}
+
+// IndexPostTags replaces the tag index entries of a post with the given tag names.
+// Tags are expected to be normalized already (see the hashtag package).
+func (db *DB) IndexPostTags(postID int, tags []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	if _, err := db.ExecContext(ctx, `DELETE FROM post_tag WHERE post_id = $1`, postID); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT OR IGNORE INTO post_tag (post_id, tag_id)
+		SELECT $1, id FROM tag WHERE name = $2
+	`
+
+	for _, name := range tags {
+		if err := db.insertTag(ctx, name); err != nil {
+			return err
+		}
+		if _, err := db.ExecContext(ctx, query, postID, name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// IndexGroupPostTags replaces the tag index entries of a group post with the given tag names.
+func (db *DB) IndexGroupPostTags(groupPostID int, tags []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	if _, err := db.ExecContext(ctx, `DELETE FROM group_post_tag WHERE group_post_id = $1`, groupPostID); err != nil {
+		return err
+	}
+
+	query := `
+		INSERT OR IGNORE INTO group_post_tag (group_post_id, tag_id)
+		SELECT $1, id FROM tag WHERE name = $2
+	`
+
+	for _, name := range tags {
+		if err := db.insertTag(ctx, name); err != nil {
+			return err
+		}
+		if _, err := db.ExecContext(ctx, query, groupPostID, name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (db *DB) insertTag(ctx context.Context, name string) error {
+	_, err := db.ExecContext(ctx, `INSERT OR IGNORE INTO tag (name) VALUES ($1)`, name)
+	return err
+}
+
+// TagByName returns a tag with its public post and follower counts.
+func (db *DB) TagByName(name string) (*Tag, bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		SELECT t.id, t.name, t.created_at,
+			(
+				SELECT COUNT(*)
+				FROM post_tag pt
+				JOIN post p ON p.id = pt.post_id
+				WHERE pt.tag_id = t.id AND p.visibility = 'public' AND p.deleted_at IS NULL
+			) AS post_count,
+			(SELECT COUNT(*) FROM tag_follow tf WHERE tf.tag_id = t.id) AS follower_count
+		FROM tag t
+		WHERE t.name = $1
+	`
+
+	var tags []Tag
+	if err := db.SelectContext(ctx, &tags, query, name); err != nil {
+		return nil, false, err
+	}
+	if len(tags) == 0 {
+		return nil, false, nil
+	}
+
+	return &tags[0], true, nil
+}
+
+// FollowTag makes userID follow the tag. Only tags already used by a post can be followed:
+// following a missing tag does nothing.
+func (db *DB) FollowTag(userID int, name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		INSERT OR IGNORE INTO tag_follow (user_id, tag_id)
+		SELECT $1, id FROM tag WHERE name = $2
+	`
+
+	_, err := db.ExecContext(ctx, query, userID, name)
+	return err
+}
+
+func (db *DB) UnfollowTag(userID int, name string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		DELETE FROM tag_follow
+		WHERE user_id = $1 AND tag_id = (SELECT id FROM tag WHERE name = $2)
+	`
+
+	_, err := db.ExecContext(ctx, query, userID, name)
+	return err
+}
+
+func (db *DB) IsFollowingTag(userID int, name string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	var count int
+	query := `
+		SELECT COUNT(*)
+		FROM tag_follow tf
+		JOIN tag t ON t.id = tf.tag_id
+		WHERE tf.user_id = $1 AND t.name = $2
+	`
+
+	if err := db.GetContext(ctx, &count, query, userID, name); err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
+// FollowedTags returns the tags followed by userID, most recently followed first.
+func (db *DB) FollowedTags(userID int) ([]Tag, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `
+		SELECT t.id, t.name, t.created_at,
+			(
+				SELECT COUNT(*)
+				FROM post_tag pt
+				JOIN post p ON p.id = pt.post_id
+				WHERE pt.tag_id = t.id AND p.visibility = 'public' AND p.deleted_at IS NULL
+			) AS post_count,
+			(SELECT COUNT(*) FROM tag_follow f WHERE f.tag_id = t.id) AS follower_count
+		FROM tag_follow tf
+		JOIN tag t ON t.id = tf.tag_id
+		WHERE tf.user_id = $1
+		ORDER BY tf.created_at DESC
+	`
+
+	var tags []Tag
+	if err := db.SelectContext(ctx, &tags, query, userID); err != nil {
+		return nil, err
+	}
+
+	return tags, nil
+}
+
+// PublicPostsByTag returns the public posts indexed under the given tag, newest first.
+func (db *DB) PublicPostsByTag(viewerID int, name string, limit, offset int) ([]Post, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	if limit <= 0 {
+		limit = 25
+	}
+
+	query := `
+		SELECT
+			p.id, u.id AS user_id, u.f_name, u.l_name, u.avatar_id, ` + altTextColumnSQL("u.avatar_id", "avatar_alt_text") + `,
+			p.content, p.media_id, ` + altTextColumnSQL("p.media_id", "media_alt_text") + `, p.created_at, p.edited_at, p.content_format, p.content_html, p.visibility,
+			COALESCE(COUNT(c.id), 0) AS comment_count,
+			` + reactionColumnsSQL(ContentPost, "p.id", "$1") + `,
+			` + mentionColumnsSQL(ContentPost, "p.id") + `,
+			` + repostColumnsSQL("p", "$1") + `,
+			` + pollColumnsSQL(ContentPost, "p.id", "$1") + `,
+			` + attachmentColumnsSQL(ContentPost, "p.id", "$1") + `,
+			` + linkPreviewColumnSQL("p") + `
+		FROM post p
+		JOIN post_tag pt ON pt.post_id = p.id
+		JOIN tag t ON t.id = pt.tag_id
+		JOIN user u ON p.user_id = u.id
+		LEFT JOIN post_comment c ON p.id = c.post_id AND c.deleted_at IS NULL
+		WHERE t.name = $2 AND p.visibility = 'public' AND p.deleted_at IS NULL
+		GROUP BY p.id, u.id, u.f_name, u.l_name, u.avatar_id, p.content, p.media_id, p.created_at, p.edited_at, p.content_format, p.content_html, p.visibility, p.repost_of_id
+		ORDER BY p.created_at DESC
+		LIMIT $3 OFFSET $4
+	`
+
+	var posts []Post
+	if err := db.SelectContext(ctx, &posts, query, viewerID, name, limit, offset); err != nil {
+		return nil, err
+	}
+	if err := db.attachReposts(ctx, viewerID, posts); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+// GroupPostsByTag returns the group posts indexed under the given tag in the groups viewerID
+// belongs to, newest first.
+func (db *DB) GroupPostsByTag(viewerID int, name string, limit, offset int) ([]GroupPost, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	if limit <= 0 {
+		limit = 25
+	}
+
+	query := `
+		SELECT
+			p.id, p.group_id, u.id AS user_id, u.f_name, u.l_name, u.avatar_id, ` + altTextColumnSQL("u.avatar_id", "avatar_alt_text") + `,
+			p.content, p.media_id, ` + altTextColumnSQL("p.media_id", "media_alt_text") + `, p.created_at, p.edited_at, p.content_format, p.content_html,
+			COALESCE(COUNT(gpc.id), 0) AS comment_count,
+			` + reactionColumnsSQL(ContentGroupPost, "p.id", "$1") + `,
+			` + mentionColumnsSQL(ContentGroupPost, "p.id") + `,
+			` + pollColumnsSQL(ContentGroupPost, "p.id", "$1") + `,
+			` + attachmentColumnsSQL(ContentGroupPost, "p.id", "$1") + `
+		FROM group_posts p
+		JOIN group_post_tag gpt ON gpt.group_post_id = p.id
+		JOIN tag t ON t.id = gpt.tag_id
+		JOIN user u ON p.user_id = u.id
+		LEFT JOIN group_post_comments gpc ON gpc.group_post_id = p.id AND gpc.deleted_at IS NULL
+		WHERE t.name = $2 AND p.deleted_at IS NULL AND ` + policy.GroupMemberSQL("p.group_id", "$1") + `
+		GROUP BY p.id, p.group_id, u.id, u.f_name, u.l_name, u.avatar_id, p.content, p.media_id, p.created_at, p.edited_at, p.content_format, p.content_html
+		ORDER BY p.created_at DESC
+		LIMIT $3 OFFSET $4
+	`
+
+	var posts []GroupPost
+	if err := db.SelectContext(ctx, &posts, query, viewerID, name, limit, offset); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
@@ -3,11 +3,13 @@ package database
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"time"
 
 	"brainbook-api/internal/cookie"
+	"brainbook-api/internal/policy"
 )
 
 type User struct {
@@ -17,10 +19,16 @@ type User struct {
 	Email          string    `db:"email" json:"email"`
 	HashedPassword string    `db:"hashed_password" json:"-"`
 	DOB            time.Time `db:"dob" json:"dob"`
-	Avatar         []byte    `db:"avatar" json:"avatar"`
+	AvatarID       *int      `db:"avatar_id" json:"avatar_id"`
+	AvatarAltText  *string   `db:"avatar_alt_text" json:"avatar_alt_text"`
 	Nickname       string    `db:"nickname" json:"nickname"`
-	Bio            string    `db:"bio" json:"bio"`
-	IsPublic       bool      `db:"is_public" json:"is_public"`
+	// Handle is the nickname folded with mention.Handle that mentions are matched against. It is
+	// unique: a number is appended when another user already has it.
+	Handle   *string `db:"handle" json:"-"`
+	Bio      string  `db:"bio" json:"bio"`
+	IsPublic bool    `db:"is_public" json:"is_public"`
+	// RequireAltText makes alt text mandatory on the images the user posts.
+	RequireAltText bool `db:"require_alt_text" json:"require_alt_text"`
 }
 
 func (u *User) FullName() string {
@@ -28,10 +36,11 @@ func (u *User) FullName() string {
 }
 
 type UserSummary struct {
-	ID     int    `db:"user_id" json:"user_id"`
-	FName  string `db:"f_name" json:"f_name"`
-	LName  string `db:"l_name" json:"l_name"`
-	Avatar []byte `db:"avatar" json:"avatar"`
+	ID            int     `db:"user_id" json:"user_id"`
+	FName         string  `db:"f_name" json:"f_name"`
+	LName         string  `db:"l_name" json:"l_name"`
+	AvatarID      *int    `db:"avatar_id" json:"avatar_id"`
+	AvatarAltText *string `db:"avatar_alt_text" json:"avatar_alt_text"`
 }
 
 func (u *UserSummary) FullName() string {
@@ -54,35 +63,51 @@ type FollowRequest struct {
 }
 
 type UserPatch struct {
-	Avatar   *[]byte `json:"avatar"`
+	AvatarID *int    `json:"avatar_id"`
 	Nickname *string `json:"nickname"`
 	Bio      *string `json:"bio"`
 }
 
 type PendingFollowRequest struct {
-	ID          int       `db:"id" json:"id"`
-	RequesterID int       `db:"requester_id" json:"requester_id"`
-	TargetID    int       `db:"target_id" json:"target_id"`
-	Status      string    `db:"status" json:"status"`
-	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+	ID                   int       `db:"id" json:"id"`
+	RequesterID          int       `db:"requester_id" json:"requester_id"`
+	TargetID             int       `db:"target_id" json:"target_id"`
+	Status               string    `db:"status" json:"status"`
+	CreatedAt            time.Time `db:"created_at" json:"created_at"`
+	MutualFollowersCount int       `db:"mutual_followers_count" json:"mutual_followers_count"`
+	SharedGroupsCount    int       `db:"shared_groups_count" json:"shared_groups_count"`
 
 	UserSummary
 }
 
+// FollowRequestFilter narrows down pending follow requests.
+type FollowRequestFilter struct {
+	// HasMutualFollowers keeps requesters that share at least one follower with the target.
+	HasMutualFollowers bool
+	// InSharedGroup keeps requesters that belong to (or own) a group the target belongs to.
+	InSharedGroup bool
+}
+
 // Checks if the context user and target user are the same.
 func (user *User) IsUserIDMatching(targetUserID int) bool {
 	return user.ID == targetUserID
 }
 
-func (db *DB) InsertUser(firstName, lastName, email, hashedPassword, nickname, bio string, dob time.Time, avatar []byte) (int, error) {
+func (db *DB) InsertUser(firstName, lastName, email, hashedPassword, nickname, bio string, dob time.Time) (int, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
 	defer cancel()
 
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
 	query := `
-    INSERT INTO user (f_name, l_name, email, hashed_password, dob, avatar, nickname, bio)
-    VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+    INSERT INTO user (f_name, l_name, email, hashed_password, dob, nickname, bio)
+    VALUES ($1, $2, $3, $4, $5, $6, $7)`
 
-	result, err := db.ExecContext(ctx, query, firstName, lastName, email, hashedPassword, dob, avatar, nickname, bio)
+	result, err := tx.ExecContext(ctx, query, firstName, lastName, email, hashedPassword, dob, nickname, bio)
 	if err != nil {
 		return 0, err
 	}
@@ -92,7 +117,11 @@ func (db *DB) InsertUser(firstName, lastName, email, hashedPassword, nickname, b
 		return 0, err
 	}
 
-	return int(id), err
+	if err := setHandle(ctx, tx, int(id), nickname); err != nil {
+		return 0, err
+	}
+
+	return int(id), tx.Commit()
 }
 
 func (db *DB) UserById(id int) (*User, bool, error) {
@@ -101,7 +130,7 @@ func (db *DB) UserById(id int) (*User, bool, error) {
 
 	var user User
 
-	query := `SELECT * FROM user WHERE id = $1`
+	query := `SELECT u.*, ` + altTextColumnSQL("u.avatar_id", "avatar_alt_text") + ` FROM user u WHERE u.id = $1`
 
 	err := db.GetContext(ctx, &user, query, id)
 	if errors.Is(err, sql.ErrNoRows) {
@@ -117,7 +146,7 @@ func (db *DB) UserByEmail(email string) (*User, bool, error) {
 
 	var user User
 
-	query := `SELECT * FROM user WHERE email = $1`
+	query := `SELECT u.*, ` + altTextColumnSQL("u.avatar_id", "avatar_alt_text") + ` FROM user u WHERE u.email = $1`
 
 	err := db.GetContext(ctx, &user, query, email)
 	if errors.Is(err, sql.ErrNoRows) {
@@ -133,7 +162,7 @@ func (db *DB) UserByUsername(username string) (*User, bool, error) {
 
 	var user User
 
-	query := `SELECT * FROM user WHERE nickname = $1`
+	query := `SELECT u.*, ` + altTextColumnSQL("u.avatar_id", "avatar_alt_text") + ` FROM user u WHERE u.nickname = $1`
 
 	err := db.GetContext(ctx, &user, query, username)
 	if errors.Is(err, sql.ErrNoRows) {
@@ -193,19 +222,42 @@ func (db *DB) UpdateNickname(userID int, nickname string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
 	defer cancel()
 
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
 	query := `UPDATE user SET nickname = $1 WHERE id = $2`
+	if _, err := tx.ExecContext(ctx, query, nickname, userID); err != nil {
+		return err
+	}
+
+	if err := setHandle(ctx, tx, userID, nickname); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
 
-	_, err := db.ExecContext(ctx, query, nickname, userID)
+func (db *DB) UpdateAvatar(userID int, avatarID *int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	query := `UPDATE user SET avatar_id = $1 WHERE id = $2`
+
+	_, err := db.ExecContext(ctx, query, avatarID, userID)
 	return err
 }
 
-func (db *DB) UpdateAvatar(userID int, avatar []byte) error {
+// UpdateRequireAltText turns the alt text requirement of a user on or off.
+func (db *DB) UpdateRequireAltText(userID int, required bool) error {
 	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
 	defer cancel()
 
-	query := `UPDATE user SET avatar = $1 WHERE id = $2`
+	query := `UPDATE user SET require_alt_text = $1 WHERE id = $2`
 
-	_, err := db.ExecContext(ctx, query, avatar, userID)
+	_, err := db.ExecContext(ctx, query, required, userID)
 	return err
 }
 
@@ -293,27 +345,135 @@ func (db *DB) UpdateFollowRequestStatus(id int, status string) error {
 	return err
 }
 
-// PendingFollowRequests returns incoming requests targeting the given user.
-func (db *DB) PendingFollowRequests(targetID int) ([]PendingFollowRequest, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
-	defer cancel()
-
-	query := `
+// pendingFollowRequestsQuery selects pending requests targeting $1 with requester details,
+// mutual follower counts and shared group counts. Filter conditions are appended by callers.
+var pendingFollowRequestsQuery = `
+	SELECT *
+	FROM (
 		SELECT fr.id, fr.requester_id, fr.target_id, fr.status, fr.created_at,
-		       u.id AS user_id, u.f_name, u.l_name, u.avatar
+		       u.id AS user_id, u.f_name, u.l_name, u.avatar_id, ` + altTextColumnSQL("u.avatar_id", "avatar_alt_text") + `,
+		       (
+				SELECT COUNT(*)
+				FROM follow_request a
+				JOIN follow_request b ON b.requester_id = a.requester_id
+				WHERE a.target_id = fr.target_id AND a.status = 'accepted'
+				  AND b.target_id = fr.requester_id AND b.status = 'accepted'
+		       ) AS mutual_followers_count,
+		       (
+				SELECT COUNT(*)
+				FROM (
+					SELECT group_id FROM group_members WHERE user_id = fr.target_id
+					UNION
+					SELECT id FROM groups WHERE owner_id = fr.target_id
+				) tg
+				WHERE tg.group_id IN (
+					SELECT group_id FROM group_members WHERE user_id = fr.requester_id
+					UNION
+					SELECT id FROM groups WHERE owner_id = fr.requester_id
+				)
+		       ) AS shared_groups_count
 		FROM follow_request fr
 		JOIN user u ON u.id = fr.requester_id
 		WHERE fr.target_id = $1 AND fr.status = 'pending'
-		ORDER BY fr.created_at ASC
-	`
+	) req
+	WHERE 1 = 1`
+
+func (f FollowRequestFilter) conditions() string {
+	var conditions string
+	if f.HasMutualFollowers {
+		conditions += " AND req.mutual_followers_count > 0"
+	}
+	if f.InSharedGroup {
+		conditions += " AND req.shared_groups_count > 0"
+	}
+	return conditions
+}
+
+// PendingFollowRequests returns incoming requests targeting the given user, oldest first.
+// When after is set, only requests positioned after that cursor are returned.
+func (db *DB) PendingFollowRequests(targetID int, filter FollowRequestFilter, after *Cursor, limit int) ([]PendingFollowRequest, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	if limit <= 0 {
+		limit = 25
+	}
+
+	// SQLite binds $N placeholders in order of first appearance, so they are numbered as they are appended.
+	args := []any{targetID}
+	query := pendingFollowRequestsQuery + filter.conditions()
+	if after != nil {
+		query += ` AND (datetime(req.created_at), req.id) > (datetime($2), $3)`
+		args = append(args, after.Timestamp(), after.ID)
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(` ORDER BY datetime(req.created_at) ASC, req.id ASC LIMIT $%d`, len(args))
 
 	var reqs []PendingFollowRequest
-	if err := db.SelectContext(ctx, &reqs, query, targetID); err != nil {
+	if err := db.SelectContext(ctx, &reqs, query, args...); err != nil {
 		return nil, err
 	}
 	return reqs, nil
 }
 
+// RespondFollowRequests sets the status of pending requests targeting targetID.
+// When all is false only the requests listed in ids are considered; the filter applies in both cases.
+// The requests that were actually updated are returned.
+func (db *DB) RespondFollowRequests(targetID int, ids []int, all bool, filter FollowRequestFilter, status string) ([]FollowRequest, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	defer cancel()
+
+	idList, err := json.Marshal(ids)
+	if err != nil {
+		return nil, err
+	}
+
+	query := pendingFollowRequestsQuery + filter.conditions()
+	args := []any{targetID}
+	if !all {
+		query += ` AND req.id IN (SELECT value FROM json_each($2))`
+		args = append(args, string(idList))
+	}
+
+	var matched []PendingFollowRequest
+	if err := db.SelectContext(ctx, &matched, query, args...); err != nil {
+		return nil, err
+	}
+	if len(matched) == 0 {
+		return []FollowRequest{}, nil
+	}
+
+	matchedIDs := make([]int, len(matched))
+	for i, req := range matched {
+		matchedIDs[i] = req.ID
+	}
+	matchedList, err := json.Marshal(matchedIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	update := `
+		UPDATE follow_request SET status = $1
+		WHERE target_id = $2 AND status = 'pending'
+		  AND id IN (SELECT value FROM json_each($3))
+	`
+	if _, err := db.ExecContext(ctx, update, status, targetID, string(matchedList)); err != nil {
+		return nil, err
+	}
+
+	updated := make([]FollowRequest, len(matched))
+	for i, req := range matched {
+		updated[i] = FollowRequest{
+			ID:          req.ID,
+			RequesterID: req.RequesterID,
+			TargetID:    req.TargetID,
+			Status:      status,
+			CreatedAt:   req.CreatedAt,
+		}
+	}
+	return updated, nil
+}
+
 // DeleteFollow removes an accepted follow relationship.
 func (db *DB) DeleteFollow(requesterID, targetID int) error {
 	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
@@ -354,7 +514,8 @@ func (db *DB) UserList(currentUserID int) ([]UserWithLastMessageTime, error) {
 			u.id AS user_id,
 			u.f_name,
 			u.l_name,
-			u.avatar,
+			u.avatar_id,
+			` + altTextColumnSQL("u.avatar_id", "avatar_alt_text") + `,
 			(
 				SELECT datetime(MAX(cm.created_at))
 				FROM conversation conv
@@ -465,7 +626,7 @@ func (db *DB) FollowersByUserID(userID int) ([]UserSummary, error) {
 
 	var followers []UserSummary
 	query := `
-		SELECT u.id as user_id, u.f_name, u.l_name, u.avatar
+		SELECT u.id as user_id, u.f_name, u.l_name, u.avatar_id, ` + altTextColumnSQL("u.avatar_id", "avatar_alt_text") + `
 		FROM user u
 		JOIN follow_request fr ON u.id = fr.requester_id
 		WHERE fr.target_id = $1 AND fr.status = 'accepted'`
@@ -484,7 +645,7 @@ func (db *DB) FollowingByUserID(userID int) ([]UserSummary, error) {
 
 	var following []UserSummary
 	query := `
-		SELECT u.id as user_id, u.f_name, u.l_name, u.avatar
+		SELECT u.id as user_id, u.f_name, u.l_name, u.avatar_id, ` + altTextColumnSQL("u.avatar_id", "avatar_alt_text") + `
 		FROM user u
 		JOIN follow_request fr ON u.id = fr.target_id
 		WHERE fr.requester_id = $1 AND fr.status = 'accepted'`
@@ -497,36 +658,42 @@ func (db *DB) FollowingByUserID(userID int) ([]UserSummary, error) {
 	return following, nil
 }
 
-// CanUsersMessage enforces the rule that at least one user must follow the other
-// or the receiver must have a public profile before direct messages are allowed.
-func (db *DB) CanUsersMessage(senderID, receiverID int) (bool, error) {
-	_, found, err := db.UserById(receiverID)
-	if err != nil {
-		return false, err
-	}
-	if !found {
-		return false, nil
+// Relation loads the follow facts between a viewer and the owner of a resource.
+// A guest viewer (ID 0) has no relation to anybody.
+func (db *DB) Relation(viewerID, ownerID int) (policy.Relation, error) {
+	var rel policy.Relation
+	if viewerID <= 0 || viewerID == ownerID {
+		return rel, nil
 	}
 
-	followsForward, err := db.IsFollowing(senderID, receiverID)
+	follows, err := db.IsFollowing(viewerID, ownerID)
 	if err != nil {
-		return false, err
-	}
-	if followsForward {
-		return true, nil
+		return rel, err
 	}
 
-	followsReverse, err := db.IsFollowing(receiverID, senderID)
+	followedBy, err := db.IsFollowing(ownerID, viewerID)
 	if err != nil {
-		return false, err
+		return rel, err
 	}
 
-	return followsReverse, nil
+	rel.Follows = follows
+	rel.FollowedBy = followedBy
+	return rel, nil
+}
+
+// CanUsersMessage enforces the rule that at least one user must follow the other
+// before direct messages are allowed.
+func (db *DB) CanUsersMessage(senderID, receiverID int) (bool, error) {
+	return db.canReachUser(senderID, receiverID, policy.MessageUser)
 }
 
 // CanDeliverMessage allows real-time delivery when the sender follows the receiver
 // or the receiver has a public profile.
 func (db *DB) CanDeliverMessage(senderID, receiverID int) (bool, error) {
+	return db.canReachUser(senderID, receiverID, policy.DeliverMessage)
+}
+
+func (db *DB) canReachUser(senderID, receiverID int, action policy.Action) (bool, error) {
 	receiver, found, err := db.UserById(receiverID)
 	if err != nil {
 		return false, err
@@ -534,9 +701,12 @@ func (db *DB) CanDeliverMessage(senderID, receiverID int) (bool, error) {
 	if !found {
 		return false, nil
 	}
-	if receiver.IsPublic {
-		return true, nil
+
+	rel, err := db.Relation(senderID, receiverID)
+	if err != nil {
+		return false, err
 	}
 
-	return db.IsFollowing(senderID, receiverID)
+	resource := policy.Resource{Kind: policy.KindUser, OwnerID: receiver.ID, OwnerIsPublic: receiver.IsPublic}
+	return policy.Allowed(policy.Viewer{ID: senderID}, action, resource, rel), nil
 }
@@ -0,0 +1,57 @@
+package hashtag
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// MaxLength is the longest tag name (in runes) that will be indexed.
+const MaxLength = 64
+
+// rgxHashtag matches a '#' that starts a word, followed by letters, digits or underscores.
+var rgxHashtag = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_&#])#([\p{L}\p{N}_]+)`)
+
+// Parse returns the distinct, normalized hashtags found in content, in order of first appearance.
+func Parse(content string) []string {
+	matches := rgxHashtag.FindAllStringSubmatch(content, -1)
+
+	seen := make(map[string]bool, len(matches))
+	tags := []string{}
+	for _, match := range matches {
+		tag, ok := Normalize(match[1])
+		if !ok || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+
+	return tags
+}
+
+// Normalize lowercases a tag and strips a leading '#'. It reports false when the
+// result is not a valid tag: empty, too long, made only of digits/underscores,
+// or containing characters other than letters, digits and underscores.
+func Normalize(tag string) (string, bool) {
+	tag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+	if tag == "" || len([]rune(tag)) > MaxLength {
+		return "", false
+	}
+
+	hasLetter := false
+	for _, r := range tag {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsDigit(r), r == '_':
+		default:
+			return "", false
+		}
+	}
+	if !hasLetter {
+		return "", false
+	}
+
+	return tag, true
+}
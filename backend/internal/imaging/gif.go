@@ -0,0 +1,212 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/gif"
+)
+
+// GIF block introducers read while walking the blocks of a file.
+const (
+	gifExtension = 0x21
+	gifImage     = 0x2C
+	gifTrailer   = 0x3B
+)
+
+// gifHeaderSize is the size of the signature and logical screen descriptor starting a GIF.
+const gifHeaderSize = 13
+
+// gifFrames walks the blocks of a GIF without decoding them and returns its number of frames and
+// their pixels added up, which is what decoding all the frames allocates.
+func gifFrames(data []byte) (frames, pixels int, err error) {
+	if len(data) < gifHeaderSize || (!bytes.HasPrefix(data, []byte("GIF87a")) && !bytes.HasPrefix(data, []byte("GIF89a"))) {
+		return 0, 0, fmt.Errorf("%w: missing GIF header", ErrInvalid)
+	}
+
+	pos := gifHeaderSize + colorTableSize(data[10])
+	for pos < len(data) {
+		switch data[pos] {
+		case gifTrailer:
+			return frames, pixels, nil
+		case gifExtension:
+			// Introducer and label, then data sub-blocks.
+			pos, err = skipSubBlocks(data, pos+2)
+		case gifImage:
+			// Introducer, position, size and flags, then the local color table, the LZW code
+			// size and data sub-blocks.
+			if pos+11 > len(data) {
+				return 0, 0, fmt.Errorf("%w: truncated GIF image descriptor", ErrInvalid)
+			}
+			width := int(binary.LittleEndian.Uint16(data[pos+5:]))
+			height := int(binary.LittleEndian.Uint16(data[pos+7:]))
+			frames++
+			pixels += width * height
+			pos, err = skipSubBlocks(data, pos+11+colorTableSize(data[pos+9]))
+		default:
+			return 0, 0, fmt.Errorf("%w: unknown GIF block 0x%02x", ErrInvalid, data[pos])
+		}
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return 0, 0, fmt.Errorf("%w: GIF without trailer", ErrInvalid)
+}
+
+// colorTableSize returns the size of the color table announced by the flags of a logical screen
+// or image descriptor.
+func colorTableSize(flags byte) int {
+	if flags&0x80 == 0 {
+		return 0
+	}
+	return 3 << (flags&0x07 + 1)
+}
+
+// skipSubBlocks returns the position after the data sub-blocks starting at pos, which end with
+// an empty one.
+func skipSubBlocks(data []byte, pos int) (int, error) {
+	for pos < len(data) {
+		size := int(data[pos])
+		pos += 1 + size
+		if size == 0 {
+			return pos, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: truncated GIF data", ErrInvalid)
+}
+
+// checkAnimation fails with ErrTooManyFrames for GIFs beyond the frame limits.
+func checkAnimation(data []byte, limits Limits) error {
+	frames, pixels, err := gifFrames(data)
+	if err != nil {
+		return err
+	}
+	if frames == 0 {
+		return fmt.Errorf("%w: GIF without frames", ErrInvalid)
+	}
+	if frames > limits.MaxFrames || pixels > limits.MaxAnimationPixels {
+		return fmt.Errorf("%w: %d frames of %d pixels", ErrTooManyFrames, frames, pixels)
+	}
+	return nil
+}
+
+// trimGIF re-encodes an animation with its first n frames only.
+func trimGIF(animation *gif.GIF, n int) ([]byte, error) {
+	trimmed := *animation
+	trimmed.Image = animation.Image[:n]
+	trimmed.Delay = animation.Delay[:n]
+	if len(animation.Disposal) > 0 {
+		trimmed.Disposal = animation.Disposal[:n]
+	}
+	return encodeGIF(&trimmed)
+}
+
+// encodeGIF encodes animation. Only the frames, their timing and disposal and the loop count
+// are written: extensions of the decoded file, such as comments and XMP, are not kept.
+func encodeGIF(animation *gif.GIF) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, animation); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// resizeAnimation scales every frame of animation to width × height, keeping the bounds part of
+// its logical screen. Frames may cover the screen in part and rely on the previous ones showing
+// through, so each is first composed on the screen the way viewers show it, following the
+// disposal methods. The copy is made of whole frames that each replace the previous one.
+func resizeAnimation(animation *gif.GIF, bounds image.Rectangle, width, height int) ([]byte, error) {
+	screen := image.NewRGBA(image.Rect(0, 0, animation.Config.Width, animation.Config.Height))
+	var previous *image.RGBA
+
+	resized := &gif.GIF{
+		Image:     make([]*image.Paletted, len(animation.Image)),
+		Delay:     animation.Delay,
+		Disposal:  make([]byte, len(animation.Image)),
+		LoopCount: animation.LoopCount,
+		Config:    image.Config{Width: width, Height: height},
+	}
+	for i, frame := range animation.Image {
+		var disposal byte
+		if i < len(animation.Disposal) {
+			disposal = animation.Disposal[i]
+		}
+		if disposal == gif.DisposalPrevious {
+			previous = image.NewRGBA(screen.Rect)
+			copy(previous.Pix, screen.Pix)
+		}
+
+		drawFrame(screen, frame)
+		resized.Image[i] = quantize(resample(screen.SubImage(bounds).(*image.RGBA), width, height), frame.Palette)
+		resized.Disposal[i] = gif.DisposalBackground
+
+		switch disposal {
+		case gif.DisposalBackground:
+			draw.Draw(screen, frame.Rect, image.Transparent, image.Point{}, draw.Src)
+		case gif.DisposalPrevious:
+			screen = previous
+		}
+	}
+
+	return encodeGIF(resized)
+}
+
+// drawFrame draws frame over screen. GIF colors are either opaque or transparent, so this copies
+// the pixels of frame except the transparent ones, without the per-pixel color conversions of
+// draw.Draw.
+func drawFrame(screen *image.RGBA, frame *image.Paletted) {
+	colors := make([]color.RGBA, len(frame.Palette))
+	for i, c := range frame.Palette {
+		colors[i] = color.RGBAModel.Convert(c).(color.RGBA)
+	}
+
+	r := frame.Rect.Intersect(screen.Rect)
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		src := frame.Pix[frame.PixOffset(r.Min.X, y):frame.PixOffset(r.Max.X, y)]
+		dst := screen.Pix[screen.PixOffset(r.Min.X, y):]
+		for x, index := range src {
+			if int(index) >= len(colors) || colors[index].A == 0 {
+				continue
+			}
+			c := colors[index]
+			dst[x*4], dst[x*4+1], dst[x*4+2], dst[x*4+3] = c.R, c.G, c.B, c.A
+		}
+	}
+}
+
+// quantize maps the pixels of src to the closest colors of palette, the palette of the frame src
+// was scaled from. Scaling blends edges with transparent areas, so a transparent color is added
+// to palettes that have none and room for one.
+func quantize(src *image.RGBA, palette color.Palette) *image.Paletted {
+	transparent := false
+	for _, c := range palette {
+		if _, _, _, a := c.RGBA(); a == 0 {
+			transparent = true
+			break
+		}
+	}
+	if !transparent && len(palette) < 256 {
+		palette = append(palette[:len(palette):len(palette)], color.Transparent)
+	}
+
+	dst := image.NewPaletted(src.Rect, palette)
+	// Scaled frames hold few distinct colors; looking each up once avoids searching the
+	// palette for every pixel.
+	indexes := map[color.RGBA]uint8{}
+	for y := src.Rect.Min.Y; y < src.Rect.Max.Y; y++ {
+		for x := src.Rect.Min.X; x < src.Rect.Max.X; x++ {
+			c := src.RGBAAt(x, y)
+			index, ok := indexes[c]
+			if !ok {
+				index = uint8(palette.Index(c))
+				indexes[c] = index
+			}
+			dst.SetColorIndex(x, y, index)
+		}
+	}
+	return dst
+}
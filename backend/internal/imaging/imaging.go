@@ -0,0 +1,327 @@
+// Package imaging prepares uploaded images for storage using only the standard library image
+// packages. Process checks the pixel dimensions an image declares before decoding any pixels,
+// so that a small file cannot expand into gigabytes of memory, and removes the metadata a camera
+// or editor embedded in it, such as EXIF GPS coordinates. Resize then derives smaller copies
+// for avatars and feeds.
+//
+// JPEG and PNG files are cleaned losslessly by dropping their metadata segments and chunks;
+// only JPEGs whose EXIF orientation rotates the picture are re-encoded, with the rotation
+// applied to the pixels. GIFs are re-encoded from their frames, decoded within the Limits, which
+// drops comment and application extensions such as XMP. Their copies are resized animations,
+// plus a static PNG of the first frame and a shorter animation.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+)
+
+// Formats, as reported by the image package.
+const (
+	FormatJPEG = "jpeg"
+	FormatPNG  = "png"
+	FormatGIF  = "gif"
+)
+
+// jpegQuality is used for re-encoded and resized JPEGs.
+const jpegQuality = 85
+
+var (
+	// ErrInvalid is returned for content that is not a JPEG, PNG or GIF, or that cannot be decoded.
+	ErrInvalid = errors.New("imaging: invalid image")
+	// ErrTooLarge is returned for images whose dimensions exceed the Limits.
+	ErrTooLarge = errors.New("imaging: image dimensions too large")
+	// ErrTooManyFrames is returned for GIFs whose frames exceed the Limits.
+	ErrTooManyFrames = errors.New("imaging: too many animation frames")
+)
+
+// Limits bound the dimensions of the images Process accepts.
+type Limits struct {
+	MaxWidth  int
+	MaxHeight int
+	// MaxPixels bounds width × height, which sets the memory needed to decode the image.
+	MaxPixels int
+	// MaxFrames bounds the frames of a GIF.
+	MaxFrames int
+	// MaxAnimationPixels bounds the pixels of all the frames of a GIF together, which sets the
+	// memory needed to decode it.
+	MaxAnimationPixels int
+}
+
+// DefaultLimits admit photos of current phone and DSLR cameras and typical animated GIFs.
+var DefaultLimits = Limits{
+	MaxWidth:           12_000,
+	MaxHeight:          12_000,
+	MaxPixels:          40_000_000,
+	MaxFrames:          1_000,
+	MaxAnimationPixels: 100_000_000,
+}
+
+// Image is an image ready to be stored.
+type Image struct {
+	// Format is one of FormatJPEG, FormatPNG or FormatGIF.
+	Format string
+	// Data is the content to store in place of the upload, without its metadata.
+	Data []byte
+	// Width and Height are the displayed dimensions, after EXIF orientation.
+	Width  int
+	Height int
+	// Frames is the number of frames of a GIF, 1 for other formats.
+	Frames int
+
+	// animation holds the decoded frames of a GIF.
+	animation *gif.GIF
+	// pixels holds the decoded, oriented image once Resize or orientation needed it. For GIFs it
+	// is the first frame.
+	pixels *image.RGBA
+}
+
+// MimeType returns the content type of the image.
+func (img *Image) MimeType() string {
+	return "image/" + img.Format
+}
+
+// Check reads the header of an image, and the block structure of a GIF, and fails with
+// ErrInvalid, ErrTooLarge or ErrTooManyFrames where Process would, without decoding any pixels.
+func Check(data []byte, limits Limits) error {
+	_, _, err := decodeConfig(data, limits)
+	return err
+}
+
+func decodeConfig(data []byte, limits Limits) (image.Config, string, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return config, "", fmt.Errorf("%w: %v", ErrInvalid, err)
+	}
+	if config.Width <= 0 || config.Height <= 0 {
+		return config, "", ErrInvalid
+	}
+	if config.Width > limits.MaxWidth || config.Height > limits.MaxHeight || config.Width*config.Height > limits.MaxPixels {
+		return config, "", fmt.Errorf("%w: %dx%d", ErrTooLarge, config.Width, config.Height)
+	}
+	if format == FormatGIF {
+		if err := checkAnimation(data, limits); err != nil {
+			return config, "", err
+		}
+	}
+	return config, format, nil
+}
+
+// Process checks an uploaded image against limits and strips its metadata.
+func Process(data []byte, limits Limits) (*Image, error) {
+	config, format, err := decodeConfig(data, limits)
+	if err != nil {
+		return nil, err
+	}
+
+	img := &Image{Format: format, Data: data, Width: config.Width, Height: config.Height, Frames: 1}
+
+	switch format {
+	case FormatJPEG:
+		orientation := jpegOrientation(data)
+		if orientation == 1 {
+			img.Data, err = stripJPEG(data)
+			if err != nil {
+				return nil, err
+			}
+			return img, nil
+		}
+
+		// Rotated pictures are re-encoded, which drops all metadata along with the orientation.
+		if err := img.decode(orientation); err != nil {
+			return nil, err
+		}
+		img.Width, img.Height = img.pixels.Rect.Dx(), img.pixels.Rect.Dy()
+		img.Data, err = img.encode(img.pixels)
+		if err != nil {
+			return nil, err
+		}
+	case FormatPNG:
+		img.Data, err = stripPNG(data)
+		if err != nil {
+			return nil, err
+		}
+	case FormatGIF:
+		// The frames are decoded now, within the limits checked above, so that corrupt GIFs fail
+		// before anything is stored. Encoding them again keeps the frames, their timing and the
+		// loop count only.
+		img.animation, err = gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+		img.Frames = len(img.animation.Image)
+		img.Data, err = encodeGIF(img.animation)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: unsupported format %s", ErrInvalid, format)
+	}
+
+	return img, nil
+}
+
+// Variant describes a resized copy of an image.
+type Variant struct {
+	Name   string
+	Width  int
+	Height int
+	// Crop fills the whole Width × Height box with the center of the image. Without it, the
+	// image is scaled to fit inside the box and keeps its aspect ratio.
+	Crop bool
+	// Static makes the copy of an animated GIF a PNG of its first frame, scaled to fit the
+	// box. Other images are static already and have no such copy.
+	Static bool
+	// MaxFrames makes the copy of a GIF with more frames an animation of its first MaxFrames
+	// frames, at full size. Width, Height and Crop are then unused.
+	MaxFrames int
+}
+
+// Resize returns the copy of the image for v and its dimensions, encoded in the format given
+// by VariantMimeType. Images are scaled down, never up, and GIFs keep all their frames. ok is
+// false when no copy is needed because the image already fits v, and for images other than
+// animated GIFs when v is Static or has MaxFrames.
+func (img *Image) Resize(v Variant) (data []byte, width, height int, ok bool, err error) {
+	if img.Format == FormatGIF && (v.Static || v.MaxFrames > 0) {
+		return img.resizeGIF(v)
+	}
+	if v.Static || v.MaxFrames > 0 {
+		return nil, 0, 0, false, nil
+	}
+
+	bounds, width, height := img.box(v)
+	if bounds.Dx() == img.Width && bounds.Dy() == img.Height && width == img.Width && height == img.Height {
+		return nil, 0, 0, false, nil
+	}
+
+	if img.animation != nil {
+		data, err = resizeAnimation(img.animation, bounds, width, height)
+		if err != nil {
+			return nil, 0, 0, false, err
+		}
+		return data, width, height, true, nil
+	}
+
+	if err := img.decode(1); err != nil {
+		return nil, 0, 0, false, err
+	}
+	src := img.pixels.SubImage(bounds.Add(img.pixels.Rect.Min)).(*image.RGBA)
+
+	data, err = img.encode(resample(src, width, height))
+	if err != nil {
+		return nil, 0, 0, false, err
+	}
+	return data, width, height, true, nil
+}
+
+// box returns the part of the image kept for v and the dimensions it is scaled to.
+func (img *Image) box(v Variant) (bounds image.Rectangle, width, height int) {
+	if v.Crop {
+		side := min(img.Width, img.Height)
+		bounds = image.Rect((img.Width-side)/2, (img.Height-side)/2, (img.Width+side)/2, (img.Height+side)/2)
+		return bounds, min(side, v.Width), min(side, v.Height)
+	}
+	width, height = fit(img.Width, img.Height, v.Width, v.Height)
+	return image.Rect(0, 0, img.Width, img.Height), width, height
+}
+
+// resizeGIF is Resize for the Static and MaxFrames variants of GIFs.
+func (img *Image) resizeGIF(v Variant) (data []byte, width, height int, ok bool, err error) {
+	switch {
+	case v.MaxFrames > 0:
+		if img.Frames <= v.MaxFrames {
+			return nil, 0, 0, false, nil
+		}
+		data, err = trimGIF(img.animation, v.MaxFrames)
+		if err != nil {
+			return nil, 0, 0, false, err
+		}
+		return data, img.Width, img.Height, true, nil
+	case v.Static:
+		if img.Frames == 1 {
+			return nil, 0, 0, false, nil
+		}
+		if err := img.decode(1); err != nil {
+			return nil, 0, 0, false, err
+		}
+		width, height = fit(img.Width, img.Height, v.Width, v.Height)
+		data, err = img.encode(resample(img.pixels, width, height))
+		if err != nil {
+			return nil, 0, 0, false, err
+		}
+		return data, width, height, true, nil
+	}
+	return nil, 0, 0, false, nil
+}
+
+// VariantMimeType returns the content type of the copy Resize makes for v.
+func (img *Image) VariantMimeType(v Variant) string {
+	if img.Format == FormatGIF && v.Static {
+		return "image/" + FormatPNG
+	}
+	return img.MimeType()
+}
+
+// decode fills img.pixels from img.Data, applying orientation. The first frame of a GIF is
+// placed on its logical screen, which it may cover only in part.
+func (img *Image) decode(orientation int) error {
+	if img.pixels != nil {
+		return nil
+	}
+
+	var decoded image.Image
+	if img.animation != nil {
+		decoded = img.animation.Image[0]
+	} else {
+		var err error
+		decoded, _, err = image.Decode(bytes.NewReader(img.Data))
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalid, err)
+		}
+	}
+
+	bounds := decoded.Bounds()
+	canvas := image.Rect(0, 0, bounds.Dx(), bounds.Dy())
+	at := canvas
+	if img.animation != nil {
+		canvas, at = image.Rect(0, 0, img.Width, img.Height), bounds
+	}
+	pixels := image.NewRGBA(canvas)
+	draw.Draw(pixels, at, decoded, bounds.Min, draw.Src)
+
+	img.pixels = orient(pixels, orientation)
+	return nil
+}
+
+// encode writes pixels in the format of img, or as a PNG for GIFs.
+func (img *Image) encode(pixels image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	var err error
+	if img.Format == FormatJPEG {
+		err = jpeg.Encode(&buf, pixels, &jpeg.Options{Quality: jpegQuality})
+	} else {
+		err = png.Encode(&buf, pixels)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// fit returns the largest dimensions of the w × h aspect ratio within maxW × maxH, never
+// larger than w × h.
+func fit(w, h, maxW, maxH int) (int, int) {
+	if w <= maxW && h <= maxH {
+		return w, h
+	}
+	if w*maxH > h*maxW {
+		return maxW, max(1, h*maxW/w)
+	}
+	return max(1, w*maxH/h), maxH
+}
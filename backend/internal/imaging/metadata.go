@@ -0,0 +1,196 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// JPEG markers read while walking the segments of a file.
+const (
+	markerSOI   = 0xD8
+	markerSOS   = 0xDA
+	markerAPP0  = 0xE0
+	markerAPP1  = 0xE1
+	markerAPP2  = 0xE2
+	markerAPP14 = 0xEE
+	markerAPP15 = 0xEF
+	markerCOM   = 0xFE
+)
+
+// exifOrientationTag is the EXIF tag of the orientation in the first image file directory.
+const exifOrientationTag = 0x0112
+
+// jpegSegment is a marker segment before the scan data of a JPEG.
+type jpegSegment struct {
+	marker byte
+	// data is the whole segment, from its 0xFF marker byte to the end of its payload.
+	data []byte
+}
+
+// payload returns the segment content after its marker and length.
+func (s jpegSegment) payload() []byte {
+	return s.data[4:]
+}
+
+// jpegSegments splits a JPEG into the marker segments that precede the first scan and the rest
+// of the file, which starts at the start-of-scan marker.
+func jpegSegments(data []byte) ([]jpegSegment, []byte, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != markerSOI {
+		return nil, nil, fmt.Errorf("%w: missing JPEG start of image", ErrInvalid)
+	}
+
+	var segments []jpegSegment
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return nil, nil, fmt.Errorf("%w: malformed JPEG marker", ErrInvalid)
+		}
+		marker := data[pos+1]
+		if marker == 0xFF {
+			// Fill byte before a marker.
+			pos++
+			continue
+		}
+		if marker == markerSOS {
+			return segments, data[pos:], nil
+		}
+
+		end := pos + 2 + int(binary.BigEndian.Uint16(data[pos+2:]))
+		if end > len(data) || end < pos+4 {
+			return nil, nil, fmt.Errorf("%w: truncated JPEG segment", ErrInvalid)
+		}
+		segments = append(segments, jpegSegment{marker: marker, data: data[pos:end]})
+		pos = end
+	}
+
+	return nil, nil, fmt.Errorf("%w: JPEG without image data", ErrInvalid)
+}
+
+// stripJPEG removes the comment and application segments of a JPEG, which hold EXIF, XMP, IPTC
+// and maker data, without re-encoding it. The JFIF header, ICC color profile and Adobe color
+// transform segments are kept since they change how the pixels are displayed.
+func stripJPEG(data []byte) ([]byte, error) {
+	segments, scan, err := jpegSegments(data)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, 0xFF, markerSOI)
+	for _, s := range segments {
+		if isJPEGMetadata(s) {
+			continue
+		}
+		out = append(out, s.data...)
+	}
+	return append(out, scan...), nil
+}
+
+func isJPEGMetadata(s jpegSegment) bool {
+	switch {
+	case s.marker == markerCOM:
+		return true
+	case s.marker == markerAPP0, s.marker == markerAPP14:
+		return false
+	case s.marker == markerAPP2:
+		return !bytes.HasPrefix(s.payload(), []byte("ICC_PROFILE\x00"))
+	default:
+		return s.marker >= markerAPP1 && s.marker <= markerAPP15
+	}
+}
+
+// jpegOrientation returns the EXIF orientation of a JPEG, from 1 to 8, or 1 when it has none.
+func jpegOrientation(data []byte) int {
+	segments, _, err := jpegSegments(data)
+	if err != nil {
+		return 1
+	}
+
+	for _, s := range segments {
+		if s.marker == markerAPP1 && bytes.HasPrefix(s.payload(), []byte("Exif\x00\x00")) {
+			return exifOrientation(s.payload()[6:])
+		}
+	}
+	return 1
+}
+
+// exifOrientation reads the orientation tag from the TIFF structure of an EXIF segment.
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for i := range entries {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 1
+		}
+		if order.Uint16(tiff[entry:]) != exifOrientationTag {
+			continue
+		}
+		// The value is a SHORT stored in the first bytes of the value field.
+		orientation := int(order.Uint16(tiff[entry+8:]))
+		if orientation < 1 || orientation > 8 {
+			return 1
+		}
+		return orientation
+	}
+	return 1
+}
+
+// pngSignature starts every PNG file.
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngMetadataChunks are the ancillary chunks holding text, EXIF and timestamps.
+var pngMetadataChunks = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"tIME": true,
+}
+
+// stripPNG removes the metadata chunks of a PNG. Chunks are copied with their checksums, so the
+// image data is untouched.
+func stripPNG(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, fmt.Errorf("%w: missing PNG signature", ErrInvalid)
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, pngSignature...)
+	pos := len(pngSignature)
+	for pos+12 <= len(data) {
+		// Length, type, data and CRC.
+		end := pos + 12 + int(binary.BigEndian.Uint32(data[pos:]))
+		if end > len(data) || end < pos+12 {
+			return nil, fmt.Errorf("%w: truncated PNG chunk", ErrInvalid)
+		}
+		chunkType := string(data[pos+4 : pos+8])
+		if !pngMetadataChunks[chunkType] {
+			out = append(out, data[pos:end]...)
+		}
+		pos = end
+		if chunkType == "IEND" {
+			return out, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: PNG without end chunk", ErrInvalid)
+}
@@ -0,0 +1,99 @@
+package imaging
+
+import "image"
+
+// resample scales src down to w × h. Each destination pixel is the average of the source pixels
+// it covers, computed one axis at a time. Averaging premultiplied RGBA keeps the colors of
+// transparent pixels from bleeding into their neighbours.
+func resample(src *image.RGBA, w, h int) *image.RGBA {
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+
+	// Horizontal pass: w × sh.
+	tmp := image.NewRGBA(image.Rect(0, 0, w, sh))
+	for x := range w {
+		x0, x1 := span(x, w, sw)
+		for y := range sh {
+			row := src.PixOffset(src.Rect.Min.X, src.Rect.Min.Y+y)
+			var sum [4]int
+			for sx := x0; sx < x1; sx++ {
+				p := src.Pix[row+sx*4 : row+sx*4+4]
+				sum[0], sum[1], sum[2], sum[3] = sum[0]+int(p[0]), sum[1]+int(p[1]), sum[2]+int(p[2]), sum[3]+int(p[3])
+			}
+			setAverage(tmp.Pix[tmp.PixOffset(x, y):], sum, x1-x0)
+		}
+	}
+
+	// Vertical pass: w × h.
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		y0, y1 := span(y, h, sh)
+		for x := range w {
+			var sum [4]int
+			for sy := y0; sy < y1; sy++ {
+				p := tmp.Pix[tmp.PixOffset(x, sy):]
+				sum[0], sum[1], sum[2], sum[3] = sum[0]+int(p[0]), sum[1]+int(p[1]), sum[2]+int(p[2]), sum[3]+int(p[3])
+			}
+			setAverage(dst.Pix[dst.PixOffset(x, y):], sum, y1-y0)
+		}
+	}
+
+	return dst
+}
+
+// span returns the source pixels [start, end) covered by destination pixel i of n, when n
+// pixels replace size source pixels.
+func span(i, n, size int) (int, int) {
+	start, end := i*size/n, (i+1)*size/n
+	if end <= start {
+		end = start + 1
+	}
+	return start, end
+}
+
+func setAverage(p []uint8, sum [4]int, count int) {
+	for c := range sum {
+		p[c] = uint8((sum[c] + count/2) / count)
+	}
+}
+
+// orient returns img turned upright according to an EXIF orientation: 2 to 4 mirror or rotate
+// it by 180°, 5 to 8 transpose it, swapping its width and height. 1 and unknown values return
+// img unchanged.
+func orient(img *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	w, h := img.Rect.Dx(), img.Rect.Dy()
+	// source maps a pixel of the upright image to the stored pixel it shows.
+	var source func(x, y int) (int, int)
+	dw, dh := w, h
+	switch orientation {
+	case 2:
+		source = func(x, y int) (int, int) { return w - 1 - x, y }
+	case 3:
+		source = func(x, y int) (int, int) { return w - 1 - x, h - 1 - y }
+	case 4:
+		source = func(x, y int) (int, int) { return x, h - 1 - y }
+	case 5:
+		source = func(x, y int) (int, int) { return y, x }
+	case 6:
+		source = func(x, y int) (int, int) { return y, h - 1 - x }
+	case 7:
+		source = func(x, y int) (int, int) { return w - 1 - y, h - 1 - x }
+	case 8:
+		source = func(x, y int) (int, int) { return w - 1 - y, x }
+	}
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := range dh {
+		for x := range dw {
+			sx, sy := source(x, y)
+			copy(dst.Pix[dst.PixOffset(x, y):dst.PixOffset(x, y)+4], img.Pix[img.PixOffset(sx, sy):])
+		}
+	}
+	return dst
+}
@@ -0,0 +1,505 @@
+// Package markdown renders the Markdown subset accepted in knowledge posts to sanitized HTML.
+//
+// Supported: paragraphs, ATX headings, emphasis (*em*, **strong**, ~~strike~~), inline code,
+// fenced code blocks, block quotes, ordered and unordered lists, horizontal rules, links with
+// http, https or mailto targets, and math delimited by $...$ (inline) or $$...$$ (display).
+// Raw HTML is never passed through: every piece of source text is escaped and only the tags
+// emitted by this package appear in the output. Math is left as escaped TeX for clients to typeset.
+package markdown
+
+import (
+	"html"
+	"net/url"
+	"strings"
+)
+
+const (
+	// maxQuoteDepth bounds block quote nesting.
+	maxQuoteDepth = 8
+	// maxInlineDepth bounds nested emphasis and links.
+	maxInlineDepth = 8
+	// maxLanguageLength bounds the language name of fenced code blocks.
+	maxLanguageLength = 32
+)
+
+// Render converts Markdown source to sanitized HTML.
+func Render(src string) string {
+	src = strings.ReplaceAll(src, "\r\n", "\n")
+	src = strings.ReplaceAll(src, "\r", "\n")
+
+	var b strings.Builder
+	renderBlocks(&b, strings.Split(src, "\n"), 0)
+	return b.String()
+}
+
+func renderBlocks(b *strings.Builder, lines []string, depth int) {
+	for i := 0; i < len(lines); {
+		trimmed := strings.TrimSpace(lines[i])
+
+		switch {
+		case trimmed == "":
+			i++
+
+		case strings.HasPrefix(trimmed, "```"):
+			i = renderFence(b, lines, i)
+
+		case trimmed == "$$":
+			i = renderDisplayMath(b, lines, i)
+
+		case len(trimmed) > 4 && strings.HasPrefix(trimmed, "$$") && strings.HasSuffix(trimmed, "$$"):
+			writeMath(b, "div", "math math-display", trimmed[2:len(trimmed)-2])
+			i++
+
+		case headingLevel(trimmed) > 0:
+			level := headingLevel(trimmed)
+			tag := "h" + string(rune('0'+level))
+			b.WriteString("<" + tag + ">")
+			renderInline(b, strings.TrimSpace(strings.TrimRight(trimmed[level:], "#")), false, 0)
+			b.WriteString("</" + tag + ">\n")
+			i++
+
+		case isRule(trimmed):
+			b.WriteString("<hr>\n")
+			i++
+
+		case strings.HasPrefix(trimmed, ">"):
+			var quoted []string
+			for ; i < len(lines) && strings.HasPrefix(strings.TrimSpace(lines[i]), ">"); i++ {
+				line := strings.TrimPrefix(strings.TrimSpace(lines[i]), ">")
+				quoted = append(quoted, strings.TrimPrefix(line, " "))
+			}
+			b.WriteString("<blockquote>\n")
+			if depth < maxQuoteDepth {
+				renderBlocks(b, quoted, depth+1)
+			} else {
+				renderParagraph(b, quoted)
+			}
+			b.WriteString("</blockquote>\n")
+
+		case listMarker(trimmed) != "":
+			i = renderList(b, lines, i)
+
+		default:
+			var paragraph []string
+			for ; i < len(lines); i++ {
+				t := strings.TrimSpace(lines[i])
+				if t == "" || (len(paragraph) > 0 && startsBlock(t)) {
+					break
+				}
+				paragraph = append(paragraph, t)
+			}
+			renderParagraph(b, paragraph)
+		}
+	}
+}
+
+func renderParagraph(b *strings.Builder, lines []string) {
+	b.WriteString("<p>")
+	renderInline(b, strings.Join(lines, "\n"), false, 0)
+	b.WriteString("</p>\n")
+}
+
+// startsBlock reports whether a trimmed line interrupts a paragraph.
+func startsBlock(trimmed string) bool {
+	return strings.HasPrefix(trimmed, "```") ||
+		strings.HasPrefix(trimmed, ">") ||
+		trimmed == "$$" ||
+		headingLevel(trimmed) > 0 ||
+		isRule(trimmed) ||
+		listMarker(trimmed) != ""
+}
+
+// renderFence writes the fenced code block opening at lines[start] and returns the index
+// of the line after it. An unterminated fence runs to the end of the text.
+func renderFence(b *strings.Builder, lines []string, start int) int {
+	language := codeLanguage(strings.TrimSpace(lines[start])[3:])
+
+	var code []string
+	i := start + 1
+	for ; i < len(lines); i++ {
+		if strings.HasPrefix(strings.TrimSpace(lines[i]), "```") {
+			i++
+			break
+		}
+		code = append(code, lines[i])
+	}
+
+	b.WriteString("<pre><code")
+	if language != "" {
+		b.WriteString(` class="language-` + language + `"`)
+	}
+	b.WriteString(">")
+	b.WriteString(html.EscapeString(strings.Join(code, "\n")))
+	b.WriteString("</code></pre>\n")
+
+	return i
+}
+
+// renderDisplayMath writes the $$ block opening at lines[start] and returns the index of the line after it.
+func renderDisplayMath(b *strings.Builder, lines []string, start int) int {
+	var math []string
+	i := start + 1
+	for ; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "$$" {
+			i++
+			break
+		}
+		math = append(math, lines[i])
+	}
+
+	writeMath(b, "div", "math math-display", strings.Join(math, "\n"))
+	b.WriteString("\n")
+	return i
+}
+
+func writeMath(b *strings.Builder, tag, class, tex string) {
+	b.WriteString("<" + tag + ` class="` + class + `">`)
+	b.WriteString(html.EscapeString(strings.TrimSpace(tex)))
+	b.WriteString("</" + tag + ">")
+}
+
+// renderList writes the list starting at lines[start] and returns the index of the line after it.
+// Indented lines continue the previous item.
+func renderList(b *strings.Builder, lines []string, start int) int {
+	ordered := listMarker(strings.TrimSpace(lines[start])) == "1."
+	tag := "ul"
+	if ordered {
+		tag = "ol"
+	}
+
+	var items [][]string
+	i := start
+items:
+	for ; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		marker := listMarker(trimmed)
+
+		switch {
+		case marker != "" && (marker == "1.") == ordered:
+			items = append(items, []string{strings.TrimSpace(trimmed[len(itemPrefix(trimmed)):])})
+		case trimmed != "" && len(items) > 0 && (line[0] == ' ' || line[0] == '\t') && marker == "":
+			last := len(items) - 1
+			items[last] = append(items[last], trimmed)
+		default:
+			break items
+		}
+	}
+
+	b.WriteString("<" + tag + ">\n")
+	for _, item := range items {
+		b.WriteString("<li>")
+		renderInline(b, strings.Join(item, "\n"), false, 0)
+		b.WriteString("</li>\n")
+	}
+	b.WriteString("</" + tag + ">\n")
+
+	return i
+}
+
+// listMarker returns "-" for unordered list items, "1." for ordered ones and "" otherwise.
+func listMarker(trimmed string) string {
+	prefix := itemPrefix(trimmed)
+	switch {
+	case prefix == "":
+		return ""
+	case prefix[0] >= '0' && prefix[0] <= '9':
+		return "1."
+	default:
+		return "-"
+	}
+}
+
+// itemPrefix returns the list marker of a trimmed line with its trailing space, or "".
+func itemPrefix(trimmed string) string {
+	if len(trimmed) >= 2 && strings.ContainsRune("-*+", rune(trimmed[0])) && trimmed[1] == ' ' {
+		return trimmed[:2]
+	}
+
+	digits := 0
+	for digits < len(trimmed) && digits < 9 && trimmed[digits] >= '0' && trimmed[digits] <= '9' {
+		digits++
+	}
+	if digits > 0 && len(trimmed) > digits+1 && (trimmed[digits] == '.' || trimmed[digits] == ')') && trimmed[digits+1] == ' ' {
+		return trimmed[:digits+2]
+	}
+
+	return ""
+}
+
+// headingLevel returns the level of an ATX heading ("# Title") or 0.
+func headingLevel(trimmed string) int {
+	level := 0
+	for level < len(trimmed) && trimmed[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || level >= len(trimmed) || trimmed[level] != ' ' {
+		return 0
+	}
+	return level
+}
+
+// isRule reports whether a trimmed line is a horizontal rule: three or more '-', '*' or '_'.
+func isRule(trimmed string) bool {
+	compact := strings.ReplaceAll(trimmed, " ", "")
+	if len(compact) < 3 {
+		return false
+	}
+	return strings.Count(compact, compact[:1]) == len(compact) && strings.ContainsRune("-*_", rune(compact[0]))
+}
+
+// codeLanguage keeps the language name of a fence info string when it is a plain identifier.
+func codeLanguage(info string) string {
+	fields := strings.Fields(info)
+	if len(fields) == 0 || len(fields[0]) > maxLanguageLength {
+		return ""
+	}
+	for _, r := range fields[0] {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("_+#.-", r)) {
+			return ""
+		}
+	}
+	return strings.ToLower(fields[0])
+}
+
+// renderInline writes a span of text with its inline markup. Links are not rendered inside links.
+func renderInline(b *strings.Builder, s string, inLink bool, depth int) {
+	plainStart := 0
+	flush := func(end int) {
+		b.WriteString(html.EscapeString(s[plainStart:end]))
+	}
+
+	for i := 0; i < len(s); {
+		next, ok := renderInlineAt(b, s, i, inLink, depth, flush)
+		if ok {
+			i = next
+			plainStart = next
+			continue
+		}
+		i++
+	}
+	flush(len(s))
+}
+
+// renderInlineAt renders the markup starting at s[i], if any. It calls flush with i first to
+// write the pending plain text, and returns the index after the markup.
+func renderInlineAt(b *strings.Builder, s string, i int, inLink bool, depth int, flush func(int)) (int, bool) {
+	switch s[i] {
+	case '\\':
+		if i+1 < len(s) && isASCIIPunct(s[i+1]) {
+			flush(i)
+			b.WriteString(html.EscapeString(s[i+1 : i+2]))
+			return i + 2, true
+		}
+
+	case '`':
+		n := runLength(s, i, '`')
+		closing := strings.Index(s[i+n:], strings.Repeat("`", n))
+		if closing >= 0 {
+			flush(i)
+			code := s[i+n : i+n+closing]
+			if len(code) > 1 && code[0] == ' ' && code[len(code)-1] == ' ' {
+				code = code[1 : len(code)-1]
+			}
+			b.WriteString("<code>" + html.EscapeString(code) + "</code>")
+			return i + n + closing + n, true
+		}
+
+	case '$':
+		if end, tex, display, ok := inlineMath(s, i); ok {
+			flush(i)
+			if display {
+				writeMath(b, "span", "math math-display", tex)
+			} else {
+				writeMath(b, "span", "math math-inline", tex)
+			}
+			return end, true
+		}
+
+	case '[':
+		if inLink || depth >= maxInlineDepth {
+			break
+		}
+		if end, text, href, ok := parseLink(s, i); ok {
+			flush(i)
+			if safe, ok := safeURL(href); ok {
+				b.WriteString(`<a href="` + html.EscapeString(safe) + `" rel="nofollow noopener noreferrer">`)
+				renderInline(b, text, true, depth+1)
+				b.WriteString("</a>")
+			} else {
+				renderInline(b, text, true, depth+1)
+			}
+			return end, true
+		}
+
+	case '*', '_', '~':
+		if depth >= maxInlineDepth {
+			break
+		}
+		if end, inner, tag, ok := emphasis(s, i); ok {
+			flush(i)
+			b.WriteString("<" + tag + ">")
+			renderInline(b, inner, inLink, depth+1)
+			b.WriteString("</" + tag + ">")
+			return end, true
+		}
+	}
+
+	return i, false
+}
+
+// emphasis parses *em*, _em_, **strong**, __strong__ or ~~strike~~ at s[i].
+// Underscores only count at word boundaries so that snake_case identifiers stay intact.
+func emphasis(s string, i int) (end int, inner, tag string, ok bool) {
+	c := s[i]
+	n := runLength(s, i, c)
+	switch {
+	case c == '~' && n == 2:
+		tag = "del"
+	case c != '~' && n == 2:
+		tag = "strong"
+	case c != '~' && n == 1:
+		tag = "em"
+	default:
+		return 0, "", "", false
+	}
+
+	if c == '_' && i > 0 && isWordByte(s[i-1]) {
+		return 0, "", "", false
+	}
+
+	delimiter := s[i : i+n]
+	start := i + n
+	if start >= len(s) || s[start] == ' ' || s[start] == '\n' {
+		return 0, "", "", false
+	}
+
+	for from := start; from < len(s); {
+		closing := strings.Index(s[from:], delimiter)
+		if closing < 0 {
+			return 0, "", "", false
+		}
+		closing += from
+		after := closing + n
+
+		valid := closing > start &&
+			s[closing-1] != ' ' && s[closing-1] != '\n' &&
+			runLength(s, closing, c) == n &&
+			!(c == '_' && after < len(s) && isWordByte(s[after]))
+		if valid {
+			return after, s[start:closing], tag, true
+		}
+		from = closing + runLength(s, closing, c)
+	}
+
+	return 0, "", "", false
+}
+
+// inlineMath parses $tex$ or $$tex$$ at s[i]. Like pandoc, inline math must not start or end
+// with a space and its closing '$' must not be followed by a digit, so prices stay text.
+func inlineMath(s string, i int) (end int, tex string, display, ok bool) {
+	if strings.HasPrefix(s[i:], "$$") {
+		closing := strings.Index(s[i+2:], "$$")
+		if closing <= 0 {
+			return 0, "", false, false
+		}
+		return i + 2 + closing + 2, s[i+2 : i+2+closing], true, true
+	}
+
+	start := i + 1
+	if start >= len(s) || s[start] == ' ' || s[start] == '\n' {
+		return 0, "", false, false
+	}
+
+	for j := start; j < len(s); j++ {
+		switch s[j] {
+		case '\\':
+			j++
+		case '\n':
+			return 0, "", false, false
+		case '$':
+			if s[j-1] == ' ' || (j+1 < len(s) && s[j+1] >= '0' && s[j+1] <= '9') {
+				return 0, "", false, false
+			}
+			return j + 1, s[start:j], false, true
+		}
+	}
+
+	return 0, "", false, false
+}
+
+// parseLink parses [text](target) at s[i].
+func parseLink(s string, i int) (end int, text, target string, ok bool) {
+	closeText := strings.IndexByte(s[i+1:], ']')
+	if closeText < 0 {
+		return 0, "", "", false
+	}
+	closeText += i + 1
+	if closeText+1 >= len(s) || s[closeText+1] != '(' {
+		return 0, "", "", false
+	}
+
+	// Balanced parentheses are allowed in the target, as in Wikipedia links
+	closeTarget, open := -1, 0
+	for j := closeText + 2; j < len(s) && closeTarget < 0; j++ {
+		switch s[j] {
+		case '(':
+			open++
+		case ')':
+			if open == 0 {
+				closeTarget = j
+			}
+			open--
+		}
+	}
+	if closeTarget < 0 {
+		return 0, "", "", false
+	}
+
+	text = s[i+1 : closeText]
+	target = strings.TrimSpace(s[closeText+2 : closeTarget])
+	if text == "" || target == "" || strings.ContainsAny(target, " \n") {
+		return 0, "", "", false
+	}
+
+	return closeTarget + 1, text, target, true
+}
+
+// safeURL returns the normalized link target when it is an absolute http, https or mailto URL.
+func safeURL(raw string) (string, bool) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", false
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		if u.Host == "" {
+			return "", false
+		}
+	case "mailto":
+		if u.Opaque == "" {
+			return "", false
+		}
+	default:
+		return "", false
+	}
+
+	return u.String(), true
+}
+
+func runLength(s string, i int, c byte) int {
+	n := 0
+	for i+n < len(s) && s[i+n] == c {
+		n++
+	}
+	return n
+}
+
+func isWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c >= 0x80
+}
+
+func isASCIIPunct(c byte) bool {
+	return strings.IndexByte("!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~", c) >= 0
+}
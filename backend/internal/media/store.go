@@ -0,0 +1,220 @@
+// Package media stores uploaded files by content. A file is addressed by the hex SHA-256 of its
+// bytes, so identical uploads share one copy; the database keeps one media row per upload with
+// its owner, type and key.
+package media
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrNotFound is returned when no file is stored under a key.
+var ErrNotFound = errors.New("media: file not found")
+
+// ErrRecentlyStored is returned by Delete for a file that Put stored within RecentPeriod.
+var ErrRecentlyStored = errors.New("media: file recently stored")
+
+// RecentPeriod is how long after Put a file is kept from Delete. An upload records its media row
+// only after Put returns, so the collector may find no references to a key that an upload of
+// the same bytes is about to reference.
+const RecentPeriod = time.Hour
+
+// Store keeps files addressed by the SHA-256 of their content.
+type Store interface {
+	// Put stores the content read from r and returns its key and size. Storing content that is
+	// already present only marks it as recently stored.
+	Put(r io.Reader) (key string, size int64, err error)
+	// Open returns the file stored under key, or ErrNotFound.
+	Open(key string) (io.ReadSeekCloser, error)
+	// Delete removes the file stored under key, or returns ErrRecentlyStored when Put stored
+	// it within RecentPeriod. Deleting a missing file is not an error.
+	Delete(key string) error
+	// Walk calls fn with the key of every stored file, in no particular order, and stops at
+	// the first error fn returns.
+	Walk(fn func(key string) error) error
+}
+
+// DiskStore is a Store backed by a directory. Files are spread over subdirectories named after
+// the first two characters of their key.
+type DiskStore struct {
+	root string
+	// mu makes the existence check of Put and the age check of Delete atomic with what
+	// follows them. The modification time of a file is when Put last stored it.
+	mu sync.Mutex
+}
+
+// NewDiskStore returns a store rooted at dir, creating it when missing.
+func NewDiskStore(dir string) (*DiskStore, error) {
+	if err := os.MkdirAll(filepath.Join(dir, "tmp"), 0o750); err != nil {
+		return nil, err
+	}
+	return &DiskStore{root: dir}, nil
+}
+
+// Put implements Store. Content is written to a temporary file first and renamed into place,
+// so a file under a key is always complete. Content already present is touched instead.
+func (s *DiskStore) Put(r io.Reader) (string, int64, error) {
+	tmp, err := os.CreateTemp(filepath.Join(s.root, "tmp"), "upload-*")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", 0, err
+	}
+
+	key := hex.EncodeToString(hash.Sum(nil))
+	path, _ := s.path(key)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := os.Stat(path); err == nil {
+		now := time.Now()
+		if err := os.Chtimes(path, now, now); err != nil {
+			return "", 0, err
+		}
+		return key, size, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return "", 0, err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", 0, err
+	}
+
+	return key, size, nil
+}
+
+// Open implements Store.
+func (s *DiskStore) Open(key string) (io.ReadSeekCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Delete implements Store.
+func (s *DiskStore) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if time.Since(info.ModTime()) < RecentPeriod {
+		return ErrRecentlyStored
+	}
+
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Walk implements Store. Temporary files of uploads in progress are skipped.
+func (s *DiskStore) Walk(fn func(key string) error) error {
+	dirs, err := os.ReadDir(s.root)
+	if err != nil {
+		return err
+	}
+
+	for _, dir := range dirs {
+		if !dir.IsDir() || len(dir.Name()) != 2 {
+			continue
+		}
+		files, err := os.ReadDir(filepath.Join(s.root, dir.Name()))
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			key := file.Name()
+			if _, err := s.path(key); err != nil || key[:2] != dir.Name() {
+				continue
+			}
+			if err := fn(key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// path returns where the file of key is stored. Keys are validated since they end up in paths.
+func (s *DiskStore) path(key string) (string, error) {
+	if len(key) != sha256.Size*2 {
+		return "", fmt.Errorf("media: invalid key %q", key)
+	}
+	if _, err := hex.DecodeString(key); err != nil {
+		return "", fmt.Errorf("media: invalid key %q", key)
+	}
+	return filepath.Join(s.root, key[:2], key), nil
+}
+
+// DetectType returns the MIME type of content from its first bytes.
+func DetectType(head []byte) string {
+	return http.DetectContentType(head)
+}
+
+// ErrTooLarge is returned by readers from LimitReader past their limit.
+var ErrTooLarge = errors.New("media: file too large")
+
+// LimitReader returns a reader of r that fails with ErrTooLarge once more than n bytes have
+// been read, so that a Store.Put of oversized content fails instead of storing a truncated file.
+func LimitReader(r io.Reader, n int64) io.Reader {
+	return &limitedReader{r: r, remaining: n}
+}
+
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, ErrTooLarge
+	}
+	// Read one byte past the limit to tell content of exactly n bytes from larger content.
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n, ErrTooLarge
+	}
+	return n, err
+}
@@ -0,0 +1,188 @@
+package media
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrNotText is returned by readers from TextReader for content that is not UTF-8 text.
+var ErrNotText = errors.New("media: content is not UTF-8 text")
+
+// Type is a kind of file users may upload. Files are recognized by their content, as sniffed by
+// http.DetectContentType, never by the name or type the client declares.
+type Type struct {
+	// Name identifies the type in configuration and errors.
+	Name string
+	// MimeType is the Content-Type files of this type are served with.
+	MimeType string
+	// MaxBytes is the size limit of a file.
+	MaxBytes int64
+	// Image types are decoded, resized and shown inline; other files are downloaded.
+	Image bool
+	// Text types must be valid UTF-8 throughout, which is checked while they are stored.
+	Text bool
+
+	// sniffed is the media type http.DetectContentType reports for the content.
+	sniffed string
+	// extensions, when set, are required of the file name to tell the type from others with the
+	// same content, like CSV from plain text.
+	extensions []string
+}
+
+// DefaultTypes are the types uploads accept unless configured otherwise. Types sniffed alike are
+// tried in order, so CSV goes before plain text.
+func DefaultTypes() []Type {
+	return []Type{
+		{Name: "jpeg", MimeType: "image/jpeg", MaxBytes: 10_000_000, Image: true, sniffed: "image/jpeg"},
+		{Name: "png", MimeType: "image/png", MaxBytes: 10_000_000, Image: true, sniffed: "image/png"},
+		{Name: "gif", MimeType: "image/gif", MaxBytes: 10_000_000, Image: true, sniffed: "image/gif"},
+		{Name: "pdf", MimeType: "application/pdf", MaxBytes: 20_000_000, sniffed: "application/pdf"},
+		{Name: "csv", MimeType: "text/csv; charset=utf-8", MaxBytes: 20_000_000, Text: true, sniffed: "text/plain", extensions: []string{".csv"}},
+		{Name: "text", MimeType: "text/plain; charset=utf-8", MaxBytes: 5_000_000, Text: true, sniffed: "text/plain"},
+		{Name: "zip", MimeType: "application/zip", MaxBytes: 50_000_000, sniffed: "application/zip"},
+	}
+}
+
+// Registry is the set of types uploads accept.
+type Registry struct {
+	types []Type
+}
+
+// NewRegistry returns a registry of types.
+func NewRegistry(types []Type) *Registry {
+	return &Registry{types: types}
+}
+
+// Configure narrows the registry to the comma-separated type names of allowed, when not empty,
+// and applies the size limits of limits, a comma-separated list of name=megabytes pairs such as
+// "pdf=30,zip=100".
+func (reg *Registry) Configure(allowed, limits string) error {
+	if names := splitList(allowed); len(names) > 0 {
+		for _, name := range names {
+			if _, ok := reg.byName(name); !ok {
+				return fmt.Errorf("media: unknown type %q", name)
+			}
+		}
+		reg.types = slices.DeleteFunc(reg.types, func(t Type) bool {
+			return !slices.Contains(names, t.Name)
+		})
+	}
+
+	for _, pair := range splitList(limits) {
+		name, value, found := strings.Cut(pair, "=")
+		megabytes, err := strconv.Atoi(strings.TrimSpace(value))
+		if !found || err != nil || megabytes <= 0 {
+			return fmt.Errorf("media: invalid size limit %q", pair)
+		}
+		i := slices.IndexFunc(reg.types, func(t Type) bool { return t.Name == strings.TrimSpace(name) })
+		if i < 0 {
+			return fmt.Errorf("media: size limit for unknown or disallowed type %q", name)
+		}
+		reg.types[i].MaxBytes = int64(megabytes) * 1_000_000
+	}
+
+	return nil
+}
+
+// Detect returns the type of a file from its first 512 bytes and its name. ok is false when the
+// content is of no type of the registry.
+func (reg *Registry) Detect(head []byte, fileName string) (Type, bool) {
+	sniffed, _, err := mime.ParseMediaType(http.DetectContentType(head))
+	if err != nil {
+		return Type{}, false
+	}
+	ext := strings.ToLower(filepath.Ext(fileName))
+
+	for _, t := range reg.types {
+		if t.sniffed != sniffed {
+			continue
+		}
+		if len(t.extensions) > 0 && !slices.Contains(t.extensions, ext) {
+			continue
+		}
+		return t, true
+	}
+	return Type{}, false
+}
+
+// MaxBytes returns the largest size limit of the registry.
+func (reg *Registry) MaxBytes() int64 {
+	var largest int64
+	for _, t := range reg.types {
+		largest = max(largest, t.MaxBytes)
+	}
+	return largest
+}
+
+// Names returns the names of the types of the registry.
+func (reg *Registry) Names() []string {
+	names := make([]string, len(reg.types))
+	for i, t := range reg.types {
+		names[i] = t.Name
+	}
+	return names
+}
+
+func (reg *Registry) byName(name string) (Type, bool) {
+	for _, t := range reg.types {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return Type{}, false
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// TextReader returns a reader of r that fails with ErrNotText as soon as the content read is not
+// valid UTF-8.
+func TextReader(r io.Reader) io.Reader {
+	return &textReader{r: r}
+}
+
+type textReader struct {
+	r io.Reader
+	// pending holds the bytes of a character split across reads.
+	pending []byte
+}
+
+func (t *textReader) Read(p []byte) (int, error) {
+	n, err := t.r.Read(p)
+
+	buf := append(t.pending, p[:n]...)
+	complete := len(buf)
+	// Hold back the start of a character that the next read completes.
+	for i := len(buf) - 1; i >= 0 && i >= len(buf)-utf8.UTFMax+1; i-- {
+		if utf8.RuneStart(buf[i]) {
+			if !utf8.FullRune(buf[i:]) {
+				complete = i
+			}
+			break
+		}
+	}
+	if !utf8.Valid(buf[:complete]) {
+		return n, ErrNotText
+	}
+	t.pending = append(t.pending[:0:0], buf[complete:]...)
+
+	if errors.Is(err, io.EOF) && len(t.pending) > 0 {
+		return n, ErrNotText
+	}
+	return n, err
+}
@@ -0,0 +1,65 @@
+package mention
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// NotificationType is the notification sent to mentioned users.
+const NotificationType = "mention"
+
+// MaxHandleLength is the longest handle (in runes) that is looked up; it matches the nickname limit.
+const MaxHandleLength = 50
+
+// rgxMention matches an '@' that starts a word, followed by letters, digits or underscores.
+var rgxMention = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_@])@([\p{L}\p{N}_]+)`)
+
+// Span is one @handle occurrence in a text. Start and End are rune offsets: Start is the
+// position of the '@' and End is exclusive.
+type Span struct {
+	Handle string
+	Start  int
+	End    int
+}
+
+// Handle returns the case-folded form of a nickname that mentions are matched against.
+// Folding happens in Go since SQLite's lower() only folds ASCII letters.
+func Handle(nickname string) string {
+	return strings.ToLower(nickname)
+}
+
+// Suffixed returns handle followed by n, which tells apart users whose nicknames fold to the
+// same handle. handle is shortened so that the result stays within MaxHandleLength.
+func Suffixed(handle string, n int) string {
+	suffix := strconv.Itoa(n)
+	runes := []rune(handle)
+	if keep := MaxHandleLength - len(suffix); len(runes) > keep {
+		runes = runes[:keep]
+	}
+	return string(runes) + suffix
+}
+
+// Parse returns every @handle occurrence in content, in order. Handles are folded with Handle
+// since nicknames are matched case-insensitively.
+func Parse(content string) []Span {
+	matches := rgxMention.FindAllStringSubmatchIndex(content, -1)
+
+	spans := []Span{}
+	for _, match := range matches {
+		handle := content[match[2]:match[3]]
+		if utf8.RuneCountInString(handle) > MaxHandleLength {
+			continue
+		}
+
+		start := utf8.RuneCountInString(content[:match[2]-1])
+		spans = append(spans, Span{
+			Handle: Handle(handle),
+			Start:  start,
+			End:    start + 1 + utf8.RuneCountInString(handle),
+		})
+	}
+
+	return spans
+}
@@ -0,0 +1,141 @@
+// Package policy holds the visibility and messaging rules of the social graph.
+//
+// Every decision is a function of a viewer, an action and a resource, plus the
+// relation facts linking the viewer to the resource owner. The same rules are
+// exposed as SQL fragments (see sql.go) so list queries filter rows exactly the
+// way single-item checks do.
+package policy
+
+// Post visibility values as stored in post.visibility.
+const (
+	VisibilityPublic    = "public"
+	VisibilityFollowers = "private" // accepted followers of the author
+	VisibilityLimited   = "limited" // explicit allow list (post_user_can_view)
+)
+
+// Article status values as stored in article.status.
+const (
+	ArticleDraft     = "draft"
+	ArticleScheduled = "scheduled" // published by the scheduler at publish_at
+	ArticlePublished = "published"
+)
+
+type Action string
+
+const (
+	// ViewPost covers reading a post and its comments.
+	ViewPost Action = "view_post"
+	// ViewArticle covers reading an article.
+	ViewArticle Action = "view_article"
+	// CommentOnPost covers writing a comment on a post.
+	CommentOnPost Action = "comment_on_post"
+	// ViewProfile covers the full profile: posts, followers and following lists.
+	ViewProfile Action = "view_profile"
+	// MessageUser covers starting or continuing a direct conversation.
+	MessageUser Action = "message_user"
+	// DeliverMessage covers pushing a direct message to the receiver in real time.
+	DeliverMessage Action = "deliver_message"
+	// ViewGroupContent covers posts, comments, events and chat of a group.
+	ViewGroupContent Action = "view_group_content"
+)
+
+type ResourceKind string
+
+const (
+	KindPost    ResourceKind = "post"
+	KindArticle ResourceKind = "article"
+	KindUser    ResourceKind = "user"
+	KindGroup   ResourceKind = "group"
+)
+
+// Viewer is the user asking for access. A zero ID is an unauthenticated guest.
+type Viewer struct {
+	ID int
+}
+
+func (v Viewer) IsGuest() bool {
+	return v.ID <= 0
+}
+
+// Resource describes what is being accessed.
+type Resource struct {
+	Kind ResourceKind
+	// OwnerID is the post or article author, the profile owner or the group owner.
+	OwnerID int
+	// Visibility is the post or article visibility; unused for other kinds.
+	Visibility string
+	// Unpublished is true for draft and scheduled articles, which only their author may view.
+	Unpublished bool
+	// OwnerIsPublic reports whether the owner's profile is public.
+	OwnerIsPublic bool
+	// Deleted is true for soft-deleted content; nobody may act on it.
+	Deleted bool
+}
+
+// Relation holds the facts linking the viewer to the resource owner.
+type Relation struct {
+	// Follows is true when the viewer has an accepted follow on the owner.
+	Follows bool
+	// FollowedBy is true when the owner has an accepted follow on the viewer.
+	FollowedBy bool
+	// AllowListed is true when the viewer is on the allow list of a limited post or article.
+	AllowListed bool
+	// GroupMember is true when the viewer belongs to (or owns) the group.
+	GroupMember bool
+}
+
+// Allowed reports whether viewer may perform action on resource.
+func Allowed(viewer Viewer, action Action, resource Resource, rel Relation) bool {
+	if resource.Deleted {
+		return false
+	}
+
+	isSelf := !viewer.IsGuest() && viewer.ID == resource.OwnerID
+
+	switch action {
+	case ViewPost:
+		return canViewPost(viewer, isSelf, resource, rel)
+
+	case ViewArticle:
+		if resource.Unpublished {
+			return isSelf
+		}
+		return canViewPost(viewer, isSelf, resource, rel)
+
+	case CommentOnPost:
+		return !viewer.IsGuest() && canViewPost(viewer, isSelf, resource, rel)
+
+	case ViewProfile:
+		return isSelf || resource.OwnerIsPublic || (!viewer.IsGuest() && rel.Follows)
+
+	case MessageUser:
+		// At least one of the two users must follow the other.
+		return !viewer.IsGuest() && !isSelf && (rel.Follows || rel.FollowedBy)
+
+	case DeliverMessage:
+		// Real-time delivery when the receiver is public or the sender follows them.
+		return !viewer.IsGuest() && !isSelf && (resource.OwnerIsPublic || rel.Follows)
+
+	case ViewGroupContent:
+		return !viewer.IsGuest() && (isSelf || rel.GroupMember)
+	}
+
+	return false
+}
+
+func canViewPost(viewer Viewer, isSelf bool, resource Resource, rel Relation) bool {
+	if isSelf {
+		return true
+	}
+
+	switch resource.Visibility {
+	case VisibilityPublic:
+		return true
+	case VisibilityFollowers:
+		return !viewer.IsGuest() && rel.Follows
+	case VisibilityLimited:
+		return !viewer.IsGuest() && rel.AllowListed
+	}
+
+	return false
+}
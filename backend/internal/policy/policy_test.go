@@ -0,0 +1,367 @@
+package policy_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"brainbook-api/internal/database"
+	"brainbook-api/internal/policy"
+)
+
+const (
+	ownerID  = 1
+	viewerID = 2
+	otherID  = 3
+)
+
+const (
+	profilePublic  = true
+	profilePrivate = false
+)
+
+type followState int
+
+const (
+	followNone followState = iota
+	followPending
+	followAccepted
+	followSelf
+)
+
+func (f followState) String() string {
+	return [...]string{"none", "pending", "accepted", "self"}[f]
+}
+
+type audience int
+
+const (
+	visPublic audience = iota
+	visPrivate
+	visLimitedIn  // limited, viewer on the allow list
+	visLimitedOut // limited, someone else on the allow list
+)
+
+func (a audience) String() string {
+	return [...]string{"public", "private", "limited-in", "limited-out"}[a]
+}
+
+func (a audience) visibility() string {
+	switch a {
+	case visPublic:
+		return policy.VisibilityPublic
+	case visPrivate:
+		return policy.VisibilityFollowers
+	}
+	return policy.VisibilityLimited
+}
+
+type want struct {
+	post    bool // policy.ViewPost, policy.CommentOnPost and policy.ViewArticle on a published article
+	profile bool // policy.ViewProfile
+	message bool // policy.MessageUser
+	deliver bool // policy.DeliverMessage
+	group   bool // policy.ViewGroupContent
+}
+
+type testCase struct {
+	public bool
+	follow followState
+	vis    audience
+	member bool
+	want   want
+}
+
+func (c testCase) String() string {
+	profile := "private"
+	if c.public {
+		profile = "public"
+	}
+	return fmt.Sprintf("profile=%s/follow=%s/visibility=%s/member=%t", profile, c.follow, c.vis, c.member)
+}
+
+func (c testCase) viewer() policy.Viewer {
+	if c.follow == followSelf {
+		return policy.Viewer{ID: ownerID}
+	}
+	return policy.Viewer{ID: viewerID}
+}
+
+func (c testCase) resource(kind policy.ResourceKind) policy.Resource {
+	return policy.Resource{Kind: kind, OwnerID: ownerID, Visibility: c.vis.visibility(), OwnerIsPublic: c.public}
+}
+
+func (c testCase) relation() policy.Relation {
+	return policy.Relation{
+		Follows:     c.follow == followAccepted,
+		AllowListed: c.vis == visLimitedIn,
+		GroupMember: c.member,
+	}
+}
+
+// testCases is every combination of owner profile, follow status of the viewer on the owner,
+// content visibility and group membership of the viewer.
+var testCases = []testCase{
+	{profilePublic, followNone, visPublic, false, want{post: true, profile: true, message: false, deliver: true, group: false}},
+	{profilePublic, followNone, visPublic, true, want{post: true, profile: true, message: false, deliver: true, group: true}},
+	{profilePublic, followNone, visPrivate, false, want{post: false, profile: true, message: false, deliver: true, group: false}},
+	{profilePublic, followNone, visPrivate, true, want{post: false, profile: true, message: false, deliver: true, group: true}},
+	{profilePublic, followNone, visLimitedIn, false, want{post: true, profile: true, message: false, deliver: true, group: false}},
+	{profilePublic, followNone, visLimitedIn, true, want{post: true, profile: true, message: false, deliver: true, group: true}},
+	{profilePublic, followNone, visLimitedOut, false, want{post: false, profile: true, message: false, deliver: true, group: false}},
+	{profilePublic, followNone, visLimitedOut, true, want{post: false, profile: true, message: false, deliver: true, group: true}},
+	{profilePublic, followPending, visPublic, false, want{post: true, profile: true, message: false, deliver: true, group: false}},
+	{profilePublic, followPending, visPublic, true, want{post: true, profile: true, message: false, deliver: true, group: true}},
+	{profilePublic, followPending, visPrivate, false, want{post: false, profile: true, message: false, deliver: true, group: false}},
+	{profilePublic, followPending, visPrivate, true, want{post: false, profile: true, message: false, deliver: true, group: true}},
+	{profilePublic, followPending, visLimitedIn, false, want{post: true, profile: true, message: false, deliver: true, group: false}},
+	{profilePublic, followPending, visLimitedIn, true, want{post: true, profile: true, message: false, deliver: true, group: true}},
+	{profilePublic, followPending, visLimitedOut, false, want{post: false, profile: true, message: false, deliver: true, group: false}},
+	{profilePublic, followPending, visLimitedOut, true, want{post: false, profile: true, message: false, deliver: true, group: true}},
+	{profilePublic, followAccepted, visPublic, false, want{post: true, profile: true, message: true, deliver: true, group: false}},
+	{profilePublic, followAccepted, visPublic, true, want{post: true, profile: true, message: true, deliver: true, group: true}},
+	{profilePublic, followAccepted, visPrivate, false, want{post: true, profile: true, message: true, deliver: true, group: false}},
+	{profilePublic, followAccepted, visPrivate, true, want{post: true, profile: true, message: true, deliver: true, group: true}},
+	{profilePublic, followAccepted, visLimitedIn, false, want{post: true, profile: true, message: true, deliver: true, group: false}},
+	{profilePublic, followAccepted, visLimitedIn, true, want{post: true, profile: true, message: true, deliver: true, group: true}},
+	{profilePublic, followAccepted, visLimitedOut, false, want{post: false, profile: true, message: true, deliver: true, group: false}},
+	{profilePublic, followAccepted, visLimitedOut, true, want{post: false, profile: true, message: true, deliver: true, group: true}},
+	{profilePublic, followSelf, visPublic, false, want{post: true, profile: true, message: false, deliver: false, group: true}},
+	{profilePublic, followSelf, visPublic, true, want{post: true, profile: true, message: false, deliver: false, group: true}},
+	{profilePublic, followSelf, visPrivate, false, want{post: true, profile: true, message: false, deliver: false, group: true}},
+	{profilePublic, followSelf, visPrivate, true, want{post: true, profile: true, message: false, deliver: false, group: true}},
+	{profilePublic, followSelf, visLimitedIn, false, want{post: true, profile: true, message: false, deliver: false, group: true}},
+	{profilePublic, followSelf, visLimitedIn, true, want{post: true, profile: true, message: false, deliver: false, group: true}},
+	{profilePublic, followSelf, visLimitedOut, false, want{post: true, profile: true, message: false, deliver: false, group: true}},
+	{profilePublic, followSelf, visLimitedOut, true, want{post: true, profile: true, message: false, deliver: false, group: true}},
+	{profilePrivate, followNone, visPublic, false, want{post: true, profile: false, message: false, deliver: false, group: false}},
+	{profilePrivate, followNone, visPublic, true, want{post: true, profile: false, message: false, deliver: false, group: true}},
+	{profilePrivate, followNone, visPrivate, false, want{post: false, profile: false, message: false, deliver: false, group: false}},
+	{profilePrivate, followNone, visPrivate, true, want{post: false, profile: false, message: false, deliver: false, group: true}},
+	{profilePrivate, followNone, visLimitedIn, false, want{post: true, profile: false, message: false, deliver: false, group: false}},
+	{profilePrivate, followNone, visLimitedIn, true, want{post: true, profile: false, message: false, deliver: false, group: true}},
+	{profilePrivate, followNone, visLimitedOut, false, want{post: false, profile: false, message: false, deliver: false, group: false}},
+	{profilePrivate, followNone, visLimitedOut, true, want{post: false, profile: false, message: false, deliver: false, group: true}},
+	{profilePrivate, followPending, visPublic, false, want{post: true, profile: false, message: false, deliver: false, group: false}},
+	{profilePrivate, followPending, visPublic, true, want{post: true, profile: false, message: false, deliver: false, group: true}},
+	{profilePrivate, followPending, visPrivate, false, want{post: false, profile: false, message: false, deliver: false, group: false}},
+	{profilePrivate, followPending, visPrivate, true, want{post: false, profile: false, message: false, deliver: false, group: true}},
+	{profilePrivate, followPending, visLimitedIn, false, want{post: true, profile: false, message: false, deliver: false, group: false}},
+	{profilePrivate, followPending, visLimitedIn, true, want{post: true, profile: false, message: false, deliver: false, group: true}},
+	{profilePrivate, followPending, visLimitedOut, false, want{post: false, profile: false, message: false, deliver: false, group: false}},
+	{profilePrivate, followPending, visLimitedOut, true, want{post: false, profile: false, message: false, deliver: false, group: true}},
+	{profilePrivate, followAccepted, visPublic, false, want{post: true, profile: true, message: true, deliver: true, group: false}},
+	{profilePrivate, followAccepted, visPublic, true, want{post: true, profile: true, message: true, deliver: true, group: true}},
+	{profilePrivate, followAccepted, visPrivate, false, want{post: true, profile: true, message: true, deliver: true, group: false}},
+	{profilePrivate, followAccepted, visPrivate, true, want{post: true, profile: true, message: true, deliver: true, group: true}},
+	{profilePrivate, followAccepted, visLimitedIn, false, want{post: true, profile: true, message: true, deliver: true, group: false}},
+	{profilePrivate, followAccepted, visLimitedIn, true, want{post: true, profile: true, message: true, deliver: true, group: true}},
+	{profilePrivate, followAccepted, visLimitedOut, false, want{post: false, profile: true, message: true, deliver: true, group: false}},
+	{profilePrivate, followAccepted, visLimitedOut, true, want{post: false, profile: true, message: true, deliver: true, group: true}},
+	{profilePrivate, followSelf, visPublic, false, want{post: true, profile: true, message: false, deliver: false, group: true}},
+	{profilePrivate, followSelf, visPublic, true, want{post: true, profile: true, message: false, deliver: false, group: true}},
+	{profilePrivate, followSelf, visPrivate, false, want{post: true, profile: true, message: false, deliver: false, group: true}},
+	{profilePrivate, followSelf, visPrivate, true, want{post: true, profile: true, message: false, deliver: false, group: true}},
+	{profilePrivate, followSelf, visLimitedIn, false, want{post: true, profile: true, message: false, deliver: false, group: true}},
+	{profilePrivate, followSelf, visLimitedIn, true, want{post: true, profile: true, message: false, deliver: false, group: true}},
+	{profilePrivate, followSelf, visLimitedOut, false, want{post: true, profile: true, message: false, deliver: false, group: true}},
+	{profilePrivate, followSelf, visLimitedOut, true, want{post: true, profile: true, message: false, deliver: false, group: true}},
+}
+
+func TestAllowed(t *testing.T) {
+	for _, tc := range testCases {
+		t.Run(tc.String(), func(t *testing.T) {
+			viewer, rel := tc.viewer(), tc.relation()
+			checks := []struct {
+				action   policy.Action
+				resource policy.Resource
+				want     bool
+			}{
+				{policy.ViewPost, tc.resource(policy.KindPost), tc.want.post},
+				{policy.CommentOnPost, tc.resource(policy.KindPost), tc.want.post},
+				{policy.ViewArticle, tc.resource(policy.KindArticle), tc.want.post},
+				{policy.ViewProfile, tc.resource(policy.KindUser), tc.want.profile},
+				{policy.MessageUser, tc.resource(policy.KindUser), tc.want.message},
+				{policy.DeliverMessage, tc.resource(policy.KindUser), tc.want.deliver},
+				{policy.ViewGroupContent, tc.resource(policy.KindGroup), tc.want.group},
+			}
+			for _, check := range checks {
+				if got := policy.Allowed(viewer, check.action, check.resource, rel); got != check.want {
+					t.Errorf("%s: got %t, want %t", check.action, got, check.want)
+				}
+			}
+		})
+	}
+}
+
+func TestAllowedGuest(t *testing.T) {
+	guest := policy.Viewer{}
+	for _, tc := range testCases {
+		if tc.follow != followNone || tc.vis == visLimitedIn {
+			continue
+		}
+		t.Run(tc.String(), func(t *testing.T) {
+			rel := policy.Relation{GroupMember: tc.member}
+			checks := []struct {
+				action   policy.Action
+				resource policy.Resource
+				want     bool
+			}{
+				{policy.ViewPost, tc.resource(policy.KindPost), tc.vis == visPublic},
+				{policy.ViewArticle, tc.resource(policy.KindArticle), tc.vis == visPublic},
+				{policy.CommentOnPost, tc.resource(policy.KindPost), false},
+				{policy.ViewProfile, tc.resource(policy.KindUser), tc.public},
+				{policy.MessageUser, tc.resource(policy.KindUser), false},
+				{policy.DeliverMessage, tc.resource(policy.KindUser), false},
+				{policy.ViewGroupContent, tc.resource(policy.KindGroup), false},
+			}
+			for _, check := range checks {
+				if got := policy.Allowed(guest, check.action, check.resource, rel); got != check.want {
+					t.Errorf("%s: got %t, want %t", check.action, got, check.want)
+				}
+			}
+		})
+	}
+}
+
+func TestAllowedHiddenContent(t *testing.T) {
+	for _, tc := range testCases {
+		t.Run(tc.String(), func(t *testing.T) {
+			viewer, rel := tc.viewer(), tc.relation()
+
+			deleted := tc.resource(policy.KindPost)
+			deleted.Deleted = true
+			for _, action := range []policy.Action{policy.ViewPost, policy.CommentOnPost, policy.ViewArticle} {
+				if policy.Allowed(viewer, action, deleted, rel) {
+					t.Errorf("%s on deleted content: got true, want false", action)
+				}
+			}
+
+			draft := tc.resource(policy.KindArticle)
+			draft.Unpublished = true
+			if got, want := policy.Allowed(viewer, policy.ViewArticle, draft, rel), tc.follow == followSelf; got != want {
+				t.Errorf("%s on unpublished article: got %t, want %t", policy.ViewArticle, got, want)
+			}
+		})
+	}
+}
+
+// openTestDB returns a database created by the migrations, so that the SQL fragments run
+// against the real tables.
+func openTestDB(t *testing.T) *database.DB {
+	t.Helper()
+
+	db, err := database.New(filepath.Join(t.TempDir(), "test.sqlite"), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// seed replaces the rows of db with post 1, article 1 and group 1 owned by ownerID and the
+// follow, allow list and membership rows of tc for viewer.
+func seed(t *testing.T, db *database.DB, tc testCase, viewer policy.Viewer, articleStatus string, deleted bool) {
+	t.Helper()
+
+	allowed := otherID
+	if tc.vis == visLimitedIn && !viewer.IsGuest() {
+		allowed = viewer.ID
+	}
+	var deletedAt any
+	if deleted {
+		deletedAt = "2024-01-01 00:00:00"
+	}
+
+	type stmt struct {
+		query string
+		args  []any
+	}
+	stmts := []stmt{
+		{`DELETE FROM post; DELETE FROM post_user_can_view; DELETE FROM article; DELETE FROM article_user_can_view;
+			DELETE FROM follow_request; DELETE FROM groups; DELETE FROM group_members`, nil},
+		{`INSERT INTO post (id, user_id, visibility, deleted_at) VALUES (1, $1, $2, $3)`, []any{ownerID, tc.vis.visibility(), deletedAt}},
+		{`INSERT INTO article (id, user_id, title, body, body_html, visibility, status) VALUES (1, $1, 'Title', 'Body', '<p>Body</p>', $2, $3)`, []any{ownerID, tc.vis.visibility(), articleStatus}},
+		{`INSERT INTO groups (id, owner_id) VALUES (1, $1)`, []any{ownerID}},
+	}
+	if tc.vis == visLimitedIn || tc.vis == visLimitedOut {
+		stmts = append(stmts,
+			stmt{`INSERT INTO post_user_can_view (post_id, user_id) VALUES (1, $1)`, []any{allowed}},
+			stmt{`INSERT INTO article_user_can_view (article_id, user_id) VALUES (1, $1)`, []any{allowed}},
+		)
+	}
+	if tc.follow == followPending || tc.follow == followAccepted {
+		stmts = append(stmts, stmt{`INSERT INTO follow_request (requester_id, target_id, status) VALUES ($1, $2, $3)`, []any{viewer.ID, ownerID, tc.follow.String()}})
+	}
+	if tc.member && !viewer.IsGuest() {
+		stmts = append(stmts, stmt{`INSERT INTO group_members (group_id, user_id) VALUES (1, $1)`, []any{viewer.ID}})
+	}
+
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt.query, stmt.args...); err != nil {
+			t.Fatalf("%s: %v", stmt.query, err)
+		}
+	}
+}
+
+func queryBool(t *testing.T, db *database.DB, query string, viewer policy.Viewer) bool {
+	t.Helper()
+
+	var got bool
+	if err := db.QueryRow(query, viewer.ID).Scan(&got); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return got
+}
+
+// TestSQLAgreesWithAllowed checks that the SQL fragments select exactly the rows policy.Allowed lets
+// the viewer see, for every test case and for guests.
+func TestSQLAgreesWithAllowed(t *testing.T) {
+	db := openTestDB(t)
+
+	postQuery := `SELECT ` + policy.PostVisibleSQL("p", "$1") + ` FROM post p WHERE p.id = 1`
+	articleQuery := `SELECT ` + policy.ArticleVisibleSQL("a", "$1") + ` FROM article a WHERE a.id = 1`
+	groupQuery := `SELECT ` + policy.GroupMemberSQL("1", "$1")
+
+	for _, tc := range testCases {
+		viewers := []policy.Viewer{tc.viewer()}
+		if tc.follow == followNone {
+			viewers = append(viewers, policy.Viewer{})
+		}
+
+		for _, viewer := range viewers {
+			name := tc.String()
+			rel := tc.relation()
+			if viewer.IsGuest() {
+				name += "/guest"
+				rel = policy.Relation{}
+			}
+
+			t.Run(name, func(t *testing.T) {
+				for _, deleted := range []bool{false, true} {
+					seed(t, db, tc, viewer, policy.ArticlePublished, deleted)
+
+					post := tc.resource(policy.KindPost)
+					post.Deleted = deleted
+					if got, want := queryBool(t, db, postQuery, viewer), policy.Allowed(viewer, policy.ViewPost, post, rel); got != want {
+						t.Errorf("PostVisibleSQL (deleted=%t): got %t, Allowed says %t", deleted, got, want)
+					}
+				}
+
+				for _, status := range []string{policy.ArticleDraft, policy.ArticleScheduled, policy.ArticlePublished} {
+					seed(t, db, tc, viewer, status, false)
+
+					article := tc.resource(policy.KindArticle)
+					article.Unpublished = status != policy.ArticlePublished
+					if got, want := queryBool(t, db, articleQuery, viewer), policy.Allowed(viewer, policy.ViewArticle, article, rel); got != want {
+						t.Errorf("ArticleVisibleSQL (status=%s): got %t, Allowed says %t", status, got, want)
+					}
+				}
+
+				if got, want := queryBool(t, db, groupQuery, viewer), policy.Allowed(viewer, policy.ViewGroupContent, tc.resource(policy.KindGroup), rel); got != want {
+					t.Errorf("GroupMemberSQL: got %t, Allowed says %t", got, want)
+				}
+			})
+		}
+	}
+}
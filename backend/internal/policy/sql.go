@@ -0,0 +1,66 @@
+package policy
+
+import "fmt"
+
+// PostVisibleSQL returns a boolean SQL expression that is true when the viewer bound to
+// the viewerParam placeholder (e.g. "$1") may view the post aliased as postAlias.
+// It is the query counterpart of Allowed(viewer, ViewPost, ...); deleted posts never match.
+func PostVisibleSQL(postAlias, viewerParam string) string {
+	return fmt.Sprintf(`%[1]s.deleted_at IS NULL AND %[2]s`,
+		postAlias, audienceSQL(postAlias, viewerParam, "post_user_can_view", "post_id"))
+}
+
+// ArticleVisibleSQL returns a boolean SQL expression that is true when the viewer bound to
+// viewerParam may view the article aliased as articleAlias: its author always can, anybody
+// else only once it is published and within its visibility.
+func ArticleVisibleSQL(articleAlias, viewerParam string) string {
+	return fmt.Sprintf(`(
+		%[1]s.user_id = %[2]s
+		OR (%[1]s.status = '%[3]s' AND %[4]s)
+	)`, articleAlias, viewerParam, ArticlePublished,
+		audienceSQL(articleAlias, viewerParam, "article_user_can_view", "article_id"))
+}
+
+// audienceSQL matches the author, public rows, followers-only rows for accepted followers and
+// limited rows for viewers on the allow list stored in allowTable under allowColumn.
+func audienceSQL(alias, viewerParam, allowTable, allowColumn string) string {
+	return fmt.Sprintf(`(
+		-- Authors always see their own content
+		%[1]s.user_id = %[2]s
+
+		-- Public content
+		OR %[1]s.visibility = '%[3]s'
+
+		-- Followers-only content requires an accepted follow
+		OR (
+			%[1]s.visibility = '%[4]s'
+			AND EXISTS (
+				SELECT 1
+				FROM follow_request f
+				WHERE f.requester_id = %[2]s
+				  AND f.target_id = %[1]s.user_id
+				  AND f.status = 'accepted'
+			)
+		)
+
+		-- Limited content requires the viewer on the allow list
+		OR (
+			%[1]s.visibility = '%[5]s'
+			AND EXISTS (
+				SELECT 1
+				FROM %[6]s acv
+				WHERE acv.%[7]s = %[1]s.id
+				  AND acv.user_id = %[2]s
+			)
+		)
+	)`, alias, viewerParam, VisibilityPublic, VisibilityFollowers, VisibilityLimited, allowTable, allowColumn)
+}
+
+// GroupMemberSQL returns a boolean SQL expression that is true when the user bound to
+// userParam belongs to, or owns, the group whose id is the groupIDExpr expression.
+func GroupMemberSQL(groupIDExpr, userParam string) string {
+	return fmt.Sprintf(`(
+		EXISTS (SELECT 1 FROM group_members gm WHERE gm.group_id = %[1]s AND gm.user_id = %[2]s)
+		OR EXISTS (SELECT 1 FROM groups g WHERE g.id = %[1]s AND g.owner_id = %[2]s)
+	)`, groupIDExpr, userParam)
+}
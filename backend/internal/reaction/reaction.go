@@ -0,0 +1,37 @@
+// Package reaction defines the fixed set of reactions users can leave on posts, comments and messages.
+package reaction
+
+// Reaction categories.
+const (
+	CategoryEmoji    = "emoji"
+	CategoryResearch = "research"
+)
+
+type Kind struct {
+	Name     string `json:"name"`
+	Emoji    string `json:"emoji"`
+	Category string `json:"category"`
+}
+
+// Kinds lists every supported reaction in display order.
+var Kinds = []Kind{
+	{Name: "like", Emoji: "👍", Category: CategoryEmoji},
+	{Name: "love", Emoji: "❤️", Category: CategoryEmoji},
+	{Name: "laugh", Emoji: "😂", Category: CategoryEmoji},
+	{Name: "wow", Emoji: "😮", Category: CategoryEmoji},
+	{Name: "sad", Emoji: "😢", Category: CategoryEmoji},
+	{Name: "insightful", Emoji: "💡", Category: CategoryResearch},
+	{Name: "helpful", Emoji: "🙌", Category: CategoryResearch},
+	{Name: "well_sourced", Emoji: "📚", Category: CategoryResearch},
+	{Name: "needs_source", Emoji: "❓", Category: CategoryResearch},
+}
+
+// IsValid reports whether name is a supported reaction.
+func IsValid(name string) bool {
+	for _, kind := range Kinds {
+		if kind.Name == name {
+			return true
+		}
+	}
+	return false
+}
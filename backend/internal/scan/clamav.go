@@ -0,0 +1,124 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultClamAVTimeout bounds a whole scan, from connecting to reading the verdict.
+	DefaultClamAVTimeout = 2 * time.Minute
+	// clamAVChunkSize is the size of the chunks a file is streamed in.
+	clamAVChunkSize = 64 << 10
+	// maxClamAVReply bounds the reply read from the daemon.
+	maxClamAVReply = 4 << 10
+)
+
+// ClamAV is a Scanner backed by a clamd daemon listening on TCP. Files are sent with the
+// INSTREAM command, so the daemon needs no access to the media store.
+type ClamAV struct {
+	// Addr is the host:port of the daemon.
+	Addr    string
+	Timeout time.Duration
+}
+
+// NewClamAV returns a scanner for the clamd daemon at addr.
+func NewClamAV(addr string) *ClamAV {
+	return &ClamAV{Addr: addr, Timeout: DefaultClamAVTimeout}
+}
+
+// Scan implements Scanner.
+func (c *ClamAV) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", c.Addr)
+	if err != nil {
+		return Result{}, fmt.Errorf("scan: connecting to clamd: %w", err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return Result{}, err
+		}
+	}
+
+	// The daemon stops reading and replies as soon as a file exceeds its limits, so the reply
+	// is read even when streaming fails.
+	streamErr := stream(conn, r)
+
+	reply, err := bufio.NewReader(io.LimitReader(conn, maxClamAVReply)).ReadBytes(0)
+	if err == io.EOF && len(reply) > 0 {
+		err = nil
+	}
+	if err != nil {
+		if streamErr != nil {
+			return Result{}, fmt.Errorf("scan: streaming to clamd: %w", streamErr)
+		}
+		return Result{}, fmt.Errorf("scan: reading clamd reply: %v", err)
+	}
+
+	return parseClamAVReply(reply)
+}
+
+// stream sends r to w with the INSTREAM command: length-prefixed chunks ended by an empty one.
+func stream(w io.Writer, r io.Reader) error {
+	buf := bufio.NewWriter(w)
+	if _, err := buf.WriteString("zINSTREAM\x00"); err != nil {
+		return err
+	}
+
+	chunk := make([]byte, clamAVChunkSize)
+	var size [4]byte
+	for {
+		n, err := r.Read(chunk)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size[:], uint32(n))
+			if _, err := buf.Write(size[:]); err != nil {
+				return err
+			}
+			if _, err := buf.Write(chunk[:n]); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	binary.BigEndian.PutUint32(size[:], 0)
+	if _, err := buf.Write(size[:]); err != nil {
+		return err
+	}
+	return buf.Flush()
+}
+
+// parseClamAVReply reads the verdict of the daemon: "stream: OK", "stream: <signature> FOUND"
+// or a message ending with ERROR.
+func parseClamAVReply(reply []byte) (Result, error) {
+	line := strings.TrimSpace(string(bytes.TrimRight(reply, "\x00\n")))
+	line = strings.TrimPrefix(line, "stream: ")
+
+	switch {
+	case line == "OK":
+		return Result{Clean: true}, nil
+	case strings.HasSuffix(line, " FOUND"):
+		return Result{Signature: strings.TrimSuffix(line, " FOUND")}, nil
+	case strings.HasSuffix(line, "ERROR"):
+		return Result{}, fmt.Errorf("%w: %s", ErrUnscannable, line)
+	default:
+		return Result{}, fmt.Errorf("scan: unexpected clamd reply %q", line)
+	}
+}
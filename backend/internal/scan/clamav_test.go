@@ -0,0 +1,216 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+// instream is what the fake daemon read from a connection.
+type instream struct {
+	data   []byte
+	chunks []int
+	err    error
+}
+
+var errSizeLimit = errors.New("size limit exceeded")
+
+// readInstream reads an INSTREAM command from r up to the terminating empty chunk, or until
+// more than limit bytes were streamed.
+func readInstream(r io.Reader, limit int) instream {
+	var in instream
+	cmd := make([]byte, len("zINSTREAM\x00"))
+	if _, in.err = io.ReadFull(r, cmd); in.err != nil {
+		return in
+	}
+	if string(cmd) != "zINSTREAM\x00" {
+		in.err = errors.New("unexpected command " + string(cmd))
+		return in
+	}
+
+	var size [4]byte
+	for {
+		if _, in.err = io.ReadFull(r, size[:]); in.err != nil {
+			return in
+		}
+		n := int(binary.BigEndian.Uint32(size[:]))
+		if n == 0 {
+			return in
+		}
+		in.chunks = append(in.chunks, n)
+
+		chunk := make([]byte, n)
+		if _, in.err = io.ReadFull(r, chunk); in.err != nil {
+			return in
+		}
+		in.data = append(in.data, chunk...)
+		if len(in.data) > limit {
+			in.err = errSizeLimit
+			return in
+		}
+	}
+}
+
+// fakeClamd starts a daemon on a loopback port that serves each connection with handle, and
+// returns a scanner for it.
+func fakeClamd(t *testing.T, handle func(conn net.Conn)) *ClamAV {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+
+	c := NewClamAV(ln.Addr().String())
+	c.Timeout = 10 * time.Second
+	return c
+}
+
+// payload returns n bytes of content to scan.
+func payload(n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	return data
+}
+
+func TestClamAVScan(t *testing.T) {
+	tests := []struct {
+		name    string
+		reply   string
+		want    Result
+		wantErr error
+	}{
+		{name: "clean", reply: "stream: OK\x00", want: Result{Clean: true}},
+		{name: "infected", reply: "stream: Eicar-Test-Signature FOUND\x00", want: Result{Signature: "Eicar-Test-Signature"}},
+		{name: "error", reply: "stream: Can't allocate memory ERROR\x00", wantErr: ErrUnscannable},
+	}
+
+	data := payload(2*clamAVChunkSize + 1000)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			streams := make(chan instream, 1)
+			c := fakeClamd(t, func(conn net.Conn) {
+				streams <- readInstream(bufio.NewReader(conn), math.MaxInt)
+				conn.Write([]byte(tt.reply))
+			})
+
+			got, err := c.Scan(context.Background(), bytes.NewReader(data))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got error %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+
+			in := <-streams
+			if in.err != nil {
+				t.Fatalf("daemon: %v", in.err)
+			}
+			if !bytes.Equal(in.data, data) {
+				t.Errorf("daemon received %d bytes, want the %d bytes scanned", len(in.data), len(data))
+			}
+			for _, n := range in.chunks {
+				if n > clamAVChunkSize {
+					t.Errorf("chunk of %d bytes, want at most %d", n, clamAVChunkSize)
+				}
+			}
+		})
+	}
+}
+
+func TestClamAVScanSizeLimit(t *testing.T) {
+	const limit = 100 << 10
+	errRead := errors.New("read failed")
+
+	// The daemon replies as soon as the limit is exceeded, then reads on until the client
+	// hangs up, like clamd does with StreamMaxLength.
+	replyEarly := func(conn net.Conn) {
+		if in := readInstream(conn, limit); in.err == errSizeLimit {
+			conn.Write([]byte("INSTREAM size limit exceeded. ERROR\x00"))
+		}
+		io.Copy(io.Discard, conn)
+	}
+	// The daemon hangs up without a verdict once the client stops sending.
+	noReply := func(conn net.Conn) {
+		conn.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+		readInstream(conn, math.MaxInt)
+	}
+
+	tests := []struct {
+		name    string
+		handle  func(conn net.Conn)
+		r       io.Reader
+		wantErr error
+	}{
+		{
+			name:    "reply before the end of the stream",
+			handle:  replyEarly,
+			r:       bytes.NewReader(payload(1 << 20)),
+			wantErr: ErrUnscannable,
+		},
+		{
+			name:    "reply after the stream failed",
+			handle:  replyEarly,
+			r:       io.MultiReader(bytes.NewReader(payload(2*limit)), iotest.ErrReader(errRead)),
+			wantErr: ErrUnscannable,
+		},
+		{
+			name:    "no reply after the stream failed",
+			handle:  noReply,
+			r:       io.MultiReader(bytes.NewReader(payload(limit)), iotest.ErrReader(errRead)),
+			wantErr: errRead,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := fakeClamd(t, tt.handle)
+
+			got, err := c.Scan(context.Background(), tt.r)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+			if got != (Result{}) {
+				t.Errorf("got %+v, want no result", got)
+			}
+		})
+	}
+}
+
+func TestClamAVScanUnreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	if _, err := NewClamAV(addr).Scan(context.Background(), bytes.NewReader(payload(10))); err == nil {
+		t.Error("got no error from a daemon that is not listening")
+	}
+}
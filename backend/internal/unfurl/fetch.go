@@ -0,0 +1,149 @@
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+const (
+	dialTimeout   = 3 * time.Second
+	headerTimeout = 4 * time.Second
+	maxRedirects  = 5
+)
+
+// Document is a resource retrieved by a Fetcher.
+type Document struct {
+	// URL is the final URL, after redirects.
+	URL         string
+	StatusCode  int
+	ContentType string
+	Header      http.Header
+	// Body holds at most the number of bytes requested from Fetch.
+	Body []byte
+}
+
+// Fetcher retrieves documents for an Unfurler. Implementations must honour ctx and return an
+// error for responses other than 2xx.
+type Fetcher interface {
+	Fetch(ctx context.Context, rawURL string, maxBytes int64) (*Document, error)
+}
+
+// HTTPFetcher is a Fetcher backed by an http.Client.
+type HTTPFetcher struct {
+	Client *http.Client
+}
+
+// NewHTTPFetcher returns a fetcher whose client only connects to public addresses. The check
+// runs on the resolved address of every connection, redirects included, so DNS names pointing
+// to internal hosts are refused as well.
+func NewHTTPFetcher() *HTTPFetcher {
+	dialer := &net.Dialer{
+		Timeout: dialTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil || !isPublic(addrPort.Addr()) {
+				return ErrBlockedAddress
+			}
+			return nil
+		},
+	}
+
+	transport := &http.Transport{
+		Proxy:                  nil,
+		DialContext:            dialer.DialContext,
+		TLSHandshakeTimeout:    dialTimeout,
+		ResponseHeaderTimeout:  headerTimeout,
+		MaxResponseHeaderBytes: 32 << 10,
+		MaxIdleConns:           10,
+		IdleConnTimeout:        30 * time.Second,
+	}
+
+	return &HTTPFetcher{Client: &http.Client{
+		Transport:     transport,
+		Timeout:       DefaultTimeout,
+		CheckRedirect: checkRedirect,
+	}}
+}
+
+// checkRedirect follows at most maxRedirects redirects, to http and https URLs only.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return errors.New("unfurl: too many redirects")
+	}
+	if _, err := parseURL(req.URL.String()); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Fetch implements Fetcher.
+func (f *HTTPFetcher) Fetch(ctx context.Context, rawURL string, maxBytes int64) (*Document, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", UserAgent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml;q=0.9,image/*;q=0.5,*/*;q=0.1")
+
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("unfurl: %s returned status %d", rawURL, resp.StatusCode)
+	}
+
+	// Pages larger than the cap are cut off: the metadata is expected near the top.
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	return &Document{
+		URL:         resp.Request.URL.String(),
+		StatusCode:  resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Header:      resp.Header,
+		Body:        body,
+	}, nil
+}
+
+// nonPublicPrefixes are special-purpose ranges not covered by the netip predicates.
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),       // "this" network
+	netip.MustParsePrefix("100.64.0.0/10"),   // carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),    // IETF protocol assignments
+	netip.MustParsePrefix("192.0.2.0/24"),    // documentation
+	netip.MustParsePrefix("198.18.0.0/15"),   // benchmarking
+	netip.MustParsePrefix("198.51.100.0/24"), // documentation
+	netip.MustParsePrefix("203.0.113.0/24"),  // documentation
+	netip.MustParsePrefix("240.0.0.0/4"),     // reserved, broadcast
+	netip.MustParsePrefix("64:ff9b::/96"),    // NAT64, embeds IPv4 addresses
+	netip.MustParsePrefix("64:ff9b:1::/48"),  // local-use NAT64
+	netip.MustParsePrefix("2001:db8::/32"),   // documentation
+	netip.MustParsePrefix("2002::/16"),       // 6to4, embeds IPv4 addresses
+}
+
+// isPublic reports whether addr is a globally routable unicast address.
+func isPublic(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || !addr.IsGlobalUnicast() || addr.IsPrivate() || addr.IsLoopback() ||
+		addr.IsLinkLocalUnicast() || addr.IsUnspecified() || addr.IsMulticast() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
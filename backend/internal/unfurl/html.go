@@ -0,0 +1,168 @@
+package unfurl
+
+import (
+	"bytes"
+	"html"
+	"strings"
+)
+
+// head is the metadata found at the top of an HTML page.
+type head struct {
+	title string
+	// meta maps lowercased meta property and name attributes to their first content.
+	meta map[string]string
+}
+
+// parseHead scans doc for the title and meta tags, stopping at the end of the head or the
+// start of the body. It is a lenient scanner rather than a full HTML parser: it only has to
+// find tags, skip comments, scripts and styles, and read attributes.
+func parseHead(doc []byte) head {
+	h := head{meta: map[string]string{}}
+
+	for i := 0; i < len(doc); {
+		lt := bytes.IndexByte(doc[i:], '<')
+		if lt < 0 {
+			break
+		}
+		i += lt
+
+		if bytes.HasPrefix(doc[i:], []byte("<!--")) {
+			end := bytes.Index(doc[i+4:], []byte("-->"))
+			if end < 0 {
+				break
+			}
+			i += 4 + end + 3
+			continue
+		}
+
+		name, next := tagName(doc, i+1)
+		switch name {
+		case "":
+			i++
+			continue
+		case "/head", "body":
+			return h
+		case "script", "style", "title", "noscript":
+			gt := bytes.IndexByte(doc[next:], '>')
+			if gt < 0 {
+				return h
+			}
+			start := next + gt + 1
+			end := indexFold(doc[start:], "</"+name)
+			if end < 0 {
+				return h
+			}
+			if name == "title" && h.title == "" {
+				h.title = html.UnescapeString(string(doc[start : start+end]))
+			}
+			i = start + end
+			continue
+		}
+
+		attrs, end := tagAttributes(doc, next)
+		if name == "meta" {
+			key := strings.ToLower(strings.TrimSpace(first(attrs["property"], attrs["name"])))
+			if _, seen := h.meta[key]; key != "" && !seen {
+				h.meta[key] = attrs["content"]
+			}
+		}
+		i = end
+	}
+
+	return h
+}
+
+// tagName reads the lowercased name of the tag starting at doc[i], after the '<'. Closing
+// tags keep their '/'. It returns the name and the index following it.
+func tagName(doc []byte, i int) (string, int) {
+	start := i
+	if i < len(doc) && doc[i] == '/' {
+		i++
+	}
+	for i < len(doc) && isNameByte(doc[i]) {
+		i++
+	}
+	if i == start || (doc[start] == '/' && i == start+1) {
+		return "", i
+	}
+	return strings.ToLower(string(doc[start:i])), i
+}
+
+func isNameByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == ':'
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// tagAttributes reads the attributes of a tag from doc[i] to its closing '>'. It returns the
+// lowercased names mapped to their unescaped values, and the index after the tag.
+func tagAttributes(doc []byte, i int) (map[string]string, int) {
+	attrs := map[string]string{}
+
+	for i < len(doc) {
+		for i < len(doc) && (isSpace(doc[i]) || doc[i] == '/') {
+			i++
+		}
+		if i >= len(doc) {
+			break
+		}
+		if doc[i] == '>' {
+			return attrs, i + 1
+		}
+
+		start := i
+		for i < len(doc) && !isSpace(doc[i]) && doc[i] != '=' && doc[i] != '>' && doc[i] != '/' {
+			i++
+		}
+		name := strings.ToLower(string(doc[start:i]))
+		if name == "" {
+			i++
+			continue
+		}
+
+		for i < len(doc) && isSpace(doc[i]) {
+			i++
+		}
+		value := ""
+		if i < len(doc) && doc[i] == '=' {
+			i++
+			for i < len(doc) && isSpace(doc[i]) {
+				i++
+			}
+			if i < len(doc) && (doc[i] == '"' || doc[i] == '\'') {
+				quote := doc[i]
+				end := bytes.IndexByte(doc[i+1:], quote)
+				if end < 0 {
+					return attrs, len(doc)
+				}
+				value = string(doc[i+1 : i+1+end])
+				i += end + 2
+			} else {
+				start := i
+				for i < len(doc) && !isSpace(doc[i]) && doc[i] != '>' {
+					i++
+				}
+				value = string(doc[start:i])
+			}
+		}
+
+		if _, seen := attrs[name]; !seen {
+			attrs[name] = html.UnescapeString(value)
+		}
+	}
+
+	return attrs, len(doc)
+}
+
+// indexFold is bytes.Index ignoring ASCII case; substr must be lowercase.
+func indexFold(s []byte, substr string) int {
+	n := len(substr)
+	for i := 0; i+n <= len(s); i++ {
+		if strings.EqualFold(string(s[i:i+n]), substr) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,130 @@
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+)
+
+// robotsAllow reports whether robots.txt on the host of target lets robotsAgent fetch it.
+// A missing or unreadable robots.txt allows everything, as crawlers usually assume, but a
+// blocked address is reported since the page itself would be refused too.
+func (u *Unfurler) robotsAllow(ctx context.Context, target *url.URL) (bool, error) {
+	robotsURL := url.URL{Scheme: target.Scheme, Host: target.Host, Path: "/robots.txt"}
+
+	doc, err := u.Fetcher.Fetch(ctx, robotsURL.String(), maxRobotsBytes)
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		if errors.Is(err, ErrBlockedAddress) {
+			return false, ErrBlockedAddress
+		}
+		return true, nil
+	}
+
+	path := target.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if target.RawQuery != "" {
+		path += "?" + target.RawQuery
+	}
+
+	return robotsAllows(string(doc.Body), robotsAgent, path), nil
+}
+
+// robotsRule is an Allow or Disallow line of robots.txt.
+type robotsRule struct {
+	allow   bool
+	pattern string
+}
+
+// robotsAllows applies the robots.txt rules of the group naming agent, or of the group with
+// the longest name contained in agent, or of the * group when none does. The longest matching pattern wins and Allow wins ties.
+func robotsAllows(body, agent, path string) bool {
+	groups := map[string][]robotsRule{}
+	var current []string
+	inRules := false
+
+	for _, line := range strings.Split(body, "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if inRules {
+				current = nil
+				inRules = false
+			}
+			current = append(current, strings.ToLower(value))
+		case "allow", "disallow":
+			inRules = true
+			for _, name := range current {
+				groups[name] = append(groups[name], robotsRule{allow: key == "allow", pattern: value})
+			}
+		}
+	}
+
+	rules, ok := groups[agent]
+	if !ok {
+		// Otherwise the group with the longest name contained in agent, the most specific one.
+		matched := ""
+		for name, group := range groups {
+			if name != "*" && name != "" && strings.Contains(agent, name) && len(name) > len(matched) {
+				rules, matched, ok = group, name, true
+			}
+		}
+	}
+	if !ok {
+		rules = groups["*"]
+	}
+
+	allowed, longest := true, -1
+	for _, rule := range rules {
+		// An empty Disallow allows everything.
+		if rule.pattern == "" {
+			continue
+		}
+		if !robotsMatch(rule.pattern, path) {
+			continue
+		}
+		if n := len(rule.pattern); n > longest || (n == longest && rule.allow) {
+			allowed, longest = rule.allow, n
+		}
+	}
+
+	return allowed
+}
+
+// robotsMatch matches path against a robots.txt pattern: a prefix where * matches any
+// sequence and a final $ anchors the end.
+func robotsMatch(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	rest := path[len(parts[0]):]
+
+	for i, part := range parts[1:] {
+		if anchored && i == len(parts)-2 {
+			return strings.HasSuffix(rest, part)
+		}
+		idx := strings.Index(rest, part)
+		if idx < 0 {
+			return false
+		}
+		rest = rest[idx+len(part):]
+	}
+
+	return !anchored || rest == ""
+}
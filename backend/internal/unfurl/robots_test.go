@@ -0,0 +1,54 @@
+package unfurl
+
+import "testing"
+
+func TestRobotsAllowsAgentGroups(t *testing.T) {
+	tests := []struct {
+		name  string
+		agent string
+		body  string
+		want  bool
+	}{
+		{
+			name:  "exact name over a contained one",
+			agent: "brainbookbot",
+			body:  "User-agent: bot\nDisallow: /\n\nUser-agent: brainbookbot\nAllow: /",
+			want:  true,
+		},
+		{
+			name:  "longest contained name",
+			agent: "brainbookbot/1.0",
+			body:  "User-agent: bot\nDisallow: /\n\nUser-agent: book\nDisallow: /\n\nUser-agent: brainbook\nAllow: /\n\nUser-agent: *\nDisallow: /",
+			want:  true,
+		},
+		{
+			name:  "longest contained name disallows",
+			agent: "brainbookbot/1.0",
+			body:  "User-agent: brainbook\nDisallow: /\n\nUser-agent: bot\nAllow: /",
+			want:  false,
+		},
+		{
+			name:  "star group when no name matches",
+			agent: "brainbookbot",
+			body:  "User-agent: otherbot\nAllow: /\n\nUser-agent: *\nDisallow: /",
+			want:  false,
+		},
+		{
+			name:  "empty agent name",
+			agent: "brainbookbot",
+			body:  "User-agent:\nDisallow: /\n\nUser-agent: *\nAllow: /",
+			want:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Groups are kept in a map: repeat to catch an answer depending on its order.
+			for range 20 {
+				if got := robotsAllows(tt.body, tt.agent, "/page"); got != tt.want {
+					t.Fatalf("got %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
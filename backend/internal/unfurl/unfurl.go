@@ -0,0 +1,265 @@
+// Package unfurl builds link previews: the title, description and image a web page declares
+// about itself through Open Graph, Twitter card and standard HTML metadata.
+//
+// Pages, and the preview image they declare, are retrieved through a Fetcher. The HTTPFetcher used in production enforces strict
+// timeouts and size caps and refuses to connect to private, loopback and other non-public
+// addresses, so that user-supplied URLs cannot reach internal services. Sites opt out with a
+// robots.txt rule for UserAgent (or *), an X-Robots-Tag header or a robots meta tag carrying
+// noindex, nosnippet, none or nopreview.
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// UserAgent identifies the fetcher to remote sites and selects robots.txt rules.
+	UserAgent = "BrainbookBot/1.0 (+link previews)"
+	// robotsAgent is the product token matched against robots.txt User-agent lines.
+	robotsAgent = "brainbookbot"
+
+	// DefaultTimeout bounds a whole unfurl, robots.txt included.
+	DefaultTimeout = 5 * time.Second
+	// MaxPageBytes is how much of a page is read; metadata lives in the head.
+	MaxPageBytes = 512 << 10
+	// maxRobotsBytes is how much of robots.txt is read.
+	maxRobotsBytes = 64 << 10
+	// MaxImageBytes is the largest preview image that is downloaded.
+	MaxImageBytes = 5 << 20
+
+	// MaxURLLength is the longest URL that is unfurled.
+	MaxURLLength = 2048
+	// Lengths (in runes) previews are truncated to.
+	maxTitleRunes       = 300
+	maxDescriptionRunes = 1000
+	maxSiteNameRunes    = 100
+	maxImageAltRunes    = 1500
+)
+
+var (
+	// ErrOptOut is returned when the site asked not to be previewed.
+	ErrOptOut = errors.New("unfurl: site opted out of previews")
+	// ErrBlockedAddress is returned when a URL resolves to a non-public address.
+	ErrBlockedAddress = errors.New("unfurl: address not allowed")
+	// ErrNotHTML is returned for resources that are not HTML pages.
+	ErrNotHTML = errors.New("unfurl: not an HTML page")
+	// ErrNoPreview is returned when a page declares nothing worth previewing.
+	ErrNoPreview = errors.New("unfurl: page has no preview metadata")
+)
+
+// Preview is the metadata of a web page shown alongside a link. Empty fields were not declared.
+type Preview struct {
+	URL         string
+	Title       string
+	Description string
+	// ImageURL is where Image was downloaded from. It is kept for reference only: clients are
+	// shown the downloaded copy so that they never contact the linked site.
+	ImageURL string
+	// Image holds the bytes of the preview image, nil when the page declares none or it could
+	// not be downloaded. They are not checked to be an image.
+	Image    []byte
+	ImageAlt string
+	SiteName string
+}
+
+// Unfurler builds previews from pages retrieved through Fetcher.
+type Unfurler struct {
+	Fetcher Fetcher
+	// Timeout bounds a whole unfurl. Zero means DefaultTimeout.
+	Timeout time.Duration
+}
+
+// New returns an Unfurler using fetcher.
+func New(fetcher Fetcher) *Unfurler {
+	return &Unfurler{Fetcher: fetcher, Timeout: DefaultTimeout}
+}
+
+// Unfurl fetches the page at rawURL and returns its preview.
+func (u *Unfurler) Unfurl(ctx context.Context, rawURL string) (*Preview, error) {
+	target, err := parseURL(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := u.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	allowed, err := u.robotsAllow(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, ErrOptOut
+	}
+
+	doc, err := u.Fetcher.Fetch(ctx, target.String(), MaxPageBytes)
+	if err != nil {
+		return nil, err
+	}
+	if optsOut(doc.Header.Values("X-Robots-Tag")...) {
+		return nil, ErrOptOut
+	}
+	if !isHTML(doc.ContentType) {
+		return nil, ErrNotHTML
+	}
+
+	base, err := url.Parse(doc.URL)
+	if err != nil {
+		base = target
+	}
+
+	head := parseHead(doc.Body)
+	if optsOut(head.meta["robots"], head.meta[robotsAgent]) {
+		return nil, ErrOptOut
+	}
+
+	preview := &Preview{
+		URL:         base.String(),
+		Title:       clean(first(head.meta["og:title"], head.meta["twitter:title"], head.title), maxTitleRunes),
+		Description: clean(first(head.meta["og:description"], head.meta["twitter:description"], head.meta["description"]), maxDescriptionRunes),
+		ImageURL:    resolveImage(base, first(head.meta["og:image"], head.meta["og:image:url"], head.meta["twitter:image"], head.meta["twitter:image:src"])),
+		ImageAlt:    clean(first(head.meta["og:image:alt"], head.meta["twitter:image:alt"]), maxImageAltRunes),
+		SiteName:    clean(first(head.meta["og:site_name"], base.Hostname()), maxSiteNameRunes),
+	}
+	if preview.ImageURL != "" {
+		preview.Image = u.fetchImage(ctx, preview.ImageURL)
+	}
+	if preview.Image == nil {
+		preview.ImageURL, preview.ImageAlt = "", ""
+	}
+	if preview.Title == "" && preview.Description == "" && preview.Image == nil {
+		return nil, ErrNoPreview
+	}
+
+	return preview, nil
+}
+
+// fetchImage downloads the preview image at imageURL within the deadline of ctx. Images that
+// cannot be downloaded, are not declared as images or exceed MaxImageBytes are dropped: the
+// preview is still worth showing without them.
+func (u *Unfurler) fetchImage(ctx context.Context, imageURL string) []byte {
+	doc, err := u.Fetcher.Fetch(ctx, imageURL, MaxImageBytes+1)
+	if err != nil {
+		return nil
+	}
+	mediaType, _, _ := strings.Cut(strings.ToLower(doc.ContentType), ";")
+	if !strings.HasPrefix(strings.TrimSpace(mediaType), "image/") || len(doc.Body) > MaxImageBytes {
+		return nil
+	}
+	return doc.Body
+}
+
+// rgxURL matches http and https URLs in text, up to whitespace or characters that cannot end one.
+var rgxURL = regexp.MustCompile(`https?://[^\s<>"'\x60\[\]{}|\\^]+`)
+
+// FindURL returns the first http or https URL in text, without trailing punctuation, or "" when
+// there is none.
+func FindURL(text string) string {
+	for _, match := range rgxURL.FindAllString(text, -1) {
+		match = trimURL(match)
+		if len(match) > MaxURLLength {
+			continue
+		}
+		if _, err := parseURL(match); err == nil {
+			return match
+		}
+	}
+	return ""
+}
+
+// trimURL strips punctuation ending a sentence, and closing parentheses that were not opened in the URL.
+func trimURL(s string) string {
+	for s != "" {
+		last := s[len(s)-1]
+		switch {
+		case strings.IndexByte(".,;:!?*_~", last) >= 0:
+			s = s[:len(s)-1]
+		case last == ')' && strings.Count(s, "(") < strings.Count(s, ")"):
+			s = s[:len(s)-1]
+		default:
+			return s
+		}
+	}
+	return s
+}
+
+// parseURL accepts absolute http and https URLs with a host and without credentials.
+func parseURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.New("unfurl: only http and https URLs are supported")
+	}
+	if u.Hostname() == "" || u.User != nil {
+		return nil, errors.New("unfurl: invalid URL")
+	}
+	u.Fragment = ""
+	return u, nil
+}
+
+func isHTML(contentType string) bool {
+	mediaType, _, _ := strings.Cut(strings.ToLower(contentType), ";")
+	mediaType = strings.TrimSpace(mediaType)
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+// optsOut reports whether robots directives forbid previews.
+func optsOut(directives ...string) bool {
+	for _, directive := range directives {
+		for _, token := range strings.FieldsFunc(strings.ToLower(directive), func(r rune) bool { return r == ',' || r == ' ' }) {
+			switch token {
+			case "noindex", "nosnippet", "none", "nopreview":
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func resolveImage(base *url.URL, raw string) string {
+	if raw == "" {
+		return ""
+	}
+	ref, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return ""
+	}
+	image := base.ResolveReference(ref)
+	if (image.Scheme != "http" && image.Scheme != "https") || image.Host == "" || len(image.String()) > MaxURLLength {
+		return ""
+	}
+	return image.String()
+}
+
+func first(values ...string) string {
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// clean collapses whitespace and truncates s to maxRunes.
+func clean(s string, maxRunes int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	if !utf8.ValidString(s) {
+		s = strings.ToValidUTF8(s, "")
+	}
+	if utf8.RuneCountInString(s) > maxRunes {
+		s = strings.TrimSpace(string([]rune(s)[:maxRunes-1])) + "…"
+	}
+	return s
+}
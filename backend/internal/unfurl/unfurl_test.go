@@ -0,0 +1,232 @@
+package unfurl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// pngBytes stands in for an image: the unfurler only checks the declared content type.
+var pngBytes = []byte("\x89PNG\r\n\x1a\nnot really a png")
+
+const previewPage = `<!doctype html>
+<html><head>
+<title>Fallback title</title>
+<meta property="og:title" content="Example &amp; title">
+<meta property="og:description" content="  An   example
+page ">
+<meta property="og:image" content="img.png">
+<meta property="og:image:alt" content="A red square">
+<meta property="og:site_name" content="Example">
+</head><body><meta property="og:title" content="Ignored"></body></html>`
+
+// newTestServer serves routes, and a 404 for anything else, robots.txt included.
+func newTestServer(t *testing.T, routes map[string]http.HandlerFunc) *httptest.Server {
+	t.Helper()
+
+	mux := http.NewServeMux()
+	for pattern, handler := range routes {
+		mux.HandleFunc(pattern, handler)
+	}
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// newTestUnfurler returns an Unfurler whose fetcher follows the redirect policy of
+// NewHTTPFetcher but may connect to the loopback address of test servers.
+func newTestUnfurler() *Unfurler {
+	return New(&HTTPFetcher{Client: &http.Client{Timeout: DefaultTimeout, CheckRedirect: checkRedirect}})
+}
+
+func serve(contentType, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Write([]byte(body))
+	}
+}
+
+func serveHTML(body string) http.HandlerFunc {
+	return serve("text/html; charset=utf-8", body)
+}
+
+func TestUnfurl(t *testing.T) {
+	srv := newTestServer(t, map[string]http.HandlerFunc{
+		"/articles/page": serveHTML(previewPage),
+		"/articles/img.png": func(w http.ResponseWriter, r *http.Request) {
+			if got := r.Header.Get("User-Agent"); got != UserAgent {
+				t.Errorf("User-Agent: got %q, want %q", got, UserAgent)
+			}
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(pngBytes)
+		},
+	})
+
+	preview, err := newTestUnfurler().Unfurl(context.Background(), srv.URL+"/articles/page#top")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := Preview{
+		URL:         srv.URL + "/articles/page",
+		Title:       "Example & title",
+		Description: "An example page",
+		ImageURL:    srv.URL + "/articles/img.png",
+		Image:       pngBytes,
+		ImageAlt:    "A red square",
+		SiteName:    "Example",
+	}
+	if preview.URL != want.URL || preview.Title != want.Title || preview.Description != want.Description ||
+		preview.ImageURL != want.ImageURL || !bytes.Equal(preview.Image, want.Image) ||
+		preview.ImageAlt != want.ImageAlt || preview.SiteName != want.SiteName {
+		t.Errorf("got %+v, want %+v", *preview, want)
+	}
+}
+
+func TestUnfurlOptOut(t *testing.T) {
+	tests := []struct {
+		name    string
+		robots  string
+		path    string
+		header  string
+		meta    string
+		wantErr error
+	}{
+		{name: "allowed"},
+		{name: "robots.txt disallows everyone", robots: "User-agent: *\nDisallow: /", wantErr: ErrOptOut},
+		{name: "robots.txt disallows the bot", robots: "User-agent: BrainbookBot\nDisallow: /page", wantErr: ErrOptOut},
+		{name: "robots.txt disallows another bot", robots: "User-agent: OtherBot\nDisallow: /"},
+		{name: "robots.txt allows the page", robots: "User-agent: *\nDisallow: /\nAllow: /page$"},
+		{name: "robots.txt disallows another path", robots: "User-agent: *\nDisallow: /private/"},
+		{name: "robots.txt matches the query", robots: "User-agent: *\nDisallow: /*?draft", path: "?draft=1", wantErr: ErrOptOut},
+		{name: "X-Robots-Tag header", header: "noarchive, noindex", wantErr: ErrOptOut},
+		{name: "unrelated X-Robots-Tag header", header: "noarchive"},
+		{name: "robots meta tag", meta: `<meta name="robots" content="nosnippet">`, wantErr: ErrOptOut},
+		{name: "bot meta tag", meta: `<meta name="BrainbookBot" content="nopreview">`, wantErr: ErrOptOut},
+		{name: "other bot meta tag", meta: `<meta name="otherbot" content="none">`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			routes := map[string]http.HandlerFunc{
+				"/page": func(w http.ResponseWriter, r *http.Request) {
+					if tt.header != "" {
+						w.Header().Set("X-Robots-Tag", tt.header)
+					}
+					serveHTML(`<head><title>Page</title>`+tt.meta+`</head>`)(w, r)
+				},
+			}
+			if tt.robots != "" {
+				routes["/robots.txt"] = serve("text/plain", tt.robots)
+			}
+			srv := newTestServer(t, routes)
+
+			_, err := newTestUnfurler().Unfurl(context.Background(), srv.URL+"/page"+tt.path)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUnfurlSizeCaps(t *testing.T) {
+	padding := strings.Repeat("<!-- padding -->", MaxPageBytes/16)
+
+	srv := newTestServer(t, map[string]http.HandlerFunc{
+		"/large":      serveHTML(`<head><title>Large</title>` + padding + `<meta name="description" content="cut off"></head>`),
+		"/late":       serveHTML(`<head>` + padding + `<title>Late</title></head>`),
+		"/large-img":  serveHTML(`<head><title>Page</title><meta property="og:image" content="/large.png"></head>`),
+		"/other-img":  serveHTML(`<head><title>Page</title><meta property="og:image" content="/image.html"></head>`),
+		"/only-img":   serveHTML(`<head><meta property="og:image" content="/large.png"></head>`),
+		"/large.png":  serve("image/png", strings.Repeat("x", MaxImageBytes+1)),
+		"/image.html": serveHTML("<p>not an image</p>"),
+		"/doc.pdf":    serve("application/pdf", "%PDF-1.4"),
+	})
+	unfurler := newTestUnfurler()
+
+	doc, err := unfurler.Fetcher.Fetch(context.Background(), srv.URL+"/large", MaxPageBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Body) != MaxPageBytes {
+		t.Errorf("Fetch read %d bytes, want the cap of %d", len(doc.Body), MaxPageBytes)
+	}
+
+	preview, err := unfurler.Unfurl(context.Background(), srv.URL+"/large")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.Title != "Large" || preview.Description != "" {
+		t.Errorf("large page: got title %q and description %q, want metadata before the cap only", preview.Title, preview.Description)
+	}
+
+	if _, err := unfurler.Unfurl(context.Background(), srv.URL+"/late"); !errors.Is(err, ErrNoPreview) {
+		t.Errorf("metadata past the cap: got error %v, want %v", err, ErrNoPreview)
+	}
+
+	for _, path := range []string{"/large-img", "/other-img"} {
+		preview, err := unfurler.Unfurl(context.Background(), srv.URL+path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if preview.Image != nil || preview.ImageURL != "" {
+			t.Errorf("%s: got an image of %d bytes from %q, want none", path, len(preview.Image), preview.ImageURL)
+		}
+	}
+
+	if _, err := unfurler.Unfurl(context.Background(), srv.URL+"/only-img"); !errors.Is(err, ErrNoPreview) {
+		t.Errorf("page with a dropped image only: got error %v, want %v", err, ErrNoPreview)
+	}
+
+	if _, err := unfurler.Unfurl(context.Background(), srv.URL+"/doc.pdf"); !errors.Is(err, ErrNotHTML) {
+		t.Errorf("PDF: got error %v, want %v", err, ErrNotHTML)
+	}
+}
+
+func TestUnfurlRedirects(t *testing.T) {
+	srv := newTestServer(t, map[string]http.HandlerFunc{
+		"/old":      http.RedirectHandler("/new/page", http.StatusMovedPermanently).ServeHTTP,
+		"/new/page": serveHTML(`<head><title>New</title><meta property="og:image" content="img.png"></head>`),
+		"/new/img.png": func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "image/png")
+			w.Write(pngBytes)
+		},
+		"/loop":   http.RedirectHandler("/loop", http.StatusFound).ServeHTTP,
+		"/scheme": http.RedirectHandler("ftp://example.com/page", http.StatusFound).ServeHTTP,
+	})
+	unfurler := newTestUnfurler()
+
+	preview, err := unfurler.Unfurl(context.Background(), srv.URL+"/old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if preview.URL != srv.URL+"/new/page" {
+		t.Errorf("URL: got %q, want the redirect target", preview.URL)
+	}
+	if preview.ImageURL != srv.URL+"/new/img.png" {
+		t.Errorf("ImageURL: got %q, want it resolved against the redirect target", preview.ImageURL)
+	}
+
+	if _, err := unfurler.Unfurl(context.Background(), srv.URL+"/loop"); err == nil || !strings.Contains(err.Error(), "too many redirects") {
+		t.Errorf("redirect loop: got error %v, want too many redirects", err)
+	}
+
+	if _, err := unfurler.Unfurl(context.Background(), srv.URL+"/scheme"); err == nil || !strings.Contains(err.Error(), "only http and https") {
+		t.Errorf("redirect to ftp: got error %v, want the scheme refused", err)
+	}
+}
+
+func TestHTTPFetcherBlocksPrivateAddresses(t *testing.T) {
+	srv := newTestServer(t, map[string]http.HandlerFunc{"/page": serveHTML(previewPage)})
+
+	if _, err := NewHTTPFetcher().Fetch(context.Background(), srv.URL+"/page", MaxPageBytes); !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("Fetch: got error %v, want %v", err, ErrBlockedAddress)
+	}
+	if _, err := New(NewHTTPFetcher()).Unfurl(context.Background(), srv.URL+"/page"); !errors.Is(err, ErrBlockedAddress) {
+		t.Errorf("Unfurl: got error %v, want %v", err, ErrBlockedAddress)
+	}
+}
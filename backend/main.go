@@ -6,11 +6,18 @@ import (
 	"log/slog"
 	"os"
 	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
 
 	"brainbook-api/api"
 	"brainbook-api/api/websocket"
 	"brainbook-api/internal/database"
 	"brainbook-api/internal/env"
+	"brainbook-api/internal/imaging"
+	"brainbook-api/internal/media"
+	"brainbook-api/internal/scan"
+	"brainbook-api/internal/unfurl"
 	"brainbook-api/internal/version"
 )
 
@@ -32,6 +39,24 @@ func run(logger *slog.Logger) error {
 	cfg.HttpPort = env.GetInt("HTTP_PORT", 8080)
 	cfg.DB.DSN = env.GetString("DB_DSN", "db.sqlite")
 	cfg.DB.Automigrate = env.GetBool("DB_AUTOMIGRATE", true)
+	cfg.Content.RetentionPeriod = time.Duration(env.GetInt("CONTENT_RETENTION_DAYS", 30)) * 24 * time.Hour
+	cfg.Content.MaxCommentDepth = env.GetInt("COMMENT_MAX_DEPTH", 3)
+	cfg.Media.Dir = env.GetString("MEDIA_DIR", "media")
+	cfg.Media.Types = media.NewRegistry(media.DefaultTypes())
+	if err := cfg.Media.Types.Configure(env.GetString("MEDIA_TYPES", ""), env.GetString("MEDIA_TYPE_LIMITS_MB", "")); err != nil {
+		return err
+	}
+	cfg.Media.ImageLimits = imaging.DefaultLimits
+	cfg.Media.ImageLimits.MaxPixels = env.GetInt("MEDIA_MAX_IMAGE_MEGAPIXELS", 40) * 1_000_000
+	cfg.Media.ImageLimits.MaxFrames = env.GetInt("MEDIA_MAX_GIF_FRAMES", imaging.DefaultLimits.MaxFrames)
+	cfg.Media.UserQuota = int64(env.GetInt("MEDIA_USER_QUOTA_MB", 1000)) * 1_000_000
+	cfg.Media.GroupQuota = int64(env.GetInt("MEDIA_GROUP_QUOTA_MB", 5000)) * 1_000_000
+	cfg.Media.OrphanGracePeriod = time.Duration(env.GetInt("MEDIA_ORPHAN_GRACE_HOURS", 24)) * time.Hour
+	adminIDs, err := parseIDList(env.GetString("ADMIN_USER_IDS", ""))
+	if err != nil {
+		return err
+	}
+	cfg.AdminIDs = adminIDs
 	// cfg.JWT.SecretKey = env.GetString("JWT_SECRET_KEY", "rev3alim442itqpwlereeo5npf3h5uip")
 
 	showVersion := flag.Bool("version", false, "display version and exit")
@@ -50,6 +75,11 @@ func run(logger *slog.Logger) error {
 	fmt.Printf("Connected to database: %s\n", cfg.DB.DSN)
 	defer db.Close()
 
+	store, err := media.NewDiskStore(cfg.Media.Dir)
+	if err != nil {
+		return err
+	}
+
 	// if *recreateDB {
 	// 	if err := db.DropDatabase(); err != nil {
 	// 		return err
@@ -61,14 +91,49 @@ func run(logger *slog.Logger) error {
 	// }
 
 	app := &api.Application{
-		Config: cfg,
-		DB:     db,
-		Logger: logger,
+		Config:  cfg,
+		DB:      db,
+		Logger:  logger,
+		Media:   store,
+		Scanner: scan.Nop{},
+	}
+
+	if err := app.ExtractLegacyMedia(); err != nil {
+		return err
+	}
+
+	if err := db.BackfillHandles(); err != nil {
+		return err
 	}
 
 	// Initialize WebSocket manager
 	app.WSManager = websocket.NewWebsocketManager()
 	app.WSManager.DB = db
+	app.WSManager.CheckAttachments = app.CheckMessageAttachments
+
+	if addr := env.GetString("CLAMAV_ADDR", ""); addr != "" {
+		app.Scanner = scan.NewClamAV(addr)
+	}
+
+	if env.GetBool("LINK_PREVIEWS", true) {
+		app.Unfurler = unfurl.New(unfurl.NewHTTPFetcher())
+	}
 
 	return app.ServeHTTP()
 }
+
+// parseIDList parses a comma-separated list of ids, such as "1,4,7".
+func parseIDList(s string) ([]int, error) {
+	var ids []int
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		id, err := strconv.Atoi(item)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("invalid id %q", item)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -4,7 +4,7 @@ export interface ApiFollowerSummary {
   user_id: number
   f_name?: string | null
   l_name?: string | null
-  avatar?: string | null
+  avatar_id?: number | null
 }
 
 export function useFollowers(apiBase: string, userId: Ref<number | null>) {
@@ -1,4 +1,5 @@
-import { extractErrorMessage, formatDate, toDataUrl, initialsFromName, buildFullName } from './useGroupHelpers'
+import { extractErrorMessage, formatDate, initialsFromName, buildFullName } from './useGroupHelpers'
+import { mediaUrl } from '~/utils'
 import type { ReceiveGroupMessageEventPayload } from '~/types'
 import type { GroupMember } from './useGroupMembers'
 
@@ -7,7 +8,7 @@ export interface ApiGroupMessage {
   user_id?: number
   f_name?: string | null
   l_name?: string | null
-  avatar?: string | null
+  avatar_id?: number | null
   content?: string | null
   created_at?: string | null
 }
@@ -164,7 +165,7 @@ export function useGroupChat(apiBase: string, groupId: Ref<number | null>, curre
         senderId: typeof message.user_id === 'number' ? message.user_id : -1,
         senderName,
         senderInitials: initialsFromName(senderName),
-        avatarSrc: toDataUrl(message.avatar),
+        avatarSrc: mediaUrl(apiBase, message.avatar_id, 'avatar'),
         content: (message.content ?? '').trim(),
         createdAtRaw: message.created_at ?? '',
         createdAtFormatted: formatDate(message.created_at)
@@ -1,5 +1,3 @@
-import { inferImageMime } from '~/utils'
-
 export function extractErrorMessage(error: unknown): string {
   if (!error) return ''
   if (typeof error === 'string') return error
@@ -29,17 +27,6 @@ export function formatDate(value?: string | null): string {
   return parsed.toLocaleString()
 }
 
-export function toDataUrl(raw?: string | null, mime?: string): string | undefined {
-  if (!raw) return undefined
-
-  const trimmed = raw.trim()
-  if (!trimmed) return undefined
-  if (trimmed.startsWith('data:')) return trimmed
-
-  const resolvedMime = mime && mime.length > 0 ? mime : inferImageMime(trimmed)
-  return `data:${resolvedMime};base64,${trimmed}`
-}
-
 export function initialsFromName(name: string): string {
   if (!name) return '??'
 
@@ -69,20 +56,3 @@ export function toSqlDateTime(localValue: string): string {
 
   return localValue
 }
-
-export function fileToBase64(file: File): Promise<string> {
-  return new Promise<string>((resolve, reject) => {
-    const reader = new FileReader()
-    reader.onload = () => {
-      const result = typeof reader.result === 'string' ? reader.result : ''
-      const payload = result.includes(',') ? result.split(',')[1] : result
-      if (payload) {
-        resolve(payload)
-      } else {
-        reject(new Error('empty-file'))
-      }
-    }
-    reader.onerror = () => reject(reader.error ?? new Error('read-error'))
-    reader.readAsDataURL(file)
-  })
-}
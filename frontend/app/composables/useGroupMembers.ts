@@ -1,10 +1,11 @@
-import { extractErrorMessage, formatDate, toDataUrl, initialsFromName, buildFullName } from './useGroupHelpers'
+import { extractErrorMessage, formatDate, initialsFromName, buildFullName } from './useGroupHelpers'
+import { mediaUrl } from '~/utils'
 
 export interface ApiGroupMember {
   user_id?: number
   f_name?: string | null
   l_name?: string | null
-  avatar?: string | null
+  avatar_id?: number | null
   role?: string | null
   joined_at?: string | null
 }
@@ -108,7 +109,7 @@ export function useGroupMembers(apiBase: string, groupId: Ref<number | null>, is
         initials: initialsFromName(fullName),
         role: (member.role ?? 'member').toLowerCase(),
         joinedAt: formatDate(member.joined_at),
-        avatarSrc: toDataUrl(member.avatar)
+        avatarSrc: mediaUrl(apiBase, member.avatar_id, 'avatar')
       }
     })
   }
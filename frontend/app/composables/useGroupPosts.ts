@@ -1,4 +1,5 @@
-import { extractErrorMessage, formatDate, toDataUrl, initialsFromName, buildFullName } from './useGroupHelpers'
+import { extractErrorMessage, formatDate, initialsFromName, buildFullName } from './useGroupHelpers'
+import { mediaUrl } from '~/utils'
 
 export interface ApiGroupPost {
   id?: number
@@ -6,9 +7,9 @@ export interface ApiGroupPost {
   user_id?: number
   f_name?: string | null
   l_name?: string | null
-  avatar?: string | null
+  avatar_id?: number | null
   content?: string | null
-  file?: string | null
+  media_id?: number | null
   created_at?: string | null
   comment_count?: number | null
 }
@@ -18,9 +19,9 @@ export interface ApiGroupComment {
   user_id?: number
   f_name?: string | null
   l_name?: string | null
-  avatar?: string | null
+  avatar_id?: number | null
   content?: string | null
-  file?: string | null
+  media_id?: number | null
   created_at?: string | null
 }
 
@@ -66,13 +67,13 @@ export function useGroupPosts(apiBase: string, groupId: Ref<number | null>) {
 
   const newPostForm = reactive({
     content: '',
-    file: null as string | null
+    media_id: null as number | null
   })
 
   const commentsCache = reactive<Record<number, GroupComment[]>>({})
   const commentsLoading = reactive<Record<number, boolean>>({})
   const newCommentDrafts = reactive<Record<number, string>>({})
-  const newCommentFiles = reactive<Record<number, string | null>>({})
+  const newCommentFiles = reactive<Record<number, number | null>>({})
   const commentSubmitting = reactive<Record<number, boolean>>({})
   const expandedPosts = ref(new Set<number>())
 
@@ -127,12 +128,12 @@ export function useGroupPosts(apiBase: string, groupId: Ref<number | null>) {
       await $fetch(`${apiBase}/protected/v1/groups/${groupId.value}/create`, {
         method: 'POST',
         credentials: 'include',
-        body: { content, file: newPostForm.file }
+        body: { content, media_id: newPostForm.media_id ?? undefined }
       })
 
       toast.add({ title: 'Post published', description: 'Your group can see it now.' })
       newPostForm.content = ''
-      newPostForm.file = null
+      newPostForm.media_id = null
       await loadPosts()
     } catch (error) {
       toast.add({
@@ -191,7 +192,7 @@ export function useGroupPosts(apiBase: string, groupId: Ref<number | null>) {
         {
           method: 'POST',
           credentials: 'include',
-          body: { content: draft, file: newCommentFiles[postId] ?? undefined }
+          body: { content: draft, media_id: newCommentFiles[postId] ?? undefined }
         }
       )
 
@@ -254,7 +255,7 @@ export function useGroupPosts(apiBase: string, groupId: Ref<number | null>) {
       commentSubmitting[Number(key)] = false
     }
     newPostForm.content = ''
-    newPostForm.file = null
+    newPostForm.media_id = null
   }
 
   function normalizePosts(posts?: ApiGroupPost[]): GroupPostItem[] {
@@ -269,8 +270,8 @@ export function useGroupPosts(apiBase: string, groupId: Ref<number | null>) {
         formattedCreatedAt: formatDate(post.created_at),
         authorName,
         authorInitials: initialsFromName(authorName),
-        avatarSrc: toDataUrl(post.avatar),
-        mediaSrc: toDataUrl(post.file),
+        avatarSrc: mediaUrl(apiBase, post.avatar_id, 'avatar'),
+        mediaSrc: mediaUrl(apiBase, post.media_id, 'feed'),
         commentCount: typeof post.comment_count === 'number' ? post.comment_count : 0
       }
     })
@@ -287,8 +288,8 @@ export function useGroupPosts(apiBase: string, groupId: Ref<number | null>) {
         formattedCreatedAt: formatDate(comment.created_at),
         authorName,
         authorInitials: initialsFromName(authorName),
-        avatarSrc: toDataUrl(comment.avatar),
-        mediaSrc: toDataUrl(comment.file)
+        avatarSrc: mediaUrl(apiBase, comment.avatar_id, 'avatar'),
+        mediaSrc: mediaUrl(apiBase, comment.media_id, 'feed')
       }
     })
   }
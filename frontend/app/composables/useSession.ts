@@ -4,7 +4,7 @@ interface SessionState {
   user_id: number | null
   full_name: string
   email: string
-  avatar: string | null
+  avatar_id: number | null
 }
 
 const _useSession = () => {
@@ -15,7 +15,7 @@ const _useSession = () => {
     user_id: null,
     full_name: '',
     email: '',
-    avatar: null
+    avatar_id: null
   }))
   const loading = ref(false)
   const error = ref<string | null>(null)
@@ -34,7 +34,7 @@ const _useSession = () => {
         user_id: data.user_id ?? null,
         full_name: data.full_name ?? '',
         email: data.email ?? '',
-        avatar: data.avatar ?? null
+        avatar_id: data.avatar_id ?? null
       }
       error.value = null
     } catch (err) {
@@ -1,7 +1,7 @@
 export interface ApiUserListItem {
   user_id: number
   user_full_name: string
-  user_avatar?: string | null
+  user_avatar_id?: number | null
   last_message_time?: string | null
   follows?: boolean
   followed_by?: boolean
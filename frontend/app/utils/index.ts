@@ -6,16 +6,23 @@ export function randomFrom<T>(array: T[]): T {
   return array[Math.floor(Math.random() * array.length)]!
 }
 
-export function normalizeAvatar(raw?: string | null): string | undefined {
-  if (!raw) return undefined
-  if (raw.startsWith('data:')) return raw
-  return `data:image/png;base64,${raw}`
+// mediaUrl returns the URL serving a media id, optionally resized to one of the variants of
+// GET /protected/v1/media/{media_id} (avatar_small, avatar, thumbnail, feed, preview, short).
+export function mediaUrl(apiBase: string, id?: number | null, variant?: string): string | undefined {
+  if (!id) return undefined
+  const url = `${apiBase}/protected/v1/media/${id}`
+  return variant ? `${url}?variant=${variant}` : url
 }
 
-export function inferImageMime(base64: string): string {
-  const trimmed = base64.trim()
-  if (trimmed.startsWith('/9j/')) return 'image/jpeg'
-  if (trimmed.startsWith('iVBOR')) return 'image/png'
-  if (trimmed.startsWith('R0lG')) return 'image/gif'
-  return 'image/png'
+// uploadMedia stores a file with POST /protected/v1/media and returns its media id, which posts,
+// comments and profile updates reference instead of inline base64.
+export async function uploadMedia(apiBase: string, file: File): Promise<number> {
+  const body = new FormData()
+  body.append('file', file)
+  const data = await $fetch<{ media_id: number }>(`${apiBase}/protected/v1/media`, {
+    method: 'POST',
+    credentials: 'include',
+    body
+  })
+  return data.media_id
 }